@@ -0,0 +1,247 @@
+package achievement
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"h5project/database"
+	"h5project/models"
+	"h5project/points"
+
+	"github.com/lib/pq"
+)
+
+// typeColumns 查询成就类型时使用的列（成就类型表别名为 at），顺序与 scanType 一致
+const typeColumns = `at.id, at.code, at.name, COALESCE(at.description, ''), at.reward_points, at.created_at,
+	at.metric, at.threshold, at.repeatable, at.auto_claim, at.scope_type, at.scope_id`
+
+// scanType 按 typeColumns 的顺序扫描一个成就类型，extra 追加在后面
+func scanType(row interface{ Scan(...interface{}) error }, extra ...interface{}) (models.AchievementType, error) {
+	var t models.AchievementType
+	var scopeID sql.NullInt64
+	dest := []interface{}{
+		&t.ID, &t.Code, &t.Name, &t.Description, &t.RewardPoints, &t.CreatedAt,
+		&t.Metric, &t.Threshold, &t.Repeatable, &t.AutoClaim, &t.ScopeType, &scopeID,
+	}
+	err := row.Scan(append(dest, extra...)...)
+	if scopeID.Valid {
+		id := int(scopeID.Int64)
+		t.ScopeID = &id
+	}
+	return t, err
+}
+
+// LoadTypes 读取所有成就类型及其规则
+func LoadTypes(q database.DBTX) ([]models.AchievementType, error) {
+	return queryTypes(q, "SELECT "+typeColumns+" FROM achievement_types at ORDER BY at.id")
+}
+
+// loadTypesByMetric 读取使用指定指标的成就类型
+func loadTypesByMetric(q database.DBTX, metrics []string) ([]models.AchievementType, error) {
+	return queryTypes(q,
+		"SELECT "+typeColumns+" FROM achievement_types at WHERE at.metric = ANY($1) ORDER BY at.id",
+		pq.Array(metrics),
+	)
+}
+
+// queryTypes 执行返回 typeColumns 的查询
+func queryTypes(q database.DBTX, query string, args ...interface{}) ([]models.AchievementType, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var types []models.AchievementType
+	for rows.Next() {
+		t, err := scanType(rows)
+		if err != nil {
+			return nil, err
+		}
+		types = append(types, t)
+	}
+	return types, rows.Err()
+}
+
+// LoadType 按代码读取一个成就类型，不存在时返回 sql.ErrNoRows
+func LoadType(q database.DBTX, code string) (models.AchievementType, error) {
+	return scanType(q.QueryRow("SELECT "+typeColumns+" FROM achievement_types at WHERE at.code = $1", code))
+}
+
+// LoadTypeByID 按ID读取一个成就类型，不存在时返回 sql.ErrNoRows
+func LoadTypeByID(q database.DBTX, id int) (models.AchievementType, error) {
+	return scanType(q.QueryRow("SELECT "+typeColumns+" FROM achievement_types at WHERE at.id = $1", id))
+}
+
+// Unlocked 一次评估中新达成的成就
+type Unlocked struct {
+	Type      models.AchievementType
+	Milestone int  // 可重复成就本次达到的里程碑，其他成就为 0
+	Claimed   bool // 是否已自动领取奖励
+}
+
+// Evaluate 按规则评估用户的所有成就：保存进度，解锁新达成的成就，自动领取的成就同时发放兑换点
+// 每个成就（可重复成就的每个里程碑）只会解锁和奖励一次，q 为事务时与调用方一起提交
+func Evaluate(q database.DBTX, userID int, now time.Time) ([]Unlocked, error) {
+	types, err := LoadTypes(q)
+	if err != nil {
+		return nil, fmt.Errorf("读取成就规则失败: %w", err)
+	}
+	return evaluate(q, userID, now, types)
+}
+
+// evaluate 评估指定的成就类型
+func evaluate(q database.DBTX, userID int, now time.Time, types []models.AchievementType) ([]Unlocked, error) {
+	evaluator := NewEvaluator(q, userID, now)
+	var unlocked []Unlocked
+	for _, t := range types {
+		progress, err := evaluator.Progress(t)
+		if err != nil {
+			return nil, err
+		}
+		if progress == nil {
+			continue
+		}
+		if err := saveProgress(q, userID, t, *progress, now); err != nil {
+			return nil, err
+		}
+
+		if t.Repeatable {
+			for _, milestone := range Milestones(t, *progress) {
+				ok, err := unlockMilestone(q, userID, t, milestone)
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					unlocked = append(unlocked, Unlocked{Type: t, Milestone: milestone, Claimed: true})
+				}
+			}
+			continue
+		}
+
+		if !progress.Reached() {
+			continue
+		}
+		ok, err := unlock(q, userID, t)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			unlocked = append(unlocked, Unlocked{Type: t, Claimed: t.AutoClaim})
+		}
+	}
+	return unlocked, nil
+}
+
+// Revoke 撤销条件已不再满足、奖励尚未领取的一次性成就，返回被撤销的成就
+// 规则变更后用于修正历史数据；已领取的成就保留，不收回已发放的兑换点
+func Revoke(q database.DBTX, userID int, now time.Time) ([]models.AchievementType, error) {
+	pending, err := queryTypes(q,
+		`SELECT `+typeColumns+`
+		 FROM achievement_types at
+		 WHERE at.repeatable = false AND at.id IN (
+			SELECT achievement_type_id FROM user_achievements WHERE user_id = $1 AND claimed_at IS NULL
+		 )
+		 ORDER BY at.id`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("读取未领取的成就失败: %w", err)
+	}
+
+	evaluator := NewEvaluator(q, userID, now)
+	var revoked []models.AchievementType
+	for _, t := range pending {
+		// 未设置指标的成就不自动判定，保持原样
+		progress, err := evaluator.Progress(t)
+		if err != nil {
+			return nil, err
+		}
+		if progress == nil || progress.Reached() {
+			continue
+		}
+
+		result, err := q.Exec(
+			"DELETE FROM user_achievements WHERE user_id = $1 AND achievement_type_id = $2 AND claimed_at IS NULL",
+			userID, t.ID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("撤销成就 %s 失败: %w", t.Code, err)
+		}
+		if affected, _ := result.RowsAffected(); affected > 0 {
+			revoked = append(revoked, t)
+		}
+	}
+	return revoked, nil
+}
+
+// Satisfied 用户当前是否满足成就条件（用于领取奖励前的校验）
+func Satisfied(q database.DBTX, userID int, t models.AchievementType, now time.Time) (bool, error) {
+	progress, err := NewEvaluator(q, userID, now).Progress(t)
+	if err != nil || progress == nil {
+		return false, err
+	}
+	return progress.Reached(), nil
+}
+
+// unlock 解锁一次性成就，自动领取的成就同时发放奖励；返回是否为本次新解锁
+func unlock(q database.DBTX, userID int, t models.AchievementType) (bool, error) {
+	var id int
+	err := q.QueryRow(
+		`INSERT INTO user_achievements (user_id, achievement_type_id, unlocked_at, claimed_at)
+		 VALUES ($1, $2, CURRENT_TIMESTAMP, CASE WHEN $3 THEN CURRENT_TIMESTAMP END)
+		 ON CONFLICT (user_id, achievement_type_id) DO NOTHING
+		 RETURNING id`,
+		userID, t.ID, t.AutoClaim,
+	).Scan(&id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("解锁成就 %s 失败: %w", t.Code, err)
+	}
+
+	if t.AutoClaim {
+		src := points.Source{Type: points.SourceUserAchievement, ID: id}
+		if _, err := points.Credit(q, userID, t.RewardPoints, points.ReasonAchievement, src); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// unlockMilestone 记录可重复成就的一个里程碑并发放奖励；返回是否为本次新达成
+func unlockMilestone(q database.DBTX, userID int, t models.AchievementType, milestone int) (bool, error) {
+	var claimID int
+	err := q.QueryRow(
+		`INSERT INTO milestone_claims (user_id, achievement_type_id, card_count)
+		 VALUES ($1, $2, $3)
+		 ON CONFLICT (user_id, achievement_type_id, card_count) DO NOTHING
+		 RETURNING id`,
+		userID, t.ID, milestone,
+	).Scan(&claimID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("记录成就 %s 的里程碑失败: %w", t.Code, err)
+	}
+
+	// 成就记录用于展示，领取时间为最近一次里程碑的时间
+	_, err = q.Exec(
+		`INSERT INTO user_achievements (user_id, achievement_type_id, unlocked_at, claimed_at)
+		 VALUES ($1, $2, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		 ON CONFLICT (user_id, achievement_type_id) DO UPDATE SET claimed_at = CURRENT_TIMESTAMP`,
+		userID, t.ID,
+	)
+	if err != nil {
+		return false, fmt.Errorf("更新成就 %s 失败: %w", t.Code, err)
+	}
+
+	src := points.Source{Type: points.SourceMilestoneClaim, ID: claimID}
+	if _, err := points.Credit(q, userID, t.RewardPoints, points.ReasonMilestone, src); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,47 @@
+package achievement
+
+import (
+	"fmt"
+
+	"h5project/database"
+	"h5project/events"
+)
+
+// eventMetrics 各领域事件可能改变的指标
+var eventMetrics = map[events.Type][]string{
+	events.CardObtained:    {MetricDistinctCards, MetricAllCards, MetricSeriesCards, MetricSeriesCompleted},
+	events.CheckinRecorded: {MetricCheckinDays, MetricLocations, MetricLocationCheckins},
+	events.PointsSpent:     {MetricRedemptions},
+}
+
+// Subscribe 订阅会改变成就进度的领域事件
+// 事件发生后只重新计算相关指标的成就，保存进度并解锁新达成的成就；处理结果为本次解锁的 []Unlocked
+func Subscribe(bus *events.Bus) {
+	for t := range eventMetrics {
+		bus.Subscribe(t, handleEvent)
+	}
+}
+
+// handleEvent 处理一个领域事件
+func handleEvent(q database.DBTX, e events.Event) (interface{}, error) {
+	types, err := loadTypesByMetric(q, eventMetrics[e.Type])
+	if err != nil {
+		return nil, fmt.Errorf("读取成就规则失败: %w", err)
+	}
+	unlocked, err := evaluate(q, e.UserID, e.At, types)
+	if err != nil || len(unlocked) == 0 {
+		return nil, err
+	}
+	return unlocked, nil
+}
+
+// UnlockedIn 从事件处理结果中取出本次解锁的成就
+func UnlockedIn(results []interface{}) []Unlocked {
+	var unlocked []Unlocked
+	for _, result := range results {
+		if u, ok := result.([]Unlocked); ok {
+			unlocked = append(unlocked, u...)
+		}
+	}
+	return unlocked
+}
@@ -0,0 +1,206 @@
+package achievement
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"h5project/database"
+	"h5project/models"
+)
+
+// ObtainableCardsWhere 收集类指标计入的卡片：用户可以获得过的卡片
+// 即未下架、已经上架，并且下架时间晚于用户注册时间（注册前就已过期的限时卡不计入）
+// 查询中卡片表别名为 c、用户表别名为 u，$2 为当前时间
+const ObtainableCardsWhere = `c.retired = false
+	AND (c.available_from IS NULL OR c.available_from <= $2)
+	AND (c.available_until IS NULL OR c.available_until > u.created_at)`
+
+// CountedCheckinsWhere 计入抽卡资格和成就的地点签到：直接通过检查或审核通过的签到
+// 查询中地点签到表别名为 lc
+const CountedCheckinsWhere = `lc.status IN ('accepted', 'approved')`
+
+// measurement 指标的当前值和总量
+type measurement struct {
+	current int
+	total   int
+
+	visited, remaining []Place // 仅地点类指标
+}
+
+// Evaluator 计算单个用户的成就进度
+// 同一个 Evaluator 内相同的指标只查询一次
+type Evaluator struct {
+	q      database.DBTX
+	userID int
+	now    time.Time
+	cache  map[string]measurement
+}
+
+// NewEvaluator 创建进度计算器，q 可以是事务
+func NewEvaluator(q database.DBTX, userID int, now time.Time) *Evaluator {
+	return &Evaluator{
+		q:      q,
+		userID: userID,
+		now:    now,
+		cache:  make(map[string]measurement),
+	}
+}
+
+// Progress 计算成就进度，未设置指标的成就返回 nil
+func (e *Evaluator) Progress(t models.AchievementType) (*Progress, error) {
+	if t.Metric == "" {
+		return nil, nil
+	}
+
+	key := t.Metric
+	var scopeID *int
+	if t.ScopeType != ScopeNone {
+		id, err := e.resolveScope(t)
+		if err != nil {
+			return nil, err
+		}
+		if id == nil {
+			// 作用范围不存在（如地点已删除），成就无法达成
+			p := progressOf(t, measurement{})
+			return &p, nil
+		}
+		scopeID = id
+		key = fmt.Sprintf("%s:%s:%d", t.Metric, t.ScopeType, *id)
+	}
+
+	m, ok := e.cache[key]
+	if !ok {
+		var err error
+		m, err = e.measure(t.Metric, scopeID)
+		if err != nil {
+			return nil, fmt.Errorf("计算成就 %s 的进度失败: %w", t.Code, err)
+		}
+		e.cache[key] = m
+	}
+
+	p := progressOf(t, m)
+	return &p, nil
+}
+
+// resolveScope 找到成就作用的地点或系列
+// 地点成就未设置 scope_id 时，使用 achievement_code 指向该成就的地点
+func (e *Evaluator) resolveScope(t models.AchievementType) (*int, error) {
+	if t.ScopeID != nil {
+		return t.ScopeID, nil
+	}
+	if t.ScopeType != ScopeLocation {
+		return nil, nil
+	}
+
+	var locationID int
+	err := e.q.QueryRow(
+		"SELECT id FROM checkin_locations WHERE achievement_code = $1 ORDER BY id LIMIT 1",
+		t.Code,
+	).Scan(&locationID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &locationID, nil
+}
+
+// measure 查询指标的当前值和总量
+func (e *Evaluator) measure(metric string, scopeID *int) (measurement, error) {
+	var m measurement
+	var err error
+
+	switch metric {
+	case MetricDistinctCards:
+		err = e.q.QueryRow(
+			"SELECT COUNT(DISTINCT card_id) FROM user_cards WHERE user_id = $1",
+			e.userID,
+		).Scan(&m.current)
+	case MetricAllCards:
+		err = e.q.QueryRow(
+			`SELECT COUNT(owned.card_id), COUNT(*)
+			 FROM cards c
+			 INNER JOIN users u ON u.id = $1
+			 LEFT JOIN (SELECT DISTINCT card_id FROM user_cards WHERE user_id = $1) owned ON owned.card_id = c.id
+			 WHERE `+ObtainableCardsWhere,
+			e.userID, e.now,
+		).Scan(&m.current, &m.total)
+	case MetricCheckinDays:
+		err = e.q.QueryRow(
+			"SELECT COUNT(DISTINCT draw_date) FROM daily_draws WHERE user_id = $1",
+			e.userID,
+		).Scan(&m.current)
+	case MetricLocations:
+		m, err = e.measureLocations()
+	case MetricLocationCheckins:
+		err = e.q.QueryRow(
+			"SELECT COUNT(*) FROM location_checkins lc WHERE lc.user_id = $1 AND lc.location_id = $2 AND "+CountedCheckinsWhere,
+			e.userID, *scopeID,
+		).Scan(&m.current)
+	case MetricSeriesCards:
+		err = e.q.QueryRow(
+			`SELECT COUNT(owned.card_id), COUNT(*)
+			 FROM cards c
+			 INNER JOIN users u ON u.id = $1
+			 LEFT JOIN (SELECT DISTINCT card_id FROM user_cards WHERE user_id = $1) owned ON owned.card_id = c.id
+			 WHERE c.series_id = $3 AND `+ObtainableCardsWhere,
+			e.userID, e.now, *scopeID,
+		).Scan(&m.current, &m.total)
+	case MetricSeriesCompleted:
+		err = e.q.QueryRow(
+			`SELECT COUNT(*) FILTER (WHERE total > 0 AND owned >= total), COUNT(*)
+			 FROM (
+				SELECT s.id, COUNT(c.id) AS total, COUNT(owned.card_id) AS owned
+				FROM card_series s
+				INNER JOIN users u ON u.id = $1
+				LEFT JOIN cards c ON c.series_id = s.id AND `+ObtainableCardsWhere+`
+				LEFT JOIN (SELECT DISTINCT card_id FROM user_cards WHERE user_id = $1) owned ON owned.card_id = c.id
+				GROUP BY s.id
+			 ) series`,
+			e.userID, e.now,
+		).Scan(&m.current, &m.total)
+	case MetricRedemptions:
+		err = e.q.QueryRow(
+			"SELECT COUNT(*) FROM redemption_records WHERE user_id = $1",
+			e.userID,
+		).Scan(&m.current)
+	default:
+		err = fmt.Errorf("未知指标 %s", metric)
+	}
+
+	return m, err
+}
+
+// measureLocations 统计用户打卡过的不同地点，并列出已打卡和未打卡的地点
+func (e *Evaluator) measureLocations() (measurement, error) {
+	var m measurement
+	rows, err := e.q.Query(
+		`SELECT l.id, l.name,
+			EXISTS(SELECT 1 FROM location_checkins lc WHERE lc.location_id = l.id AND lc.user_id = $1 AND `+CountedCheckinsWhere+`)
+		 FROM checkin_locations l
+		 ORDER BY l.id`,
+		e.userID,
+	)
+	if err != nil {
+		return m, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var place Place
+		var visited bool
+		if err := rows.Scan(&place.ID, &place.Name, &visited); err != nil {
+			return m, err
+		}
+		m.total++
+		if visited {
+			m.current++
+			m.visited = append(m.visited, place)
+		} else {
+			m.remaining = append(m.remaining, place)
+		}
+	}
+	return m, rows.Err()
+}
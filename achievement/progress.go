@@ -0,0 +1,154 @@
+package achievement
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"h5project/database"
+	"h5project/models"
+
+	"github.com/lib/pq"
+)
+
+// CardCatalogMetrics 总量取决于卡片目录的指标，卡片或系列变化后需要重新计算（见 RecomputeMetrics）
+var CardCatalogMetrics = []string{MetricAllCards, MetricSeriesCards, MetricSeriesCompleted}
+
+// LocationMetrics 取决于打卡地点的指标，地点变化后需要重新计算
+var LocationMetrics = []string{MetricLocations, MetricLocationCheckins}
+
+// saveProgress 保存用户的成就进度（user_achievement_progress），成就页面直接读取
+func saveProgress(q database.DBTX, userID int, t models.AchievementType, p Progress, now time.Time) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec(
+		`INSERT INTO user_achievement_progress (user_id, achievement_type_id, progress, updated_at)
+		 VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (user_id, achievement_type_id) DO UPDATE SET progress = EXCLUDED.progress, updated_at = EXCLUDED.updated_at`,
+		userID, t.ID, data, now,
+	)
+	if err != nil {
+		return fmt.Errorf("保存成就 %s 的进度失败: %w", t.Code, err)
+	}
+	return nil
+}
+
+// LoadStatuses 用一次查询读取用户所有成就的状态和已保存的进度（只读）
+// 进度在领域事件、管理员修改卡片/地点/成就规则和回填工具中计算，还没有计算过的成就 Progress 为空
+func LoadStatuses(q database.DBTX, userID int) ([]models.AchievementStatus, error) {
+	rows, err := q.Query(
+		`SELECT `+typeColumns+`, COALESCE(loc.name, s.name, ''),
+			ua.unlocked_at, ua.claimed_at, p.progress
+		 FROM achievement_types at
+		 LEFT JOIN user_achievements ua ON ua.achievement_type_id = at.id AND ua.user_id = $1
+		 LEFT JOIN user_achievement_progress p ON p.achievement_type_id = at.id AND p.user_id = $1
+		 LEFT JOIN LATERAL (
+			SELECT l.name FROM checkin_locations l
+			WHERE l.id = at.scope_id OR (at.scope_id IS NULL AND l.achievement_code = at.code)
+			ORDER BY l.id LIMIT 1
+		 ) loc ON at.scope_type = $2
+		 LEFT JOIN card_series s ON at.scope_type = $3 AND s.id = at.scope_id
+		 ORDER BY at.id`,
+		userID, ScopeLocation, ScopeSeries,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var statuses []models.AchievementStatus
+	for rows.Next() {
+		var scopeName string
+		var unlockedAt, claimedAt sql.NullTime
+		var data []byte
+		t, err := scanType(rows, &scopeName, &unlockedAt, &claimedAt, &data)
+		if err != nil {
+			return nil, err
+		}
+		t.ScopeName = scopeName
+
+		status := models.AchievementStatus{
+			AchievementType: t,
+			Unlocked:        unlockedAt.Valid,
+			Claimed:         claimedAt.Valid,
+		}
+		if unlockedAt.Valid {
+			status.UnlockedAt = &unlockedAt.Time
+		}
+		if claimedAt.Valid {
+			status.ClaimedAt = &claimedAt.Time
+		}
+		if t.Metric != "" && data != nil {
+			var p Progress
+			if err := json.Unmarshal(data, &p); err != nil {
+				return nil, fmt.Errorf("解析成就 %s 的进度失败: %w", t.Code, err)
+			}
+			status.Progress = &p
+		}
+
+		statuses = append(statuses, status)
+	}
+	return statuses, rows.Err()
+}
+
+// RecomputeMetrics 为所有用户重新计算使用这些指标的成就进度，并解锁新达成的成就
+// 卡片、系列或地点变化后在同一个事务中调用
+func RecomputeMetrics(q database.DBTX, now time.Time, metrics ...string) error {
+	types, err := loadTypesByMetric(q, metrics)
+	if err != nil {
+		return fmt.Errorf("读取成就规则失败: %w", err)
+	}
+	return recomputeAll(q, now, types)
+}
+
+// RecomputeType 为所有用户重新计算某个成就的进度（新增或修改成就规则后调用）
+func RecomputeType(q database.DBTX, now time.Time, typeID int) error {
+	t, err := LoadTypeByID(q, typeID)
+	if err != nil {
+		return fmt.Errorf("读取成就规则失败: %w", err)
+	}
+	return recomputeAll(q, now, []models.AchievementType{t})
+}
+
+// recomputeAll 为所有用户评估指定的成就
+// 先清除已保存的进度，规则变化后不再适用的进度（如关联的地点已删除）不会留下
+func recomputeAll(q database.DBTX, now time.Time, types []models.AchievementType) error {
+	if len(types) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(types))
+	for i, t := range types {
+		ids[i] = int64(t.ID)
+	}
+	if _, err := q.Exec("DELETE FROM user_achievement_progress WHERE achievement_type_id = ANY($1)", pq.Array(ids)); err != nil {
+		return fmt.Errorf("清除成就进度失败: %w", err)
+	}
+
+	rows, err := q.Query("SELECT id FROM users ORDER BY id")
+	if err != nil {
+		return err
+	}
+	var userIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		userIDs = append(userIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, userID := range userIDs {
+		if _, err := evaluate(q, userID, now, types); err != nil {
+			return fmt.Errorf("重新计算用户 %d 的成就进度失败: %w", userID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package achievement
+
+import (
+	"errors"
+	"fmt"
+
+	"h5project/models"
+)
+
+// 成就指标（achievement_types.metric）
+const (
+	MetricDistinctCards    = "distinct_cards"     // 拥有的不同卡片数
+	MetricAllCards         = "all_cards"          // 拥有的可获得卡片数，总量为全部可获得卡片
+	MetricCheckinDays      = "checkin_days"       // 抽卡打卡的不同天数
+	MetricLocations        = "distinct_locations" // 打卡过的不同地点（教堂）数，总量为全部地点
+	MetricLocationCheckins = "location_checkins"  // 在某个地点的打卡次数（作用范围：地点）
+	MetricSeriesCards      = "series_cards"       // 在某个系列中拥有的卡片数，总量为该系列全部可获得卡片（作用范围：系列）
+	MetricSeriesCompleted  = "series_completed"   // 已集齐的系列数，总量为系列数
+	MetricRedemptions      = "redemptions"        // 兑换次数
+)
+
+// 作用范围（achievement_types.scope_type）
+const (
+	ScopeNone     = ""
+	ScopeLocation = "location"
+	ScopeSeries   = "series"
+)
+
+// metricSpec 指标的约束
+type metricSpec struct {
+	scope    string // 需要的作用范围
+	hasTotal bool   // 是否有总量（Threshold 为 0 时以总量为目标）
+}
+
+var metrics = map[string]metricSpec{
+	MetricDistinctCards:    {scope: ScopeNone},
+	MetricAllCards:         {scope: ScopeNone, hasTotal: true},
+	MetricCheckinDays:      {scope: ScopeNone},
+	MetricLocations:        {scope: ScopeNone, hasTotal: true},
+	MetricLocationCheckins: {scope: ScopeLocation},
+	MetricSeriesCards:      {scope: ScopeSeries, hasTotal: true},
+	MetricSeriesCompleted:  {scope: ScopeNone, hasTotal: true},
+	MetricRedemptions:      {scope: ScopeNone},
+}
+
+// ErrInvalidRule 成就规则不合法
+var ErrInvalidRule = errors.New("成就规则不合法")
+
+// Validate 校验成就规则
+// 地点类规则可以不填 scope_id，此时通过 checkin_locations.achievement_code 关联地点
+func Validate(t models.AchievementType) error {
+	if t.Metric == "" {
+		if t.Repeatable || t.AutoClaim || t.ScopeType != ScopeNone {
+			return fmt.Errorf("%w: 未设置指标", ErrInvalidRule)
+		}
+		return nil
+	}
+
+	spec, ok := metrics[t.Metric]
+	if !ok {
+		return fmt.Errorf("%w: 未知指标 %s", ErrInvalidRule, t.Metric)
+	}
+	if t.ScopeType != spec.scope {
+		return fmt.Errorf("%w: 指标 %s 的作用范围必须为 %q", ErrInvalidRule, t.Metric, spec.scope)
+	}
+	if t.ScopeType == ScopeSeries && t.ScopeID == nil {
+		return fmt.Errorf("%w: 系列成就必须指定 scope_id", ErrInvalidRule)
+	}
+	if t.ScopeType == ScopeNone && t.ScopeID != nil {
+		return fmt.Errorf("%w: 指标 %s 不需要 scope_id", ErrInvalidRule, t.Metric)
+	}
+	if t.Threshold < 0 || (t.Threshold == 0 && !spec.hasTotal) {
+		return fmt.Errorf("%w: 指标 %s 的目标值必须大于 0", ErrInvalidRule, t.Metric)
+	}
+	if t.Repeatable {
+		if t.Threshold == 0 {
+			return fmt.Errorf("%w: 可重复成就必须设置目标值", ErrInvalidRule)
+		}
+		// 可重复成就每次达成即发放，没有手动领取的入口
+		if !t.AutoClaim {
+			return fmt.Errorf("%w: 可重复成就必须自动领取", ErrInvalidRule)
+		}
+	}
+	return nil
+}
+
+// Progress 成就进度
+type Progress struct {
+	Current       int     `json:"current"`
+	Target        int     `json:"target"`
+	NextMilestone int     `json:"next_milestone,omitempty"` // 可重复成就的下一个里程碑
+	Visited       []Place `json:"visited,omitempty"`        // 地点类指标：已打卡的地点
+	Remaining     []Place `json:"remaining,omitempty"`      // 地点类指标：尚未打卡的地点
+}
+
+// Place 进度中列出的打卡地点
+type Place struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// progressOf 根据指标的测量结果计算进度
+func progressOf(t models.AchievementType, m measurement) Progress {
+	p := Progress{
+		Current:   m.current,
+		Target:    t.Threshold,
+		Visited:   m.visited,
+		Remaining: m.remaining,
+	}
+	if t.Threshold == 0 {
+		p.Target = m.total
+	}
+	if t.Repeatable && t.Threshold > 0 {
+		p.NextMilestone = (m.current/t.Threshold + 1) * t.Threshold
+	}
+	return p
+}
+
+// Reached 是否达到目标
+func (p Progress) Reached() bool {
+	return p.Target > 0 && p.Current >= p.Target
+}
+
+// Milestones 可重复成就已经达到的所有里程碑（Threshold 的整数倍）
+func Milestones(t models.AchievementType, p Progress) []int {
+	if !t.Repeatable || t.Threshold <= 0 {
+		return nil
+	}
+	var milestones []int
+	for value := t.Threshold; value <= p.Current; value += t.Threshold {
+		milestones = append(milestones, value)
+	}
+	return milestones
+}
@@ -0,0 +1,150 @@
+package achievement
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"h5project/database"
+)
+
+// 地点成就分级：每个打卡地点按 system_config 的 location_achievement_tiers 自动生成一组地点成就，
+// 格式为逗号分隔的“次数:称号”，按次数从小到大排列，例如 5:初访者,15:朝圣者,50:守护者
+// 生成的成就代码为 location_<地点ID>_<次数>，名称为“地点名称+称号”；已生成的成就可以在成就管理中修改，
+// 分级配置修改后只补充缺少的成就，不会删除已有的成就
+
+// LocationTiersConfigKey 地点成就分级的配置项
+const LocationTiersConfigKey = "location_achievement_tiers"
+
+// DefaultLocationTiers 未配置或配置无效时使用的分级
+const DefaultLocationTiers = "5:初访者,15:朝圣者,50:守护者"
+
+// LocationTier 地点成就的一个等级
+type LocationTier struct {
+	Threshold int    // 在该地点累计打卡的次数
+	Title     string // 称号，成就名称为地点名称加称号
+}
+
+// ParseLocationTiers 解析地点成就分级配置
+func ParseLocationTiers(s string) ([]LocationTier, error) {
+	var tiers []LocationTier
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.SplitN(item, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("无效的分级 %q，格式为 次数:称号", item)
+		}
+		threshold, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+		if err != nil || threshold <= 0 {
+			return nil, fmt.Errorf("无效的分级 %q，次数必须是正整数", item)
+		}
+		title := strings.TrimSpace(parts[1])
+		if title == "" || len([]rune(title)) > 20 {
+			return nil, fmt.Errorf("无效的分级 %q，称号不能为空且不超过 20 字", item)
+		}
+		if len(tiers) > 0 && threshold <= tiers[len(tiers)-1].Threshold {
+			return nil, errors.New("分级的次数必须从小到大排列且不能重复")
+		}
+		tiers = append(tiers, LocationTier{Threshold: threshold, Title: title})
+	}
+	if len(tiers) == 0 {
+		return nil, errors.New("至少需要一个分级")
+	}
+	return tiers, nil
+}
+
+// LoadLocationTiers 读取地点成就分级，未配置或配置无效时使用默认分级
+func LoadLocationTiers(q database.DBTX) []LocationTier {
+	value := DefaultLocationTiers
+	q.QueryRow("SELECT value FROM system_config WHERE key = $1", LocationTiersConfigKey).Scan(&value)
+	tiers, err := ParseLocationTiers(value)
+	if err != nil {
+		log.Printf("⚠️  地点成就分级配置无效，使用默认分级: %v", err)
+		tiers, _ = ParseLocationTiers(DefaultLocationTiers)
+	}
+	return tiers
+}
+
+// EnsureLocationAchievements 为地点补充缺少的分级成就，返回新创建的成就数
+func EnsureLocationAchievements(q database.DBTX, locationID int, name string) (int, error) {
+	return ensureLocationTiers(q, locationID, name, LoadLocationTiers(q))
+}
+
+// EnsureAllLocationAchievements 为所有地点补充缺少的分级成就，返回新创建的成就数
+// 启动时和修改分级配置后调用，直接在数据库中新增的地点也会生成成就
+func EnsureAllLocationAchievements(q database.DBTX) (int, error) {
+	rows, err := q.Query("SELECT id, name FROM checkin_locations ORDER BY id")
+	if err != nil {
+		return 0, err
+	}
+	type location struct {
+		id   int
+		name string
+	}
+	var locations []location
+	for rows.Next() {
+		var l location
+		if err := rows.Scan(&l.id, &l.name); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		locations = append(locations, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	tiers := LoadLocationTiers(q)
+	created := 0
+	for _, l := range locations {
+		n, err := ensureLocationTiers(q, l.id, l.name, tiers)
+		if err != nil {
+			return created, err
+		}
+		created += n
+	}
+	return created, nil
+}
+
+// ensureLocationTiers 为地点创建缺少的分级成就
+// 地点已有相同次数的地点打卡成就（按 scope_id 或 achievement_code 关联，如初始的 location_a_15）时跳过该等级
+func ensureLocationTiers(q database.DBTX, locationID int, name string, tiers []LocationTier) (int, error) {
+	created := 0
+	for _, tier := range tiers {
+		// achievement_types.name 最长 100 字
+		title := []rune(name + tier.Title)
+		if len(title) > 100 {
+			title = title[:100]
+		}
+		result, err := q.Exec(
+			`INSERT INTO achievement_types
+			 (code, name, description, reward_points, metric, threshold, repeatable, auto_claim, scope_type, scope_id)
+			 SELECT $1::varchar, $2::varchar, $3::text, 1, $4::varchar, $5::int, FALSE, TRUE, $6::varchar, $7::int
+			 WHERE NOT EXISTS (
+				SELECT 1 FROM achievement_types at
+				WHERE at.metric = $4 AND at.threshold = $5
+				  AND (at.scope_id = $7 OR (at.scope_id IS NULL AND at.code = (
+					SELECT achievement_code FROM checkin_locations WHERE id = $7
+				  )))
+			 )
+			 ON CONFLICT (code) DO NOTHING`,
+			fmt.Sprintf("location_%d_%d", locationID, tier.Threshold),
+			string(title),
+			fmt.Sprintf("在%s累计打卡%d次", name, tier.Threshold),
+			MetricLocationCheckins, tier.Threshold, ScopeLocation, locationID,
+		)
+		if err != nil {
+			return created, fmt.Errorf("创建地点 %d 的成就失败: %w", locationID, err)
+		}
+		if affected, _ := result.RowsAffected(); affected > 0 {
+			created++
+		}
+	}
+	return created, nil
+}
@@ -0,0 +1,47 @@
+package achievement
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDefaultLocationTiers(t *testing.T) {
+	tiers, err := ParseLocationTiers(DefaultLocationTiers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []LocationTier{{5, "初访者"}, {15, "朝圣者"}, {50, "守护者"}}
+	if !reflect.DeepEqual(tiers, want) {
+		t.Errorf("got %v, want %v", tiers, want)
+	}
+}
+
+func TestParseLocationTiersTrimsWhitespace(t *testing.T) {
+	tiers, err := ParseLocationTiers(" 3 : 新人 , ,10:常客,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []LocationTier{{3, "新人"}, {10, "常客"}}
+	if !reflect.DeepEqual(tiers, want) {
+		t.Errorf("got %v, want %v", tiers, want)
+	}
+}
+
+func TestParseLocationTiersRejectsInvalid(t *testing.T) {
+	for _, s := range []string{
+		"",
+		" , ",
+		"5",
+		"0:零",
+		"-1:负数",
+		"x:称号",
+		"5:",
+		"15:朝圣者,5:初访者",
+		"5:初访者,5:重复",
+		"5:一二三四五六七八九十一二三四五六七八九十一",
+	} {
+		if _, err := ParseLocationTiers(s); err == nil {
+			t.Errorf("ParseLocationTiers(%q) should fail", s)
+		}
+	}
+}
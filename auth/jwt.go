@@ -2,32 +2,74 @@ package auth
 
 import (
 	"errors"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtSecret = []byte("your-secret-key-change-in-production")
+var (
+	mu        sync.RWMutex
+	jwtSecret []byte
+)
+
+// ErrNoSecret 未设置签名密钥
+var ErrNoSecret = errors.New("未设置 JWT 签名密钥")
+
+// SetSecret 设置 JWT 签名密钥（启动时调用一次，使用 config.JWTSecret）
+func SetSecret(s string) {
+	mu.Lock()
+	defer mu.Unlock()
+	jwtSecret = []byte(s)
+}
+
+// secret 当前的签名密钥，未设置时返回 ErrNoSecret
+func secret() ([]byte, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if len(jwtSecret) == 0 {
+		return nil, ErrNoSecret
+	}
+	return jwtSecret, nil
+}
+
+// 用户角色
+const (
+	RoleUser  = "user"  // 普通用户
+	RoleStaff = "staff" // 工作人员（处理反馈、核销兑换等）
+	RoleAdmin = "admin" // 管理员（管理卡片、地点、成就、系统配置等）
+)
+
+// ValidRole 检查角色是否有效
+func ValidRole(role string) bool {
+	return role == RoleUser || role == RoleStaff || role == RoleAdmin
+}
 
 type Claims struct {
 	UserID   int    `json:"user_id"`
 	Username string `json:"username"`
+	Role     string `json:"role"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(userID int, username string) (string, error) {
+func GenerateToken(userID int, username, role string) (string, error) {
 	expirationTime := time.Now().Add(24 * 7 * time.Hour) // 7天过期
 	claims := &Claims{
 		UserID:   userID,
 		Username: username,
+		Role:     role,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
+	key, err := secret()
+	if err != nil {
+		return "", err
+	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	return token.SignedString(key)
 }
 
 func ValidateToken(tokenString string) (*Claims, error) {
@@ -36,7 +78,7 @@ func ValidateToken(tokenString string) (*Claims, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("invalid signing method")
 		}
-		return jwtSecret, nil
+		return secret()
 	})
 
 	if err != nil {
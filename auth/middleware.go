@@ -2,14 +2,19 @@ package auth
 
 import (
 	"context"
+	"database/sql"
+	"log"
 	"net/http"
 	"strings"
+
+	"h5project/database"
 )
 
 type contextKey string
 
 const userIDKey contextKey = "userID"
 const usernameKey contextKey = "username"
+const roleKey contextKey = "role"
 
 func JWTMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -36,12 +41,68 @@ func JWTMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		// 将用户信息存储到请求上下文
 		ctx := context.WithValue(r.Context(), userIDKey, claims.UserID)
 		ctx = context.WithValue(ctx, usernameKey, claims.Username)
+		ctx = context.WithValue(ctx, roleKey, normalizeRole(claims.Role))
 		r = r.WithContext(ctx)
 
 		next(w, r)
 	}
 }
 
+// RequireRole 角色校验中间件（需放在JWTMiddleware之后）
+// 只有角色在 roles 中的用户可以访问；角色每次从数据库读取，修改角色后下一次请求即生效，不依赖 token 中的角色
+func RequireRole(next http.HandlerFunc, roles ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(userIDKey).(int)
+		if !ok {
+			http.Error(w, "未授权", http.StatusUnauthorized)
+			return
+		}
+
+		var role string
+		err := database.DB.QueryRow("SELECT role FROM users WHERE id = $1", userID).Scan(&role)
+		if err == sql.ErrNoRows {
+			http.Error(w, "未授权", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			log.Printf("❌ 查询用户 %d 的角色失败: %v", userID, err)
+			http.Error(w, "服务器错误", http.StatusInternalServerError)
+			return
+		}
+		role = normalizeRole(role)
+
+		for _, allowed := range roles {
+			if role == allowed {
+				next(w, r.WithContext(context.WithValue(r.Context(), roleKey, role)))
+				return
+			}
+		}
+		http.Error(w, "权限不足", http.StatusForbidden)
+	}
+}
+
+// GetRoleFromRequest 获取当前用户角色（未经过JWTMiddleware时视为普通用户）
+func GetRoleFromRequest(r *http.Request) string {
+	if role, ok := r.Context().Value(roleKey).(string); ok {
+		return role
+	}
+	return RoleUser
+}
+
+// GetUsernameFromRequest 获取当前用户名（需经过JWTMiddleware）
+func GetUsernameFromRequest(r *http.Request) string {
+	username, _ := r.Context().Value(usernameKey).(string)
+	return username
+}
+
+// normalizeRole 旧token中没有角色信息，按普通用户处理
+func normalizeRole(role string) string {
+	if !ValidRole(role) {
+		return RoleUser
+	}
+	return role
+}
+
 func GetUserIDFromRequest(r *http.Request) (int, error) {
 	authHeader := r.Header.Get("Authorization")
 	if authHeader == "" {
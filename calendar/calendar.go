@@ -0,0 +1,188 @@
+package calendar
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+	_ "time/tzdata" // 内置时区数据，服务器缺少 tzdata 时也能加载 Asia/Shanghai
+
+	"h5project/config"
+)
+
+// DateFormat 游戏日的日期格式（与 daily_draws.draw_date 一致）
+const DateFormat = "2006-01-02"
+
+// Clock 时钟，测试时可以替换为固定时间
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock 使用系统时间
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time { return time.Now() }
+
+// FixedClock 固定时间的时钟（用于测试）
+type FixedClock struct {
+	T time.Time
+}
+
+func (c FixedClock) Now() time.Time { return c.T }
+
+// Calendar 游戏日历：统一管理每日重置时间和时区
+// 每天在 resetHour 点（按 location 时区）进入新的游戏日，
+// 例如 resetHour=16 时，北京时间 15:59 仍属于前一天
+type Calendar struct {
+	location  *time.Location
+	resetHour int
+	clock     Clock
+
+	periodKind string // 兑换周期类型，见 period.go
+	eventKey   string // 兑换周期为 event 时的活动标识
+}
+
+// New 创建游戏日历
+func New(location *time.Location, resetHour int, clock Clock) (*Calendar, error) {
+	if location == nil {
+		return nil, fmt.Errorf("时区不能为空")
+	}
+	if resetHour < 0 || resetHour > 23 {
+		return nil, fmt.Errorf("重置时间必须在 0~23 之间，当前为 %d", resetHour)
+	}
+	if clock == nil {
+		clock = SystemClock{}
+	}
+	return &Calendar{location: location, resetHour: resetHour, clock: clock}, nil
+}
+
+// Location 日历使用的时区
+func (c *Calendar) Location() *time.Location { return c.location }
+
+// ResetHour 每日重置的小时
+func (c *Calendar) ResetHour() int { return c.resetHour }
+
+// Now 当前时间（日历时区）
+func (c *Calendar) Now() time.Time {
+	return c.clock.Now().In(c.location)
+}
+
+// GameDay 返回时间 t 所属游戏日的零点（日历时区）
+func (c *Calendar) GameDay(t time.Time) time.Time {
+	local := t.In(c.location)
+	if local.Hour() < c.resetHour {
+		local = local.AddDate(0, 0, -1)
+	}
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, c.location)
+}
+
+// Today 当前游戏日，格式 2006-01-02
+func (c *Calendar) Today() string {
+	return c.GameDay(c.clock.Now()).Format(DateFormat)
+}
+
+// NextReset 下一次每日重置的时间
+func (c *Calendar) NextReset() time.Time {
+	day := c.GameDay(c.clock.Now())
+	return time.Date(day.Year(), day.Month(), day.Day()+1, c.resetHour, 0, 0, 0, c.location)
+}
+
+var (
+	defaultCalendar *Calendar
+	mu              sync.RWMutex
+)
+
+// Querier 读取 system_config 的数据库连接，*sql.DB 和 *sql.Tx 都可以使用
+type Querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Load 按配置创建日历：先读取环境变量配置，再用 system_config 表中的
+// game_timezone / game_reset_hour / redemption_period / redemption_event_key 覆盖
+// （db 为空时只使用环境变量）；在事务中调用可以在提交前校验修改后的配置
+func Load(cfg *config.Config, db Querier) (*Calendar, error) {
+	timezone := cfg.GameTimezone
+	resetHour := cfg.GameResetHour
+	periodKind := cfg.RedemptionPeriod
+	eventKey := cfg.RedemptionEventKey
+
+	if db != nil {
+		rows, err := db.Query(
+			`SELECT key, value FROM system_config
+			 WHERE key IN ('game_timezone', 'game_reset_hour', 'redemption_period', 'redemption_event_key')`,
+		)
+		if err != nil {
+			log.Printf("⚠️  读取游戏日历配置失败，使用环境变量配置: %v", err)
+		} else {
+			defer rows.Close()
+			for rows.Next() {
+				var key, value string
+				if err := rows.Scan(&key, &value); err != nil {
+					continue
+				}
+				switch key {
+				case "game_timezone":
+					timezone = value
+				case "game_reset_hour":
+					hour, err := strconv.Atoi(value)
+					if err != nil {
+						log.Printf("⚠️  system_config 中 game_reset_hour='%s' 不是有效的整数，已忽略", value)
+						continue
+					}
+					resetHour = hour
+				case "redemption_period":
+					periodKind = value
+				case "redemption_event_key":
+					eventKey = value
+				}
+			}
+		}
+	}
+
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("无效的时区 '%s': %w", timezone, err)
+	}
+	c, err := New(location, resetHour, SystemClock{})
+	if err != nil {
+		return nil, err
+	}
+	return c.WithPeriod(periodKind, eventKey)
+}
+
+// Reload 重新加载配置并替换默认日历（修改 system_config 后调用）
+func Reload(cfg *config.Config, db Querier) error {
+	c, err := Load(cfg, db)
+	if err != nil {
+		return err
+	}
+	SetDefault(c)
+	log.Printf("✅ 游戏日历已加载 (时区: %s, 每日重置: %d:00, 兑换周期: %s)", c.location, c.resetHour, c.PeriodKind())
+	return nil
+}
+
+// SetDefault 设置默认日历
+func SetDefault(c *Calendar) {
+	mu.Lock()
+	defer mu.Unlock()
+	defaultCalendar = c
+}
+
+// Default 获取默认日历（未加载时使用 Asia/Shanghai、16点重置）
+func Default() *Calendar {
+	mu.RLock()
+	c := defaultCalendar
+	mu.RUnlock()
+	if c != nil {
+		return c
+	}
+
+	location, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		location = time.FixedZone("CST", 8*3600)
+	}
+	c, _ = New(location, 16, SystemClock{})
+	return c
+}
@@ -0,0 +1,87 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+func mustLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func newCalendar(t *testing.T, now time.Time) *Calendar {
+	t.Helper()
+	c, err := New(mustLocation(t, "Asia/Shanghai"), 16, FixedClock{T: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestTodayAroundResetHour(t *testing.T) {
+	shanghai := mustLocation(t, "Asia/Shanghai")
+	for _, tc := range []struct {
+		now  time.Time
+		want string
+	}{
+		{time.Date(2026, 10, 18, 0, 0, 0, 0, shanghai), "2026-10-17"},
+		{time.Date(2026, 10, 18, 15, 59, 59, 0, shanghai), "2026-10-17"},
+		{time.Date(2026, 10, 18, 16, 0, 0, 0, shanghai), "2026-10-18"},
+		{time.Date(2026, 10, 18, 23, 59, 0, 0, shanghai), "2026-10-18"},
+	} {
+		if got := newCalendar(t, tc.now).Today(); got != tc.want {
+			t.Errorf("Today() at %s = %s, want %s", tc.now.Format(time.RFC3339), got, tc.want)
+		}
+	}
+}
+
+func TestTodayWithServerInOtherTimezone(t *testing.T) {
+	// 服务器时间不是北京时间时，仍按日历时区判断游戏日
+	losAngeles := mustLocation(t, "America/Los_Angeles")
+	for _, tc := range []struct {
+		now  time.Time
+		want string
+	}{
+		// UTC 07:59 = 北京时间 15:59
+		{time.Date(2026, 10, 18, 7, 59, 0, 0, time.UTC), "2026-10-17"},
+		// UTC 08:00 = 北京时间 16:00
+		{time.Date(2026, 10, 18, 8, 0, 0, 0, time.UTC), "2026-10-18"},
+		// 洛杉矶 10-18 01:00 = 北京时间 10-18 16:00
+		{time.Date(2026, 10, 18, 1, 0, 0, 0, losAngeles), "2026-10-18"},
+		// 洛杉矶 10-17 20:00 = 北京时间 10-18 11:00，服务器日期和游戏日都是 17 日
+		{time.Date(2026, 10, 17, 20, 0, 0, 0, losAngeles), "2026-10-17"},
+	} {
+		if got := newCalendar(t, tc.now).Today(); got != tc.want {
+			t.Errorf("Today() at %s = %s, want %s", tc.now.Format(time.RFC3339), got, tc.want)
+		}
+	}
+}
+
+func TestNextReset(t *testing.T) {
+	shanghai := mustLocation(t, "Asia/Shanghai")
+	for _, tc := range []struct {
+		now  time.Time
+		want time.Time
+	}{
+		{time.Date(2026, 10, 18, 15, 59, 0, 0, shanghai), time.Date(2026, 10, 18, 16, 0, 0, 0, shanghai)},
+		{time.Date(2026, 10, 18, 16, 0, 0, 0, shanghai), time.Date(2026, 10, 19, 16, 0, 0, 0, shanghai)},
+		{time.Date(2026, 10, 18, 7, 59, 0, 0, time.UTC), time.Date(2026, 10, 18, 16, 0, 0, 0, shanghai)},
+	} {
+		if got := newCalendar(t, tc.now).NextReset(); !got.Equal(tc.want) {
+			t.Errorf("NextReset() at %s = %s, want %s", tc.now.Format(time.RFC3339), got, tc.want)
+		}
+	}
+}
+
+func TestNewRejectsInvalidResetHour(t *testing.T) {
+	for _, hour := range []int{-1, 24} {
+		if _, err := New(time.UTC, hour, nil); err == nil {
+			t.Errorf("New with reset hour %d should fail", hour)
+		}
+	}
+}
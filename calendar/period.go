@@ -0,0 +1,121 @@
+package calendar
+
+import (
+	"fmt"
+	"time"
+)
+
+// 兑换周期类型
+const (
+	PeriodMonthly = "monthly" // 每月
+	PeriodWeekly  = "weekly"  // 每周（ISO周，周一开始）
+	PeriodEvent   = "event"   // 每次活动（由活动标识区分）
+)
+
+// Period 一个兑换周期
+type Period struct {
+	Kind  string     `json:"kind"`
+	Key   string     `json:"key"`   // 周期标识，存入 redemption_records.redemption_month
+	Name  string     `json:"name"`  // 展示名称，如"本月"
+	Start *time.Time `json:"start"` // 周期开始时间（活动周期为空）
+	End   *time.Time `json:"end"`   // 周期结束时间（活动周期为空）
+}
+
+// Every 周期频率描述，如"每月"
+func (p Period) Every() string {
+	switch p.Kind {
+	case PeriodWeekly:
+		return "每周"
+	case PeriodEvent:
+		return "每次活动"
+	default:
+		return "每月"
+	}
+}
+
+// Next 下一个周期的描述，如"下月"
+func (p Period) Next() string {
+	switch p.Kind {
+	case PeriodWeekly:
+		return "下周"
+	case PeriodEvent:
+		return "下次活动"
+	default:
+		return "下月"
+	}
+}
+
+// ValidPeriodKind 检查周期类型是否有效
+func ValidPeriodKind(kind string) bool {
+	return kind == PeriodMonthly || kind == PeriodWeekly || kind == PeriodEvent
+}
+
+// WithPeriod 返回使用指定兑换周期的日历副本
+// eventKey 仅在 kind 为 event 时使用，换一个活动标识即开启新的兑换周期
+func (c *Calendar) WithPeriod(kind, eventKey string) (*Calendar, error) {
+	if !ValidPeriodKind(kind) {
+		return nil, fmt.Errorf("无效的兑换周期 '%s'，必须是 monthly、weekly 或 event", kind)
+	}
+	if kind == PeriodEvent && eventKey == "" {
+		return nil, fmt.Errorf("兑换周期为 event 时必须设置活动标识")
+	}
+	copied := *c
+	copied.periodKind = kind
+	copied.eventKey = eventKey
+	return &copied, nil
+}
+
+// PeriodKind 当前使用的兑换周期类型
+func (c *Calendar) PeriodKind() string {
+	if c.periodKind == "" {
+		return PeriodMonthly
+	}
+	return c.periodKind
+}
+
+// CurrentPeriod 当前游戏日所在的兑换周期
+func (c *Calendar) CurrentPeriod() Period {
+	return c.PeriodAt(c.clock.Now())
+}
+
+// PeriodAt 时间 t 所在的兑换周期
+// 周期边界与游戏日一致：按日历时区，在每日重置时间切换
+func (c *Calendar) PeriodAt(t time.Time) Period {
+	day := c.GameDay(t)
+
+	switch c.PeriodKind() {
+	case PeriodWeekly:
+		year, week := day.ISOWeek()
+		offset := (int(day.Weekday()) + 6) % 7 // 周一为0
+		start := c.resetAt(day.AddDate(0, 0, -offset))
+		end := start.AddDate(0, 0, 7)
+		return Period{
+			Kind:  PeriodWeekly,
+			Key:   fmt.Sprintf("%d-W%02d", year, week),
+			Name:  "本周",
+			Start: &start,
+			End:   &end,
+		}
+	case PeriodEvent:
+		return Period{
+			Kind: PeriodEvent,
+			Key:  "event:" + c.eventKey,
+			Name: "本次活动",
+		}
+	default:
+		start := c.resetAt(time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, c.location))
+		end := start.AddDate(0, 1, 0)
+		return Period{
+			Kind:  PeriodMonthly,
+			Key:   day.Format("2006-01"),
+			Name:  "本月",
+			Start: &start,
+			End:   &end,
+		}
+	}
+}
+
+// resetAt 某个游戏日开始的时刻
+func (c *Calendar) resetAt(day time.Time) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), c.resetHour, 0, 0, 0, c.location)
+}
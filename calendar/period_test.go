@@ -0,0 +1,80 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPeriodAtAroundResetHour(t *testing.T) {
+	shanghai := mustLocation(t, "Asia/Shanghai")
+	c := newCalendar(t, time.Time{})
+	weekly, err := c.WithPeriod(PeriodWeekly, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		cal  *Calendar
+		now  time.Time
+		want string
+	}{
+		// 11月1日 16:00 之前仍属于10月
+		{c, time.Date(2026, 11, 1, 15, 59, 0, 0, shanghai), "2026-10"},
+		{c, time.Date(2026, 11, 1, 16, 0, 0, 0, shanghai), "2026-11"},
+		// 2026-10-19 是周一
+		{weekly, time.Date(2026, 10, 19, 15, 59, 0, 0, shanghai), "2026-W42"},
+		{weekly, time.Date(2026, 10, 19, 16, 0, 0, 0, shanghai), "2026-W43"},
+	} {
+		if got := tc.cal.PeriodAt(tc.now).Key; got != tc.want {
+			t.Errorf("%s PeriodAt(%s) = %s, want %s", tc.cal.PeriodKind(), tc.now.Format(time.RFC3339), got, tc.want)
+		}
+	}
+}
+
+func TestWeeklyPeriodAtYearBoundary(t *testing.T) {
+	shanghai := mustLocation(t, "Asia/Shanghai")
+	c, err := newCalendar(t, time.Time{}).WithPeriod(PeriodWeekly, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		now   time.Time
+		want  string
+		start time.Time
+	}{
+		// 2026-01-01 是周四，属于 2026 年第1周（从 2025-12-29 开始）
+		{time.Date(2026, 1, 1, 16, 0, 0, 0, shanghai), "2026-W01", time.Date(2025, 12, 29, 16, 0, 0, 0, shanghai)},
+		// 2027-01-01 是周五，属于 2026 年第53周
+		{time.Date(2027, 1, 1, 16, 0, 0, 0, shanghai), "2026-W53", time.Date(2026, 12, 28, 16, 0, 0, 0, shanghai)},
+		// 2027-01-04 周一 16:00 之前仍是 2026-W53
+		{time.Date(2027, 1, 4, 15, 59, 0, 0, shanghai), "2026-W53", time.Date(2026, 12, 28, 16, 0, 0, 0, shanghai)},
+		{time.Date(2027, 1, 4, 16, 0, 0, 0, shanghai), "2027-W01", time.Date(2027, 1, 4, 16, 0, 0, 0, shanghai)},
+	} {
+		p := c.PeriodAt(tc.now)
+		if p.Key != tc.want {
+			t.Errorf("PeriodAt(%s) = %s, want %s", tc.now.Format(time.RFC3339), p.Key, tc.want)
+		}
+		if !p.Start.Equal(tc.start) || !p.End.Equal(tc.start.AddDate(0, 0, 7)) {
+			t.Errorf("PeriodAt(%s) = [%s, %s), want start %s", tc.now.Format(time.RFC3339), p.Start, p.End, tc.start)
+		}
+	}
+}
+
+func TestWithPeriodRequiresEventKey(t *testing.T) {
+	c := newCalendar(t, time.Time{})
+	if _, err := c.WithPeriod(PeriodEvent, ""); err == nil {
+		t.Error("event period without key should fail")
+	}
+	if _, err := c.WithPeriod("daily", ""); err == nil {
+		t.Error("unknown period kind should fail")
+	}
+
+	event, err := c.WithPeriod(PeriodEvent, "spring")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := event.PeriodAt(time.Now()); p.Key != "event:spring" || p.Start != nil {
+		t.Errorf("event period = %+v", p)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"log"
+
+	"h5project/achievement"
+	"h5project/calendar"
+	"h5project/config"
+	"h5project/database"
+)
+
+// 成就回填工具：成就规则变更后按当前规则重新评估已有用户
+// 限时卡片到了上架时间后，收集类成就的总量会变化但没有事件触发重新计算，也用此工具刷新进度
+// 使用方法: go run ./cmd/backfill_achievements [-dry-run] [-user 用户ID]
+//
+// 对每个用户：
+//   - 重新计算并保存所有成就的进度
+//   - 解锁按新规则已经达成的成就（自动领取的成就同时发放兑换点）
+//   - 撤销按新规则未达成、奖励尚未领取的成就
+//   - 已领取的成就保留，不收回已发放的兑换点
+
+// errDryRun 试运行时回滚事务
+var errDryRun = errors.New("dry run")
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "只输出评估结果，不写入数据库")
+	onlyUser := flag.Int("user", 0, "只处理指定用户ID（0 表示全部用户）")
+	flag.Parse()
+
+	cfg := config.LoadConfig()
+	if err := database.InitDB(); err != nil {
+		log.Fatal("数据库初始化失败:", err)
+	}
+	defer database.CloseDB()
+
+	if err := calendar.Reload(cfg, database.DB); err != nil {
+		log.Fatal("游戏日历加载失败:", err)
+	}
+
+	userIDs, err := loadUserIDs(*onlyUser)
+	if err != nil {
+		log.Fatal("读取用户失败:", err)
+	}
+
+	var unlockedTotal, revokedTotal, failed int
+	for _, userID := range userIDs {
+		var unlocked []achievement.Unlocked
+		var revoked int
+		err := database.WithTx(func(tx *sql.Tx) error {
+			now := calendar.Default().Now()
+			var err error
+			unlocked, err = achievement.Evaluate(tx, userID, now)
+			if err != nil {
+				return err
+			}
+			types, err := achievement.Revoke(tx, userID, now)
+			if err != nil {
+				return err
+			}
+			revoked = len(types)
+			for _, t := range types {
+				log.Printf("   用户 %d 撤销成就 %s（%s）", userID, t.Code, t.Name)
+			}
+			if *dryRun {
+				return errDryRun
+			}
+			return nil
+		})
+		if err != nil && err != errDryRun {
+			log.Printf("❌ 用户 %d 回填失败: %v", userID, err)
+			failed++
+			continue
+		}
+
+		for _, u := range unlocked {
+			if u.Milestone > 0 {
+				log.Printf("   用户 %d 达成成就 %s 的里程碑 %d", userID, u.Type.Code, u.Milestone)
+			} else {
+				log.Printf("   用户 %d 解锁成就 %s（%s）", userID, u.Type.Code, u.Type.Name)
+			}
+		}
+		unlockedTotal += len(unlocked)
+		revokedTotal += revoked
+	}
+
+	mode := "已写入"
+	if *dryRun {
+		mode = "试运行，未写入"
+	}
+	log.Printf("✅ 回填完成（%s）：用户 %d 个，解锁 %d 个，撤销 %d 个，失败 %d 个",
+		mode, len(userIDs), unlockedTotal, revokedTotal, failed)
+}
+
+// loadUserIDs 读取需要处理的用户ID
+func loadUserIDs(onlyUser int) ([]int, error) {
+	if onlyUser > 0 {
+		return []int{onlyUser}, nil
+	}
+
+	rows, err := database.DB.Query("SELECT id FROM users ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
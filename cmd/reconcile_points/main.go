@@ -0,0 +1,98 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"h5project/config"
+	"h5project/database"
+	"h5project/points"
+)
+
+// 兑换点对账工具：检查 users.exchange_points 与 points_ledger 流水是否一致
+// 使用方法: go run ./cmd/reconcile_points [-fix | -adjust-ledger -operator 操作人 -reason 原因]
+//
+// 不一致的用户会逐个列出，存在不一致时以状态码 1 退出（便于定时任务告警）。
+// 流水只追加不修改，是兑换点的准确记录：
+//   - -fix 把不一致用户的 users.exchange_points 重置为流水合计
+//   - -adjust-ledger 反过来以当前余额为准，为每个不一致的用户追加一条对账修正流水；
+//     只在确认余额才是正确的值时使用，必须提供 -operator 和 -reason，记录在审计日志中
+
+func main() {
+	fix := flag.Bool("fix", false, "把缓存余额重置为流水合计")
+	adjustLedger := flag.Bool("adjust-ledger", false, "以当前余额为准追加对账修正流水（需要 -operator 和 -reason）")
+	operator := flag.String("operator", "", "执行 -adjust-ledger 的操作人")
+	reason := flag.String("reason", "", "执行 -adjust-ledger 的原因")
+	flag.Parse()
+
+	if *fix && *adjustLedger {
+		log.Fatal("-fix 和 -adjust-ledger 不能同时使用")
+	}
+	if *adjustLedger && (strings.TrimSpace(*operator) == "" || strings.TrimSpace(*reason) == "") {
+		log.Fatal("-adjust-ledger 需要提供 -operator 和 -reason")
+	}
+
+	config.LoadConfig()
+	if err := database.InitDB(); err != nil {
+		log.Fatal("数据库初始化失败:", err)
+	}
+	defer database.CloseDB()
+
+	drifts, err := points.FindDrift(database.DB)
+	if err != nil {
+		log.Fatal("对账查询失败:", err)
+	}
+	if len(drifts) == 0 {
+		log.Println("✅ 所有用户的兑换点与流水一致")
+		return
+	}
+
+	for _, d := range drifts {
+		last := "无"
+		if d.LastBalance != nil {
+			last = strconv.Itoa(*d.LastBalance)
+		}
+		log.Printf("⚠️  用户 %d: 当前余额 %d，流水合计 %d，最后一条流水余额 %s（共 %d 条）",
+			d.UserID, d.Cached, d.LedgerSum, last, d.Entries)
+	}
+
+	if !*fix && !*adjustLedger {
+		log.Printf("❌ %d 个用户的兑换点与流水不一致，确认后使用 -fix 把余额重置为流水合计", len(drifts))
+		database.CloseDB()
+		os.Exit(1)
+	}
+
+	// 每个用户单独提交，核对时锁定用户行，避免与正在进行的兑换点变动冲突
+	fixed := 0
+	for _, d := range drifts {
+		var changed bool
+		err := database.WithTx(func(tx *sql.Tx) error {
+			var err error
+			if *adjustLedger {
+				changed, err = points.AdjustLedger(tx, d.UserID, strings.TrimSpace(*operator), strings.TrimSpace(*reason))
+			} else {
+				changed, err = points.Reconcile(tx, d.UserID)
+			}
+			return err
+		})
+		if err != nil {
+			log.Printf("❌ %v", err)
+			continue
+		}
+		if changed {
+			fixed++
+		}
+	}
+	if *adjustLedger {
+		log.Printf("✅ 已为 %d 个用户追加对账修正流水", fixed)
+	} else {
+		log.Printf("✅ 已将 %d 个用户的余额重置为流水合计", fixed)
+	}
+	if fixed < len(drifts) {
+		log.Printf("⚠️  %d 个用户未修正（余额已等于流水合计但最后一条流水的余额不一致，或修正失败），需要人工核查", len(drifts)-fixed)
+	}
+}
@@ -1,11 +1,15 @@
 package config
 
 import (
+	"errors"
 	"log"
 	"os"
 	"strconv"
 )
 
+// insecureJWTSecret 以前写在代码里的默认密钥，已经公开，不能使用
+const insecureJWTSecret = "your-secret-key-change-in-production"
+
 // Config 应用配置结构
 type Config struct {
 	// 服务器配置
@@ -26,6 +30,22 @@ type Config struct {
 
 	// JWT配置
 	JWTSecret string
+
+	// 兑换券签名密钥（必须单独设置，不能与 JWT 密钥相同）
+	VoucherSecret string
+	// 更换兑换券密钥前使用的密钥，只用于校验更换前发出、尚未核销的兑换券（可选）
+	VoucherSecretPrevious string
+
+	// 打卡地点签到码和签到二维码的签名密钥（必须单独设置，不能与 JWT 密钥相同）
+	CheckinSecret string
+
+	// 游戏日历配置（可被 system_config 中的 game_timezone / game_reset_hour 覆盖）
+	GameTimezone  string
+	GameResetHour int // 每天几点进入新的游戏日
+
+	// 兑换周期配置（可被 system_config 中的 redemption_period / redemption_event_key 覆盖）
+	RedemptionPeriod   string // monthly、weekly 或 event
+	RedemptionEventKey string // 兑换周期为 event 时的活动标识
 }
 
 var AppConfig *Config
@@ -50,7 +70,22 @@ func LoadConfig() *Config {
 		DBConnMaxLifetime: getEnvAsInt("DB_CONN_MAX_LIFETIME", 5), // 5分钟
 
 		// JWT配置
-		JWTSecret: getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
+		JWTSecret: getEnv("JWT_SECRET", ""),
+
+		// 兑换券签名密钥
+		VoucherSecret:         getEnv("VOUCHER_SECRET", ""),
+		VoucherSecretPrevious: getEnv("VOUCHER_SECRET_PREVIOUS", ""),
+
+		// 打卡地点签到码签名密钥
+		CheckinSecret: getEnv("CHECKIN_SECRET", ""),
+
+		// 游戏日历配置（默认北京时间下午4点重置）
+		GameTimezone:  getEnv("GAME_TIMEZONE", "Asia/Shanghai"),
+		GameResetHour: getEnvAsInt("GAME_RESET_HOUR", 16),
+
+		// 兑换周期配置（默认每月一次）
+		RedemptionPeriod:   getEnv("REDEMPTION_PERIOD", "monthly"),
+		RedemptionEventKey: getEnv("REDEMPTION_EVENT_KEY", ""),
 	}
 
 	AppConfig = config
@@ -58,6 +93,28 @@ func LoadConfig() *Config {
 	return config
 }
 
+// CheckSecrets 检查签名密钥，服务启动前调用，未设置或使用公开的默认值时拒绝启动
+// 生成密钥：openssl rand -base64 32
+func (c *Config) CheckSecrets() error {
+	if c.JWTSecret == "" {
+		return errors.New("未设置 JWT_SECRET")
+	}
+	if c.JWTSecret == insecureJWTSecret {
+		return errors.New("JWT_SECRET 不能使用默认值")
+	}
+	// 签到码和兑换券的密钥各自独立，任何一个泄露都不影响其他密钥
+	if c.VoucherSecret == "" {
+		return errors.New("未设置 VOUCHER_SECRET")
+	}
+	if c.CheckinSecret == "" {
+		return errors.New("未设置 CHECKIN_SECRET")
+	}
+	if c.VoucherSecret == c.JWTSecret || c.CheckinSecret == c.JWTSecret || c.VoucherSecret == c.CheckinSecret {
+		return errors.New("JWT_SECRET、VOUCHER_SECRET、CHECKIN_SECRET 必须互不相同")
+	}
+	return nil
+}
+
 // GetConfig 获取配置（如果未加载则先加载）
 func GetConfig() *Config {
 	if AppConfig == nil {
@@ -93,6 +93,19 @@ echo "✅ 文件复制完成"
 echo ""
 echo "⚙️  步骤5: 配置systemd服务..."
 sudo cp "$ROOT_DIR/deploy/h5project.service" /etc/systemd/system/
+
+# 签名密钥：首次部署时随机生成，之后保持不变（更换 JWT_SECRET 会使所有用户需要重新登录）
+# JWT、兑换券、签到码各用一个独立的密钥；缺少的密钥会补充生成
+SECRETS_FILE=/etc/h5project/secrets.env
+sudo mkdir -p /etc/h5project
+sudo touch $SECRETS_FILE
+sudo chmod 600 $SECRETS_FILE
+for KEY in JWT_SECRET VOUCHER_SECRET CHECKIN_SECRET; do
+    if ! sudo grep -q "^$KEY=" $SECRETS_FILE; then
+        echo "生成签名密钥: $KEY"
+        echo "$KEY=$(openssl rand -base64 32)" | sudo tee -a $SECRETS_FILE > /dev/null
+    fi
+done
 sudo systemctl daemon-reload
 echo "✅ 服务配置完成"
 
@@ -42,6 +42,9 @@ echo ""
 echo "═══════════════════════════════════════════════════"
 echo ""
 
+# 本地开发密钥
+. ./scripts/dev_env.sh
+
 # 设置环境变量指定端口，前台运行，显示实时日志
 PORT=8081 go run main.go
 
@@ -0,0 +1,76 @@
+package events
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"h5project/database"
+)
+
+// Type 领域事件类型
+type Type string
+
+const (
+	CardObtained    Type = "card_obtained"    // 用户获得新卡片（抽卡）
+	CheckinRecorded Type = "checkin_recorded" // 用户完成打卡（每日抽卡打卡、地点打卡）
+	PointsSpent     Type = "points_spent"     // 用户消耗兑换点（兑换）
+)
+
+// Event 领域事件
+type Event struct {
+	Type   Type
+	UserID int
+	At     time.Time // 按游戏日历的当前时间
+}
+
+// Handler 事件处理函数
+// q 为发布方的事务，处理结果与业务数据一起提交；返回值原样交给发布方（如本次解锁的成就），不需要时返回 nil
+type Handler func(q database.DBTX, e Event) (interface{}, error)
+
+// Bus 同步事件总线：事件在发布方的事务中依次交给订阅者处理
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[Type][]Handler
+}
+
+// NewBus 创建事件总线
+func NewBus() *Bus {
+	return &Bus{handlers: make(map[Type][]Handler)}
+}
+
+// Subscribe 订阅事件
+func (b *Bus) Subscribe(t Type, h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[t] = append(b.handlers[t], h)
+}
+
+// Publish 按订阅顺序处理事件，返回所有处理函数的非空结果
+// 任一处理函数失败即返回错误，发布方应回滚事务
+func (b *Bus) Publish(q database.DBTX, evts ...Event) ([]interface{}, error) {
+	var results []interface{}
+	for _, e := range evts {
+		b.mu.RLock()
+		handlers := b.handlers[e.Type]
+		b.mu.RUnlock()
+
+		for _, h := range handlers {
+			result, err := h(q, e)
+			if err != nil {
+				return nil, fmt.Errorf("处理事件 %s 失败: %w", e.Type, err)
+			}
+			if result != nil {
+				results = append(results, result)
+			}
+		}
+	}
+	return results, nil
+}
+
+var defaultBus = NewBus()
+
+// Default 获取默认事件总线
+func Default() *Bus {
+	return defaultBus
+}
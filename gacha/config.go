@@ -0,0 +1,111 @@
+package gacha
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"h5project/database"
+)
+
+// draw_weights 表中的配置类别
+const (
+	CategoryPolicy = "policy"
+	CategoryRarity = "rarity"
+)
+
+// Config 抽卡概率配置
+type Config struct {
+	NewCardRate   float64            // 抽到未拥有卡片的概率（0~1）
+	RarityWeights map[string]float64 // 各稀有度的单卡权重
+	PityThreshold int                // 连续重复多少次后触发保底，0 表示关闭
+}
+
+// DefaultConfig 默认配置（与最初硬编码的"新卡90%，旧卡10%"一致）
+func DefaultConfig() Config {
+	return Config{
+		NewCardRate:   0.9,
+		RarityWeights: map[string]float64{},
+		PityThreshold: 5,
+	}
+}
+
+// LoadConfig 从 draw_weights 表读取抽卡配置，运营修改表后下一次抽卡即生效
+// 表中缺失的项使用默认值；非法的项同样使用默认值，并在返回的错误中列出（q 可以是事务，用于保存前校验）
+func LoadConfig(q database.DBTX) (Config, error) {
+	cfg := DefaultConfig()
+
+	rows, err := q.Query("SELECT category, key, weight FROM draw_weights ORDER BY category, key")
+	if err != nil {
+		return cfg, err
+	}
+	defer rows.Close()
+
+	var errs []error
+	for rows.Next() {
+		var category, key string
+		var weight float64
+		if err := rows.Scan(&category, &key, &weight); err != nil {
+			return cfg, err
+		}
+		if err := cfg.apply(category, key, weight); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return cfg, err
+	}
+	return cfg, errors.Join(errs...)
+}
+
+// apply 应用单条配置，未知的配置项和超出范围的值返回错误且不应用
+func (c *Config) apply(category, key string, weight float64) error {
+	switch category {
+	case CategoryPolicy:
+		switch key {
+		case "new_card_rate":
+			if weight < 0 || weight > 1 {
+				return fmt.Errorf("new_card_rate=%v 超出范围 [0,1]", weight)
+			}
+			c.NewCardRate = weight
+		case "pity_threshold":
+			// 0 表示关闭保底
+			if weight < 0 || weight != math.Trunc(weight) {
+				return fmt.Errorf("pity_threshold=%v 必须是非负整数", weight)
+			}
+			c.PityThreshold = int(weight)
+		default:
+			return fmt.Errorf("未知的配置项 %s/%s", category, key)
+		}
+	case CategoryRarity:
+		if weight < 0 {
+			return fmt.Errorf("稀有度 %s 的权重 %v 为负数", key, weight)
+		}
+		c.RarityWeights[key] = weight
+	default:
+		return fmt.Errorf("未知的配置类别 %s", category)
+	}
+	return nil
+}
+
+// BuildPolicy 根据配置组装抽卡策略
+func BuildPolicy(cfg Config) DrawPolicy {
+	return cfg.PityPolicy()
+}
+
+// PityPolicy 在新卡偏向策略外包一层保底
+func (c Config) PityPolicy() PityPolicy {
+	return PityPolicy{
+		Threshold: c.PityThreshold,
+		Inner: NewCardBiasPolicy{
+			NewCardRate: c.NewCardRate,
+			Picker:      c.RarityPicker(),
+		},
+		Rarity: c.RarityPicker(),
+	}
+}
+
+// RarityPicker 按配置的稀有度权重挑选卡片
+func (c Config) RarityPicker() RarityPicker {
+	return RarityPicker{Weights: c.RarityWeights}
+}
@@ -0,0 +1,52 @@
+package gacha
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConfigApply(t *testing.T) {
+	cfg := DefaultConfig()
+	for _, err := range []error{
+		cfg.apply(CategoryPolicy, "new_card_rate", 0.7),
+		cfg.apply(CategoryPolicy, "pity_threshold", 8),
+		cfg.apply(CategoryRarity, "rare", 2.5),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := Config{
+		NewCardRate:   0.7,
+		RarityWeights: map[string]float64{"rare": 2.5},
+		PityThreshold: 8,
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("got %+v, want %+v", cfg, want)
+	}
+}
+
+func TestConfigApplyRejectsOutOfRange(t *testing.T) {
+	cfg := DefaultConfig()
+	for _, item := range []struct {
+		category, key string
+		weight        float64
+	}{
+		{CategoryPolicy, "new_card_rate", -0.1},
+		{CategoryPolicy, "new_card_rate", 1.5},
+		{CategoryPolicy, "pity_threshold", -1},
+		{CategoryPolicy, "pity_threshold", 2.5},
+		{CategoryPolicy, "pity_treshold", 3},
+		{CategoryRarity, "rare", -2},
+		{"polcy", "new_card_rate", 0.5},
+	} {
+		if err := cfg.apply(item.category, item.key, item.weight); err == nil {
+			t.Errorf("apply(%s, %s, %v) should fail", item.category, item.key, item.weight)
+		}
+	}
+
+	if !reflect.DeepEqual(cfg, DefaultConfig()) {
+		t.Errorf("out-of-range values should be ignored, got %+v", cfg)
+	}
+}
@@ -0,0 +1,55 @@
+package gacha
+
+// PoolOdds 抽卡第一步选中某个卡池的概率，以及在该卡池内各稀有度的概率
+type PoolOdds struct {
+	Probability float64      `json:"probability"` // 本次抽卡从该卡池抽取的概率（0~1）
+	CardCount   int          `json:"card_count"`
+	Rarities    []RarityOdds `json:"rarities"` // 从该卡池抽取时各稀有度的概率（条件概率）
+}
+
+// DrawOdds 用户下一次抽卡的实际概率
+// 单张卡的概率 = 卡池概率 × 该卡在卡池内的概率（RarityOdds.PerCard）
+type DrawOdds struct {
+	NewCardPool   PoolOdds   `json:"new_card_pool"`
+	OwnedCardPool PoolOdds   `json:"owned_card_pool"`
+	Pity          PityStatus `json:"pity"`
+}
+
+// Odds 按与 PityPolicy.Draw 相同的规则计算给定卡池下一次抽卡的概率
+func (c Config) Odds(pool Pool) DrawOdds {
+	policy := c.PityPolicy()
+	picker := c.RarityPicker()
+	status := policy.Status(pool.DuplicateStreak)
+
+	newRate := c.NewCardRate
+	owned := pool.OwnedCards
+	switch {
+	case len(pool.NewCards) == 0 && len(pool.OwnedCards) == 0:
+		newRate = 0
+	case len(pool.NewCards) == 0:
+		newRate = 0
+		// 已集齐时保底必出最稀有的卡
+		if status.Guaranteed {
+			owned = policy.rarest(pool.OwnedCards)
+		}
+	case len(pool.OwnedCards) == 0 || status.Guaranteed:
+		newRate = 1
+	}
+
+	odds := DrawOdds{
+		NewCardPool: PoolOdds{
+			Probability: newRate,
+			CardCount:   len(pool.NewCards),
+			Rarities:    picker.Odds(pool.NewCards),
+		},
+		OwnedCardPool: PoolOdds{
+			CardCount: len(owned),
+			Rarities:  picker.Odds(owned),
+		},
+		Pity: status,
+	}
+	if len(pool.OwnedCards) > 0 {
+		odds.OwnedCardPool.Probability = 1 - newRate
+	}
+	return odds
+}
@@ -0,0 +1,89 @@
+package gacha
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"h5project/models"
+)
+
+func TestOddsMatchDraws(t *testing.T) {
+	const draws = 30000
+	cfg := Config{
+		NewCardRate:   0.8,
+		RarityWeights: map[string]float64{"common": 4, "rare": 1},
+		PityThreshold: 5,
+	}
+	pool := Pool{
+		NewCards:   []models.Card{{ID: 1, Rarity: "common"}, {ID: 2, Rarity: "rare"}},
+		OwnedCards: []models.Card{{ID: 3, Rarity: "common"}, {ID: 4, Rarity: "rare"}, {ID: 5, Rarity: "rare"}},
+	}
+
+	// 各张卡的公示概率
+	odds := cfg.Odds(pool)
+	want := make(map[int]float64)
+	for _, side := range []struct {
+		cards []models.Card
+		odds  PoolOdds
+	}{{pool.NewCards, odds.NewCardPool}, {pool.OwnedCards, odds.OwnedCardPool}} {
+		for _, card := range side.cards {
+			for _, r := range side.odds.Rarities {
+				if r.Rarity == card.Rarity {
+					want[card.ID] = side.odds.Probability * r.PerCard
+				}
+			}
+		}
+	}
+
+	policy := cfg.PityPolicy()
+	rng := rand.New(rand.NewSource(11))
+	counts := make(map[int]int)
+	for i := 0; i < draws; i++ {
+		result, err := policy.Draw(pool, rng)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[result.Card.ID]++
+	}
+
+	sum := 0.0
+	for id, p := range want {
+		sum += p
+		if got := float64(counts[id]) / draws; math.Abs(got-p) > 0.01 {
+			t.Errorf("card %d: drawn %.4f, published %.4f", id, got, p)
+		}
+	}
+	if math.Abs(sum-1) > 1e-9 {
+		t.Errorf("odds sum to %v, want 1", sum)
+	}
+}
+
+func TestOddsWithPityAndEmptySides(t *testing.T) {
+	cfg := Config{
+		NewCardRate:   0.8,
+		RarityWeights: map[string]float64{"common": 4, "rare": 1},
+		PityThreshold: 2,
+	}
+	cards := []models.Card{{ID: 1, Rarity: "common"}, {ID: 2, Rarity: "rare"}}
+
+	// 只剩新卡
+	if odds := cfg.Odds(Pool{NewCards: cards}); odds.NewCardPool.Probability != 1 || odds.OwnedCardPool.Probability != 0 {
+		t.Errorf("only new cards: %+v", odds)
+	}
+	// 保底时必出新卡
+	odds := cfg.Odds(Pool{NewCards: cards[:1], OwnedCards: cards[1:], DuplicateStreak: 2})
+	if odds.NewCardPool.Probability != 1 || !odds.Pity.Guaranteed {
+		t.Errorf("pity with new cards: %+v", odds)
+	}
+	// 已集齐且保底时必出最稀有的卡
+	odds = cfg.Odds(Pool{OwnedCards: cards, DuplicateStreak: 2})
+	if odds.OwnedCardPool.Probability != 1 || odds.OwnedCardPool.CardCount != 1 ||
+		len(odds.OwnedCardPool.Rarities) != 1 || odds.OwnedCardPool.Rarities[0].Rarity != "rare" {
+		t.Errorf("pity with completed collection: %+v", odds)
+	}
+	// 卡池为空
+	if odds := cfg.Odds(Pool{}); odds.NewCardPool.Probability != 0 || odds.OwnedCardPool.Probability != 0 {
+		t.Errorf("empty pool: %+v", odds)
+	}
+}
@@ -0,0 +1,83 @@
+package gacha
+
+import (
+	"math/rand"
+
+	"h5project/models"
+)
+
+// PityPolicy 保底策略：连续抽到 Threshold 次重复卡后，下一次必出新卡
+// 已集齐所有卡片时改为必出当前卡池中最稀有的卡
+type PityPolicy struct {
+	Threshold int // 触发保底所需的连续重复次数，<=0 表示关闭保底
+	Inner     DrawPolicy
+	Rarity    RarityPicker
+}
+
+// NextStreak 一次抽卡后的连续重复次数：抽到新卡或触发保底后清零，否则加一
+// 已集齐所有卡片时每次都是重复卡，触发保底后同样清零，保底每 Threshold 次重复触发一次
+// handlers 中按 daily_draws 统计连续重复次数的查询与此一致
+func NextStreak(streak int, r Result) int {
+	if r.IsNewCard || r.Pity {
+		return 0
+	}
+	return streak + 1
+}
+
+// PityStatus 用户当前的保底进度
+type PityStatus struct {
+	Enabled         bool `json:"enabled"`
+	DuplicateStreak int  `json:"duplicate_streak"` // 当前连续重复次数
+	Threshold       int  `json:"threshold"`
+	Remaining       int  `json:"remaining"`  // 距离保底还需的重复次数
+	Guaranteed      bool `json:"guaranteed"` // 下一次抽卡是否触发保底
+}
+
+// Status 根据连续重复次数计算保底进度
+func (p PityPolicy) Status(streak int) PityStatus {
+	status := PityStatus{
+		Enabled:         p.Threshold > 0,
+		DuplicateStreak: streak,
+		Threshold:       p.Threshold,
+	}
+	if !status.Enabled {
+		return status
+	}
+	if streak < p.Threshold {
+		status.Remaining = p.Threshold - streak
+	}
+	status.Guaranteed = status.Remaining == 0
+	return status
+}
+
+func (p PityPolicy) Draw(pool Pool, rng *rand.Rand) (Result, error) {
+	if !p.Status(pool.DuplicateStreak).Guaranteed {
+		return p.Inner.Draw(pool, rng)
+	}
+
+	if len(pool.NewCards) > 0 {
+		return Result{Card: p.Rarity.Pick(pool.NewCards, rng), IsNewCard: true, Pity: true}, nil
+	}
+	if len(pool.OwnedCards) == 0 {
+		return Result{}, ErrEmptyPool
+	}
+	return Result{Card: p.Rarity.Pick(p.rarest(pool.OwnedCards), rng), IsNewCard: false, Pity: true}, nil
+}
+
+// rarest 返回权重最低（最稀有）的那一档卡片
+func (p PityPolicy) rarest(cards []models.Card) []models.Card {
+	minWeight := p.Rarity.weightOf(cards[0])
+	for _, card := range cards[1:] {
+		if w := p.Rarity.weightOf(card); w < minWeight {
+			minWeight = w
+		}
+	}
+
+	var result []models.Card
+	for _, card := range cards {
+		if p.Rarity.weightOf(card) == minWeight {
+			result = append(result, card)
+		}
+	}
+	return result
+}
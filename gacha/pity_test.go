@@ -0,0 +1,72 @@
+package gacha
+
+import (
+	"math/rand"
+	"testing"
+
+	"h5project/models"
+)
+
+func TestPityResetsAfterCompletedCollection(t *testing.T) {
+	// 已集齐所有卡片：每次都是重复卡，保底每 Threshold 次重复触发一次，而不是达到后每次都触发
+	owned := []models.Card{
+		{ID: 1, Rarity: "common"},
+		{ID: 2, Rarity: "rare"},
+		{ID: 3, Rarity: "legendary"},
+	}
+	policy := Config{
+		NewCardRate:   0.9,
+		RarityWeights: map[string]float64{"common": 10, "rare": 4, "legendary": 1},
+		PityThreshold: 3,
+	}.PityPolicy()
+	rng := rand.New(rand.NewSource(1))
+
+	streak := 0
+	for i := 1; i <= 12; i++ {
+		result, err := policy.Draw(Pool{OwnedCards: owned, DuplicateStreak: streak}, rng)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantPity := i%4 == 0
+		if result.Pity != wantPity {
+			t.Fatalf("draw %d: pity = %v, want %v (streak %d)", i, result.Pity, wantPity, streak)
+		}
+		if result.Pity && result.Card.Rarity != "legendary" {
+			t.Errorf("draw %d: pity should pick the rarest card, got %s", i, result.Card.Rarity)
+		}
+		if result.IsNewCard {
+			t.Errorf("draw %d: completed collection should never draw a new card", i)
+		}
+		streak = NextStreak(streak, result)
+	}
+
+	if status := policy.Status(streak); status.Guaranteed {
+		t.Errorf("status after pity should not stay guaranteed: %+v", status)
+	}
+}
+
+func TestPityGuaranteesNewCard(t *testing.T) {
+	policy := Config{NewCardRate: 0, RarityWeights: map[string]float64{}, PityThreshold: 2}.PityPolicy()
+	pool := Pool{
+		NewCards:        []models.Card{{ID: 1}},
+		OwnedCards:      []models.Card{{ID: 2}},
+		DuplicateStreak: 2,
+	}
+	result, err := policy.Draw(pool, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Pity || !result.IsNewCard || result.Card.ID != 1 {
+		t.Errorf("got %+v, want pity new card 1", result)
+	}
+	if NextStreak(2, result) != 0 {
+		t.Error("streak should reset after pity")
+	}
+}
+
+func TestPityDisabled(t *testing.T) {
+	status := PityPolicy{Threshold: 0}.Status(100)
+	if status.Enabled || status.Guaranteed {
+		t.Errorf("threshold 0 should disable pity: %+v", status)
+	}
+}
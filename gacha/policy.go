@@ -0,0 +1,67 @@
+package gacha
+
+import (
+	"errors"
+	"math/rand"
+
+	"h5project/models"
+)
+
+// ErrEmptyPool 卡池为空，无法抽卡
+var ErrEmptyPool = errors.New("卡池为空")
+
+// Pool 一次抽卡时的候选卡池
+type Pool struct {
+	NewCards   []models.Card // 用户尚未拥有的卡片
+	OwnedCards []models.Card // 用户已拥有的卡片（抽到即为重复卡）
+
+	DuplicateStreak int // 最近连续抽到重复卡的次数（用于保底）
+}
+
+// Result 抽卡结果
+type Result struct {
+	Card      models.Card
+	IsNewCard bool
+	Pity      bool // 是否由保底触发
+}
+
+// DrawPolicy 抽卡策略
+// 策略只依赖传入的卡池和随机源，不访问数据库，方便单独测试
+type DrawPolicy interface {
+	Draw(pool Pool, rng *rand.Rand) (Result, error)
+}
+
+// Picker 在一组卡片中挑选一张
+type Picker interface {
+	Pick(cards []models.Card, rng *rand.Rand) models.Card
+}
+
+// UniformPicker 等概率挑选
+type UniformPicker struct{}
+
+func (UniformPicker) Pick(cards []models.Card, rng *rand.Rand) models.Card {
+	return cards[rng.Intn(len(cards))]
+}
+
+// NewCardBiasPolicy 新卡偏向策略：先按 NewCardRate 决定抽新卡还是旧卡，再在对应卡池内挑选
+// 某一侧卡池为空时自动落到另一侧
+type NewCardBiasPolicy struct {
+	NewCardRate float64
+	Picker      Picker // 为空时等概率挑选
+}
+
+func (p NewCardBiasPolicy) Draw(pool Pool, rng *rand.Rand) (Result, error) {
+	if len(pool.NewCards) == 0 && len(pool.OwnedCards) == 0 {
+		return Result{}, ErrEmptyPool
+	}
+
+	picker := p.Picker
+	if picker == nil {
+		picker = UniformPicker{}
+	}
+
+	if len(pool.NewCards) > 0 && (len(pool.OwnedCards) == 0 || rng.Float64() < p.NewCardRate) {
+		return Result{Card: picker.Pick(pool.NewCards, rng), IsNewCard: true}, nil
+	}
+	return Result{Card: picker.Pick(pool.OwnedCards, rng), IsNewCard: false}, nil
+}
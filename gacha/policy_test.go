@@ -0,0 +1,67 @@
+package gacha
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"h5project/models"
+)
+
+func TestNewCardBiasEmptyPool(t *testing.T) {
+	_, err := NewCardBiasPolicy{NewCardRate: 0.9}.Draw(Pool{}, rand.New(rand.NewSource(1)))
+	if err != ErrEmptyPool {
+		t.Errorf("err = %v, want ErrEmptyPool", err)
+	}
+}
+
+func TestNewCardBiasFallsThroughEmptySide(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	cards := []models.Card{{ID: 1}, {ID: 2}}
+
+	// 新卡概率为 0，但没有旧卡时只能抽新卡
+	for i := 0; i < 100; i++ {
+		result, err := NewCardBiasPolicy{NewCardRate: 0}.Draw(Pool{NewCards: cards}, rng)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.IsNewCard {
+			t.Fatalf("draw %d: only new cards left, got %+v", i, result)
+		}
+	}
+
+	// 新卡概率为 1，但已集齐时只能抽旧卡
+	for i := 0; i < 100; i++ {
+		result, err := NewCardBiasPolicy{NewCardRate: 1}.Draw(Pool{OwnedCards: cards}, rng)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.IsNewCard {
+			t.Fatalf("draw %d: no new cards left, got %+v", i, result)
+		}
+	}
+}
+
+func TestNewCardBiasDistribution(t *testing.T) {
+	const draws = 20000
+	policy := NewCardBiasPolicy{NewCardRate: 0.9}
+	pool := Pool{
+		NewCards:   []models.Card{{ID: 1}, {ID: 2}},
+		OwnedCards: []models.Card{{ID: 3}, {ID: 4}, {ID: 5}},
+	}
+	rng := rand.New(rand.NewSource(42))
+
+	newCards := 0
+	for i := 0; i < draws; i++ {
+		result, err := policy.Draw(pool, rng)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.IsNewCard {
+			newCards++
+		}
+	}
+	if got := float64(newCards) / draws; math.Abs(got-0.9) > 0.01 {
+		t.Errorf("new card rate = %.4f, want 0.9±0.01", got)
+	}
+}
@@ -0,0 +1,116 @@
+package gacha
+
+import (
+	"math/rand"
+	"sort"
+
+	"h5project/models"
+)
+
+// DefaultRarityWeight 未在 draw_weights 中配置的稀有度使用的单卡权重
+const DefaultRarityWeight = 1.0
+
+// RarityPicker 按稀有度加权挑选：每张卡的权重为其稀有度对应的权重
+type RarityPicker struct {
+	Weights map[string]float64
+}
+
+// weightOf 获取单张卡的权重
+func (p RarityPicker) weightOf(card models.Card) float64 {
+	if w, ok := p.Weights[card.Rarity]; ok {
+		return w
+	}
+	return DefaultRarityWeight
+}
+
+func (p RarityPicker) Pick(cards []models.Card, rng *rand.Rand) models.Card {
+	total := 0.0
+	for _, card := range cards {
+		total += p.weightOf(card)
+	}
+	// 权重全为0时退化为等概率
+	if total <= 0 {
+		return UniformPicker{}.Pick(cards, rng)
+	}
+
+	target := rng.Float64() * total
+	for _, card := range cards {
+		target -= p.weightOf(card)
+		if target < 0 {
+			return card
+		}
+	}
+	return cards[len(cards)-1]
+}
+
+// RarityOdds 某个稀有度的概率公示
+type RarityOdds struct {
+	Rarity      string  `json:"rarity"`
+	CardCount   int     `json:"card_count"`
+	Weight      float64 `json:"weight"`      // 单卡权重
+	Probability float64 `json:"probability"` // 抽到该稀有度的概率（0~1）
+	PerCard     float64 `json:"per_card"`    // 该稀有度下单张卡的概率（0~1）
+}
+
+// Odds 计算在给定卡池内按稀有度加权抽卡的概率，按概率从高到低排列
+func (p RarityPicker) Odds(cards []models.Card) []RarityOdds {
+	counts := make(map[string]int)
+	total := 0.0
+	for _, card := range cards {
+		counts[card.Rarity]++
+		total += p.weightOf(card)
+	}
+
+	odds := make([]RarityOdds, 0, len(counts))
+	for rarity, count := range counts {
+		weight := p.weightOf(models.Card{Rarity: rarity})
+		o := RarityOdds{Rarity: rarity, CardCount: count, Weight: weight}
+		if total > 0 {
+			o.PerCard = weight / total
+		} else {
+			o.PerCard = 1 / float64(len(cards))
+		}
+		o.Probability = o.PerCard * float64(count)
+		odds = append(odds, o)
+	}
+
+	sort.Slice(odds, func(i, j int) bool {
+		if odds[i].Probability != odds[j].Probability {
+			return odds[i].Probability > odds[j].Probability
+		}
+		return odds[i].Rarity < odds[j].Rarity
+	})
+	return odds
+}
+
+// RarityWeight 某个稀有度的单卡权重，实际概率取决于所在卡池中其他卡片的权重
+type RarityWeight struct {
+	Rarity    string  `json:"rarity"`
+	CardCount int     `json:"card_count"`
+	Weight    float64 `json:"weight"`
+}
+
+// RarityWeights 列出卡片中各稀有度的单卡权重，按权重从高到低排列
+func (p RarityPicker) RarityWeights(cards []models.Card) []RarityWeight {
+	counts := make(map[string]int)
+	for _, card := range cards {
+		counts[card.Rarity]++
+	}
+
+	weights := make([]RarityWeight, 0, len(counts))
+	for rarity, count := range counts {
+		weights = append(weights, RarityWeight{
+			Rarity:    rarity,
+			CardCount: count,
+			Weight:    p.weightOf(models.Card{Rarity: rarity}),
+		})
+	}
+
+	sort.Slice(weights, func(i, j int) bool {
+		if weights[i].Weight != weights[j].Weight {
+			return weights[i].Weight > weights[j].Weight
+		}
+		return weights[i].Rarity < weights[j].Rarity
+	})
+	return weights
+}
@@ -0,0 +1,83 @@
+package gacha
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"h5project/models"
+)
+
+func TestRarityPickerDistribution(t *testing.T) {
+	const draws = 30000
+	picker := RarityPicker{Weights: map[string]float64{"common": 6, "rare": 3}}
+	// epic 未配置，使用 DefaultRarityWeight
+	cards := []models.Card{
+		{ID: 1, Rarity: "common"},
+		{ID: 2, Rarity: "common"},
+		{ID: 3, Rarity: "rare"},
+		{ID: 4, Rarity: "epic"},
+	}
+	rng := rand.New(rand.NewSource(7))
+
+	counts := make(map[string]int)
+	for i := 0; i < draws; i++ {
+		counts[picker.Pick(cards, rng).Rarity]++
+	}
+
+	odds := picker.Odds(cards)
+	sum := 0.0
+	for _, o := range odds {
+		sum += o.Probability
+		if got := float64(counts[o.Rarity]) / draws; math.Abs(got-o.Probability) > 0.01 {
+			t.Errorf("%s: drawn %.4f, published %.4f", o.Rarity, got, o.Probability)
+		}
+	}
+	if math.Abs(sum-1) > 1e-9 {
+		t.Errorf("odds sum to %v, want 1", sum)
+	}
+
+	// common: 2*6/16, rare: 3/16, epic: 1/16，按概率从高到低排列
+	want := []struct {
+		rarity string
+		p      float64
+	}{{"common", 0.75}, {"rare", 0.1875}, {"epic", 0.0625}}
+	for i, w := range want {
+		if odds[i].Rarity != w.rarity || math.Abs(odds[i].Probability-w.p) > 1e-9 {
+			t.Errorf("odds[%d] = %+v, want %s %.4f", i, odds[i], w.rarity, w.p)
+		}
+	}
+}
+
+func TestRarityPickerZeroWeights(t *testing.T) {
+	picker := RarityPicker{Weights: map[string]float64{"common": 0, "rare": 0}}
+	cards := []models.Card{{ID: 1, Rarity: "common"}, {ID: 2, Rarity: "rare"}}
+	rng := rand.New(rand.NewSource(3))
+
+	// 权重全为 0 时退化为等概率，两张卡都能抽到
+	seen := make(map[int]bool)
+	for i := 0; i < 100; i++ {
+		seen[picker.Pick(cards, rng).ID] = true
+	}
+	if len(seen) != 2 {
+		t.Errorf("zero weights should fall back to uniform, saw %v", seen)
+	}
+
+	for _, o := range picker.Odds(cards) {
+		if o.Probability != 0.5 || o.PerCard != 0.5 {
+			t.Errorf("zero weights odds = %+v, want 0.5", o)
+		}
+	}
+}
+
+func TestRarityPickerSkipsZeroWeight(t *testing.T) {
+	picker := RarityPicker{Weights: map[string]float64{"common": 1, "retired": 0}}
+	cards := []models.Card{{ID: 1, Rarity: "retired"}, {ID: 2, Rarity: "common"}}
+	rng := rand.New(rand.NewSource(5))
+
+	for i := 0; i < 100; i++ {
+		if card := picker.Pick(cards, rng); card.ID != 2 {
+			t.Fatalf("picked zero-weight card %+v", card)
+		}
+	}
+}
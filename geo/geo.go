@@ -0,0 +1,105 @@
+package geo
+
+import "math"
+
+// EarthRadius 地球平均半径（米）
+const EarthRadius = 6371000.0
+
+// Point 经纬度坐标（度）
+type Point struct {
+	Lat float64 `json:"latitude"`
+	Lng float64 `json:"longitude"`
+}
+
+// Valid 纬度在 [-90, 90]、经度在 [-180, 180] 范围内
+func (p Point) Valid() bool {
+	return !math.IsNaN(p.Lat) && !math.IsNaN(p.Lng) &&
+		p.Lat >= -90 && p.Lat <= 90 && p.Lng >= -180 && p.Lng <= 180
+}
+
+// Distance 两点之间的大圆距离（米），使用 haversine 公式
+// 与余弦定理相比，距离很近的两点不会因为 acos 的参数略大于 1 而得到 NaN
+func Distance(a, b Point) float64 {
+	lat1 := radians(a.Lat)
+	lat2 := radians(b.Lat)
+	dLat := lat2 - lat1
+	dLng := radians(b.Lng - a.Lng)
+
+	h := sinSquared(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*sinSquared(dLng/2)
+	// 浮点误差可能让 h 略超出 [0, 1]
+	h = math.Min(1, math.Max(0, h))
+	return 2 * EarthRadius * math.Asin(math.Sqrt(h))
+}
+
+// NormalizeLng 将经度换算到 [-180, 180) 范围内
+func NormalizeLng(lng float64) float64 {
+	lng = math.Mod(lng+180, 360)
+	if lng < 0 {
+		lng += 360
+	}
+	return lng - 180
+}
+
+// Box 经纬度矩形范围
+// 跨越 180° 经线时 MinLng > MaxLng，表示 [MinLng, 180] 和 [-180, MaxLng] 两段
+type Box struct {
+	MinLat, MaxLat float64
+	MinLng, MaxLng float64
+}
+
+// BoundingBox 以 center 为圆心、radius（米）为半径的圆的外接矩形
+// 圆覆盖极点时经度范围为全部经度
+func BoundingBox(center Point, radius float64) Box {
+	dLat := degrees(radius / EarthRadius)
+	box := Box{
+		MinLat: center.Lat - dLat,
+		MaxLat: center.Lat + dLat,
+		MinLng: -180,
+		MaxLng: 180,
+	}
+	if box.MinLat <= -90 || box.MaxLat >= 90 {
+		box.MinLat = math.Max(box.MinLat, -90)
+		box.MaxLat = math.Min(box.MaxLat, 90)
+		return box
+	}
+
+	// 圆上经度偏移最大的点不在圆心所在纬度上，按球面公式计算最大经度偏移
+	sinRatio := math.Sin(radius/EarthRadius) / math.Cos(radians(center.Lat))
+	if sinRatio >= 1 {
+		return box
+	}
+	dLng := degrees(math.Asin(sinRatio))
+	box.MinLng = NormalizeLng(center.Lng - dLng)
+	box.MaxLng = NormalizeLng(center.Lng + dLng)
+	return box
+}
+
+// Wraps 矩形是否跨越 180° 经线
+func (b Box) Wraps() bool {
+	return b.MinLng > b.MaxLng
+}
+
+// Contains 点是否在矩形内
+func (b Box) Contains(p Point) bool {
+	if p.Lat < b.MinLat || p.Lat > b.MaxLat {
+		return false
+	}
+	lng := NormalizeLng(p.Lng)
+	if b.Wraps() {
+		return lng >= b.MinLng || lng <= b.MaxLng
+	}
+	return lng >= b.MinLng && lng <= b.MaxLng
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func degrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+func sinSquared(x float64) float64 {
+	s := math.Sin(x)
+	return s * s
+}
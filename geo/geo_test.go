@@ -0,0 +1,146 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDistanceIdenticalPoints(t *testing.T) {
+	points := []Point{
+		{0, 0},
+		{26.4776, 119.5431},
+		{89.9999999, 10},
+		{-90, 0},
+		{12.3456789, 180},
+		{12.3456789, -180},
+	}
+	for _, p := range points {
+		d := Distance(p, p)
+		if math.IsNaN(d) || d != 0 {
+			t.Errorf("Distance(%v, %v) = %v, want 0", p, p, d)
+		}
+	}
+}
+
+func TestDistanceNearlyIdenticalPointsIsNotNaN(t *testing.T) {
+	// 余弦定理在这里会得到 acos(1.0000000000000002) = NaN
+	a := Point{26.07720000, 119.29650000}
+	b := Point{26.07720001, 119.29650001}
+	d := Distance(a, b)
+	if math.IsNaN(d) || d < 0 || d > 0.01 {
+		t.Errorf("Distance(%v, %v) = %v, want a few millimetres", a, b, d)
+	}
+}
+
+func TestDistanceAcrossAntimeridian(t *testing.T) {
+	a := Point{0, 179.9995}
+	b := Point{0, -179.9995}
+	// 经度相差 0.001°，赤道上约 111 米，而不是绕地球大半圈
+	want := 2 * math.Pi * EarthRadius * 0.001 / 360
+	if d := Distance(a, b); math.Abs(d-want) > 0.01 {
+		t.Errorf("Distance(%v, %v) = %v, want %v", a, b, d, want)
+	}
+	if d1, d2 := Distance(a, b), Distance(b, a); d1 != d2 {
+		t.Errorf("Distance is not symmetric: %v != %v", d1, d2)
+	}
+	if d := Distance(Point{10, 180}, Point{10, -180}); d > 1e-6 {
+		t.Errorf("180 and -180 should be the same meridian, got %v", d)
+	}
+}
+
+func TestDistanceKnownValues(t *testing.T) {
+	tests := []struct {
+		a, b Point
+		want float64
+		tol  float64
+	}{
+		// 赤道上经度相差 1°
+		{Point{0, 0}, Point{0, 1}, 2 * math.Pi * EarthRadius / 360, 0.01},
+		// 两极之间半个大圆
+		{Point{90, 0}, Point{-90, 0}, math.Pi * EarthRadius, 0.01},
+		// 对跖点
+		{Point{0, 0}, Point{0, 180}, math.Pi * EarthRadius, 0.01},
+	}
+	for _, tt := range tests {
+		if d := Distance(tt.a, tt.b); math.Abs(d-tt.want) > tt.tol {
+			t.Errorf("Distance(%v, %v) = %v, want %v", tt.a, tt.b, d, tt.want)
+		}
+	}
+}
+
+func TestNormalizeLng(t *testing.T) {
+	tests := map[float64]float64{
+		0:      0,
+		179.5:  179.5,
+		180:    -180,
+		-180:   -180,
+		181:    -179,
+		-181:   179,
+		540:    -180,
+		-359.5: 0.5,
+	}
+	for in, want := range tests {
+		if got := NormalizeLng(in); math.Abs(got-want) > 1e-9 {
+			t.Errorf("NormalizeLng(%v) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestBoundingBoxContainsCircle(t *testing.T) {
+	centers := []Point{
+		{26.0772, 119.2965},
+		{0, 179.9999},
+		{0, -179.9999},
+		{60, 180},
+		{-45, -180},
+	}
+	for _, c := range centers {
+		box := BoundingBox(c, 500)
+		if !box.Contains(c) {
+			t.Errorf("BoundingBox(%v) = %+v does not contain its center", c, box)
+		}
+		// 圆周上的点都应在外接矩形内
+		for bearing := 0.0; bearing < 360; bearing += 15 {
+			p := destination(c, 499, bearing)
+			if Distance(c, p) > 500 {
+				t.Fatalf("destination(%v) is %v m away", c, Distance(c, p))
+			}
+			if !box.Contains(p) {
+				t.Errorf("BoundingBox(%v) = %+v does not contain %v at bearing %v", c, box, p, bearing)
+			}
+		}
+	}
+}
+
+func TestBoundingBoxWrapsAtAntimeridian(t *testing.T) {
+	box := BoundingBox(Point{0, 179.9999}, 500)
+	if !box.Wraps() {
+		t.Fatalf("BoundingBox near 180° should wrap, got %+v", box)
+	}
+	if !box.Contains(Point{0, -179.9999}) {
+		t.Errorf("wrapped box %+v should contain a point just across the antimeridian", box)
+	}
+	if box.Contains(Point{0, 0}) || box.Contains(Point{0, 179}) || box.Contains(Point{0, -179}) {
+		t.Errorf("wrapped box %+v should not contain points far from the antimeridian", box)
+	}
+}
+
+func TestBoundingBoxNearPole(t *testing.T) {
+	box := BoundingBox(Point{89.999, 30}, 1000)
+	if box.MaxLat != 90 || box.MinLng != -180 || box.MaxLng != 180 {
+		t.Errorf("box around the pole should cover all longitudes, got %+v", box)
+	}
+	if !box.Contains(Point{89.9995, -150}) {
+		t.Errorf("box %+v should contain a point on the other side of the pole", box)
+	}
+}
+
+// destination 从 p 出发沿方位角 bearing（度）前进 distance 米后的位置
+func destination(p Point, distance, bearing float64) Point {
+	lat1, lng1 := radians(p.Lat), radians(p.Lng)
+	delta := distance / EarthRadius
+	theta := radians(bearing)
+	lat2 := math.Asin(math.Sin(lat1)*math.Cos(delta) + math.Cos(lat1)*math.Sin(delta)*math.Cos(theta))
+	lng2 := lng1 + math.Atan2(math.Sin(theta)*math.Sin(delta)*math.Cos(lat1), math.Cos(delta)-math.Sin(lat1)*math.Sin(lat2))
+	return Point{degrees(lat2), NormalizeLng(degrees(lng2))}
+}
@@ -0,0 +1,148 @@
+package geo
+
+import (
+	"math"
+	"sort"
+)
+
+// DefaultCellDegrees 网格索引默认的格子大小（度），约 1.1 公里
+const DefaultCellDegrees = 0.01
+
+// Site 带有效范围的地点（如打卡地点）
+// 有效范围为以 Center 为圆心、Radius 为半径的圆；设置了 Area 时以 Area 为准，忽略 Radius
+type Site struct {
+	ID     int
+	Name   string
+	Center Point
+	Radius float64 // 有效半径（米）
+	Area   *Area   // 不规则边界（可选）
+}
+
+// Covers 点是否在地点的有效范围内，返回到中心点的距离（米）
+func (s Site) Covers(p Point) (float64, bool) {
+	d := Distance(p, s.Center)
+	if s.Area != nil {
+		return d, s.Area.Contains(p)
+	}
+	return d, d <= s.Radius
+}
+
+// bounds 有效范围的外接矩形
+func (s Site) bounds() Box {
+	if s.Area != nil {
+		return s.Area.Bounds()
+	}
+	return BoundingBox(s.Center, s.Radius)
+}
+
+// Match 查询命中的地点及距离（米）
+type Match struct {
+	Site
+	Distance float64
+}
+
+type cell struct {
+	lat, lng int
+}
+
+// Index 地点的内存网格索引
+// 每个地点登记到其外接矩形覆盖的所有格子中，查询时只需计算坐标所在格子里的候选地点的距离
+// 索引创建后只读，可以被多个协程同时查询；地点变更时重新创建
+type Index struct {
+	cellDegrees float64
+	sites       []Site
+	cells       map[cell][]int // 格子 -> sites 下标
+	global      []int          // 外接矩形过大（如覆盖极点）的地点，每次查询都检查
+}
+
+// maxCellsPerSite 单个地点最多登记的格子数，超过时作为全局地点处理
+const maxCellsPerSite = 4096
+
+// NewIndex 创建网格索引，cellDegrees 不大于 0 时使用 DefaultCellDegrees
+func NewIndex(sites []Site, cellDegrees float64) *Index {
+	if cellDegrees <= 0 {
+		cellDegrees = DefaultCellDegrees
+	}
+	idx := &Index{
+		cellDegrees: cellDegrees,
+		sites:       append([]Site(nil), sites...),
+		cells:       make(map[cell][]int),
+	}
+
+	for i, s := range idx.sites {
+		box := s.bounds()
+		ranges := [][2]float64{{box.MinLng, box.MaxLng}}
+		if box.Wraps() {
+			ranges = [][2]float64{{box.MinLng, 180}, {-180, box.MaxLng}}
+		}
+
+		minLat, maxLat := idx.coord(box.MinLat), idx.coord(box.MaxLat)
+		count := 0
+		for _, r := range ranges {
+			count += (idx.coord(r[1]) - idx.coord(r[0]) + 1) * (maxLat - minLat + 1)
+		}
+		if count > maxCellsPerSite {
+			idx.global = append(idx.global, i)
+			continue
+		}
+
+		for _, r := range ranges {
+			for lat := minLat; lat <= maxLat; lat++ {
+				for lng := idx.coord(r[0]); lng <= idx.coord(r[1]); lng++ {
+					key := cell{lat, lng}
+					idx.cells[key] = append(idx.cells[key], i)
+				}
+			}
+		}
+	}
+	return idx
+}
+
+// Len 索引中的地点数量
+func (idx *Index) Len() int {
+	return len(idx.sites)
+}
+
+// Sites 索引中的所有地点
+func (idx *Index) Sites() []Site {
+	return append([]Site(nil), idx.sites...)
+}
+
+// Within 返回有效范围覆盖 p 的所有地点，按距离从近到远排序
+func (idx *Index) Within(p Point) []Match {
+	if !p.Valid() {
+		return nil
+	}
+
+	key := cell{idx.coord(p.Lat), idx.coord(NormalizeLng(p.Lng))}
+	candidates := append(append([]int(nil), idx.cells[key]...), idx.global...)
+
+	var matches []Match
+	for _, i := range candidates {
+		s := idx.sites[i]
+		if d, ok := s.Covers(p); ok {
+			matches = append(matches, Match{Site: s, Distance: d})
+		}
+	}
+	sort.SliceStable(matches, func(a, b int) bool {
+		if matches[a].Distance != matches[b].Distance {
+			return matches[a].Distance < matches[b].Distance
+		}
+		return matches[a].ID < matches[b].ID
+	})
+	return matches
+}
+
+// Nearest 返回有效范围覆盖 p 的最近地点
+func (idx *Index) Nearest(p Point) (Match, bool) {
+	matches := idx.Within(p)
+	if len(matches) == 0 {
+		return Match{}, false
+	}
+	return matches[0], true
+}
+
+// coord 坐标所在格子的编号
+func (idx *Index) coord(deg float64) int {
+	return int(math.Floor(deg / idx.cellDegrees))
+}
@@ -0,0 +1,109 @@
+package geo
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestIndexNearest(t *testing.T) {
+	idx := NewIndex([]Site{
+		{ID: 1, Name: "南门堂", Center: Point{26.4776, 119.5431}, Radius: 500},
+		{ID: 2, Name: "附近的堂", Center: Point{26.4800, 119.5431}, Radius: 500},
+		{ID: 3, Name: "远处的堂", Center: Point{26.0772, 119.2965}, Radius: 500},
+	}, 0)
+
+	m, ok := idx.Nearest(Point{26.4777, 119.5431})
+	if !ok || m.ID != 1 {
+		t.Fatalf("Nearest = %+v, %v; want site 1", m, ok)
+	}
+
+	// 两个地点范围重叠时按距离排序
+	matches := idx.Within(Point{26.4790, 119.5431})
+	if len(matches) != 2 || matches[0].ID != 2 || matches[1].ID != 1 {
+		t.Fatalf("Within = %+v; want sites 2 then 1", matches)
+	}
+
+	if _, ok := idx.Nearest(Point{27, 120}); ok {
+		t.Errorf("point outside every radius should not match")
+	}
+}
+
+func TestIndexIdenticalPoint(t *testing.T) {
+	center := Point{26.4776, 119.5431}
+	idx := NewIndex([]Site{{ID: 7, Center: center, Radius: 0}}, 0)
+	m, ok := idx.Nearest(center)
+	if !ok || m.ID != 7 || m.Distance != 0 {
+		t.Errorf("Nearest(center) = %+v, %v; want site 7 at distance 0", m, ok)
+	}
+}
+
+func TestIndexAcrossAntimeridian(t *testing.T) {
+	idx := NewIndex([]Site{
+		{ID: 1, Center: Point{-16.5, 179.9995}, Radius: 300},
+		{ID: 2, Center: Point{-16.5, -179.9995}, Radius: 300},
+	}, 0)
+
+	for _, p := range []Point{{-16.5, 180}, {-16.5, -180}, {-16.5, 179.9999}, {-16.5, -179.9999}} {
+		matches := idx.Within(p)
+		if len(matches) != 2 {
+			t.Errorf("Within(%v) = %+v; want both sites across the antimeridian", p, matches)
+		}
+	}
+
+	m, ok := idx.Nearest(Point{-16.5, -179.9996})
+	if !ok || m.ID != 2 {
+		t.Errorf("Nearest = %+v, %v; want site 2", m, ok)
+	}
+}
+
+func TestIndexNearPole(t *testing.T) {
+	idx := NewIndex([]Site{{ID: 1, Center: Point{89.9995, 0}, Radius: 200}}, 0)
+	if _, ok := idx.Nearest(Point{89.9995, 180}); !ok {
+		t.Errorf("site near the pole should match a point on the other side of the pole")
+	}
+}
+
+func TestIndexInvalidPoint(t *testing.T) {
+	idx := NewIndex([]Site{{ID: 1, Center: Point{0, 0}, Radius: 1000}}, 0)
+	for _, p := range []Point{{91, 0}, {0, 181}, {-91, -181}} {
+		if matches := idx.Within(p); matches != nil {
+			t.Errorf("Within(%v) = %+v; want nil for invalid coordinates", p, matches)
+		}
+	}
+}
+
+// 网格索引的结果应与逐个计算距离的结果一致
+func TestIndexMatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var sites []Site
+	for i := 0; i < 200; i++ {
+		sites = append(sites, Site{
+			ID:     i + 1,
+			Center: Point{rng.Float64()*0.2 + 26.4, NormalizeLng(rng.Float64()*0.4 + 179.8)},
+			Radius: rng.Float64()*3000 + 50,
+		})
+	}
+	idx := NewIndex(sites, 0.005)
+
+	for i := 0; i < 2000; i++ {
+		p := Point{rng.Float64()*0.2 + 26.4, NormalizeLng(rng.Float64()*0.4 + 179.8)}
+		want := map[int]bool{}
+		for _, s := range sites {
+			if Distance(p, s.Center) <= s.Radius {
+				want[s.ID] = true
+			}
+		}
+		got := idx.Within(p)
+		if len(got) != len(want) {
+			t.Fatalf("Within(%v) returned %d sites, brute force found %d", p, len(got), len(want))
+		}
+		for j, m := range got {
+			if !want[m.ID] {
+				t.Fatalf("Within(%v) returned unexpected site %d", p, m.ID)
+			}
+			if j > 0 && got[j-1].Distance > m.Distance {
+				t.Fatalf("Within(%v) is not sorted by distance", p)
+			}
+		}
+	}
+}
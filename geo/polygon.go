@@ -0,0 +1,225 @@
+package geo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Ring 闭合的环（首尾相同的点只保留一个）
+type Ring []Point
+
+// Polygon 多边形，第一个环为外边界，其余为内部不计入的洞
+type Polygon []Ring
+
+// Area 由一个或多个多边形组成的区域，对应 GeoJSON 的 Polygon 或 MultiPolygon
+// 跨越 180° 经线的区域需要按 RFC 7946 拆分成多个多边形
+type Area struct {
+	Polygons []Polygon
+	bounds   Box
+}
+
+// geoJSON 解析时使用的 GeoJSON 对象，只支持 Polygon、MultiPolygon 和包含它们的 Feature
+type geoJSON struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+	Geometry    *geoJSON        `json:"geometry"`
+}
+
+// ParseGeoJSON 解析并校验 GeoJSON 区域，坐标顺序为 [经度, 纬度]
+func ParseGeoJSON(data []byte) (*Area, error) {
+	var obj geoJSON
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, fmt.Errorf("无效的 GeoJSON: %w", err)
+	}
+	if obj.Type == "Feature" {
+		if obj.Geometry == nil {
+			return nil, errors.New("GeoJSON Feature 缺少 geometry")
+		}
+		obj = *obj.Geometry
+	}
+
+	var raw [][][][]float64
+	switch obj.Type {
+	case "Polygon":
+		var polygon [][][]float64
+		if err := json.Unmarshal(obj.Coordinates, &polygon); err != nil {
+			return nil, fmt.Errorf("无效的 Polygon 坐标: %w", err)
+		}
+		raw = [][][][]float64{polygon}
+	case "MultiPolygon":
+		if err := json.Unmarshal(obj.Coordinates, &raw); err != nil {
+			return nil, fmt.Errorf("无效的 MultiPolygon 坐标: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("不支持的 GeoJSON 类型 %q，只支持 Polygon 和 MultiPolygon", obj.Type)
+	}
+	if len(raw) == 0 {
+		return nil, errors.New("GeoJSON 区域不能为空")
+	}
+
+	area := &Area{}
+	for i, rawPolygon := range raw {
+		if len(rawPolygon) == 0 {
+			return nil, fmt.Errorf("第 %d 个多边形没有边界", i+1)
+		}
+		var polygon Polygon
+		for j, rawRing := range rawPolygon {
+			ring, err := parseRing(rawRing)
+			if err != nil {
+				return nil, fmt.Errorf("第 %d 个多边形的第 %d 个环: %w", i+1, j+1, err)
+			}
+			polygon = append(polygon, ring)
+		}
+		area.Polygons = append(area.Polygons, polygon)
+	}
+	area.bounds = area.computeBounds()
+	return area, nil
+}
+
+// parseRing 解析一个环：至少 3 个不同的点，坐标有效，经度跨度不超过 180°
+func parseRing(raw [][]float64) (Ring, error) {
+	var ring Ring
+	for _, pos := range raw {
+		if len(pos) < 2 {
+			return nil, errors.New("坐标至少需要经度和纬度")
+		}
+		p := Point{Lat: pos[1], Lng: pos[0]}
+		if !p.Valid() {
+			return nil, fmt.Errorf("无效的坐标 [%v, %v]", pos[0], pos[1])
+		}
+		ring = append(ring, p)
+	}
+	if len(ring) > 1 && ring[0] == ring[len(ring)-1] {
+		ring = ring[:len(ring)-1]
+	}
+	if len(ring) < 3 {
+		return nil, errors.New("至少需要 3 个不同的点")
+	}
+
+	minLng, maxLng := ring[0].Lng, ring[0].Lng
+	for _, p := range ring {
+		minLng = math.Min(minLng, p.Lng)
+		maxLng = math.Max(maxLng, p.Lng)
+	}
+	if maxLng-minLng > 180 {
+		return nil, errors.New("经度跨度超过 180°，跨越 180° 经线的区域请拆分为 MultiPolygon")
+	}
+	return ring, nil
+}
+
+// Contains 点是否在区域内（落在边界上视为在区域内）
+func (a *Area) Contains(p Point) bool {
+	if !a.bounds.Contains(p) {
+		return false
+	}
+	// 180° 和 -180° 是同一条经线，两种写法都检查
+	candidates := []Point{{Lat: p.Lat, Lng: NormalizeLng(p.Lng)}}
+	if candidates[0].Lng == -180 {
+		candidates = append(candidates, Point{Lat: p.Lat, Lng: 180})
+	}
+	for _, c := range candidates {
+		for _, polygon := range a.Polygons {
+			if polygon.contains(c) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Bounds 区域的外接矩形
+func (a *Area) Bounds() Box {
+	return a.bounds
+}
+
+// MarshalJSON 输出 GeoJSON（单个多边形输出为 Polygon，多个输出为 MultiPolygon）
+func (a *Area) MarshalJSON() ([]byte, error) {
+	coords := make([][][][2]float64, len(a.Polygons))
+	for i, polygon := range a.Polygons {
+		for _, ring := range polygon {
+			var positions [][2]float64
+			for _, p := range ring {
+				positions = append(positions, [2]float64{p.Lng, p.Lat})
+			}
+			positions = append(positions, [2]float64{ring[0].Lng, ring[0].Lat})
+			coords[i] = append(coords[i], positions)
+		}
+	}
+	if len(coords) == 1 {
+		return json.Marshal(map[string]interface{}{"type": "Polygon", "coordinates": coords[0]})
+	}
+	return json.Marshal(map[string]interface{}{"type": "MultiPolygon", "coordinates": coords})
+}
+
+// contains 点在外边界内且不在任何洞内（洞的边界视为在多边形内）
+func (pg Polygon) contains(p Point) bool {
+	if !pg[0].contains(p, true) {
+		return false
+	}
+	for _, hole := range pg[1:] {
+		if hole.contains(p, false) {
+			return false
+		}
+	}
+	return true
+}
+
+// contains 射线法判断点是否在环内，onEdge 为落在边界上时的结果
+func (r Ring) contains(p Point, onEdge bool) bool {
+	inside := false
+	for i, j := 0, len(r)-1; i < len(r); j, i = i, i+1 {
+		a, b := r[j], r[i]
+		if onSegment(p, a, b) {
+			return onEdge
+		}
+		if (a.Lat > p.Lat) != (b.Lat > p.Lat) {
+			lng := a.Lng + (p.Lat-a.Lat)*(b.Lng-a.Lng)/(b.Lat-a.Lat)
+			if p.Lng < lng {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
+
+// onSegment 点是否落在线段 ab 上
+func onSegment(p, a, b Point) bool {
+	const eps = 1e-12
+	cross := (b.Lng-a.Lng)*(p.Lat-a.Lat) - (b.Lat-a.Lat)*(p.Lng-a.Lng)
+	if math.Abs(cross) > eps {
+		return false
+	}
+	return p.Lng >= math.Min(a.Lng, b.Lng)-eps && p.Lng <= math.Max(a.Lng, b.Lng)+eps &&
+		p.Lat >= math.Min(a.Lat, b.Lat)-eps && p.Lat <= math.Max(a.Lat, b.Lat)+eps
+}
+
+// computeBounds 所有外边界的外接矩形
+// 拆分在 180° 经线两侧的区域返回跨越 180° 经线的矩形
+func (a *Area) computeBounds() Box {
+	box := Box{MinLat: 90, MaxLat: -90, MinLng: 180, MaxLng: -180}
+	east := Box{MinLng: 180, MaxLng: -180} // 东经部分
+	west := Box{MinLng: 180, MaxLng: -180} // 西经部分
+	for _, polygon := range a.Polygons {
+		for _, p := range polygon[0] {
+			box.MinLat = math.Min(box.MinLat, p.Lat)
+			box.MaxLat = math.Max(box.MaxLat, p.Lat)
+			box.MinLng = math.Min(box.MinLng, p.Lng)
+			box.MaxLng = math.Max(box.MaxLng, p.Lng)
+			half := &west
+			if p.Lng >= 0 {
+				half = &east
+			}
+			half.MinLng = math.Min(half.MinLng, p.Lng)
+			half.MaxLng = math.Max(half.MaxLng, p.Lng)
+		}
+	}
+
+	// 东西两部分分别贴着 180° 经线时，取跨越 180° 经线的较窄矩形
+	if east.MinLng <= east.MaxLng && west.MinLng <= west.MaxLng &&
+		(180-east.MinLng)+(west.MaxLng+180) < box.MaxLng-box.MinLng {
+		box.MinLng, box.MaxLng = east.MinLng, west.MaxLng
+	}
+	return box
+}
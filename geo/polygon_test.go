@@ -0,0 +1,127 @@
+package geo
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// 一个 L 形的教堂院落，中间有一块不对外开放的区域
+const lShapedChurch = `{
+	"type": "Feature",
+	"properties": {"name": "南门堂"},
+	"geometry": {
+		"type": "Polygon",
+		"coordinates": [
+			[[119.540, 26.480], [119.544, 26.480], [119.544, 26.482], [119.542, 26.482], [119.542, 26.484], [119.540, 26.484], [119.540, 26.480]],
+			[[119.5405, 26.4805], [119.5410, 26.4805], [119.5410, 26.4810], [119.5405, 26.4810], [119.5405, 26.4805]]
+		]
+	}
+}`
+
+func TestAreaContains(t *testing.T) {
+	area, err := ParseGeoJSON([]byte(lShapedChurch))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		p    Point
+		want bool
+	}{
+		{"inside the lower part", Point{26.481, 119.543}, true},
+		{"inside the upper part", Point{26.483, 119.541}, true},
+		{"in the missing corner of the L", Point{26.483, 119.543}, false},
+		{"inside the hole", Point{26.4808, 119.5408}, false},
+		{"on the outer boundary", Point{26.480, 119.542}, true},
+		{"on a vertex", Point{26.482, 119.542}, true},
+		{"on the hole boundary", Point{26.4805, 119.5408}, true},
+		{"outside", Point{26.479, 119.541}, false},
+	}
+	for _, tt := range tests {
+		if got := area.Contains(tt.p); got != tt.want {
+			t.Errorf("%s: Contains(%v) = %v, want %v", tt.name, tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestAreaAcrossAntimeridian(t *testing.T) {
+	// 按 RFC 7946 在 180° 经线处拆分的区域
+	area, err := ParseGeoJSON([]byte(`{
+		"type": "MultiPolygon",
+		"coordinates": [
+			[[[179.999, -16.501], [180, -16.501], [180, -16.499], [179.999, -16.499], [179.999, -16.501]]],
+			[[[-180, -16.501], [-179.999, -16.501], [-179.999, -16.499], [-180, -16.499], [-180, -16.501]]]
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !area.Bounds().Wraps() {
+		t.Errorf("bounds %+v should wrap across the antimeridian", area.Bounds())
+	}
+
+	for _, p := range []Point{{-16.5, 179.9995}, {-16.5, -179.9995}, {-16.5, 180}, {-16.5, -180}} {
+		if !area.Contains(p) {
+			t.Errorf("Contains(%v) = false, want true", p)
+		}
+	}
+	for _, p := range []Point{{-16.5, 0}, {-16.5, 179.99}, {-16.5, -179.99}} {
+		if area.Contains(p) {
+			t.Errorf("Contains(%v) = true, want false", p)
+		}
+	}
+}
+
+func TestParseGeoJSONRejectsInvalidAreas(t *testing.T) {
+	tests := map[string]string{
+		"not json":                               `{`,
+		"point":                                  `{"type": "Point", "coordinates": [119.54, 26.48]}`,
+		"empty polygon":                          `{"type": "Polygon", "coordinates": []}`,
+		"too few points":                         `{"type": "Polygon", "coordinates": [[[119.54, 26.48], [119.55, 26.48], [119.54, 26.48]]]}`,
+		"latitude too large":                     `{"type": "Polygon", "coordinates": [[[119.54, 96.48], [119.55, 26.48], [119.55, 26.49], [119.54, 96.48]]]}`,
+		"crosses antimeridian without splitting": `{"type": "Polygon", "coordinates": [[[179.9, 0], [-179.9, 0], [-179.9, 1], [179.9, 1], [179.9, 0]]]}`,
+		"feature without geometry":               `{"type": "Feature", "properties": {}}`,
+	}
+	for name, data := range tests {
+		if _, err := ParseGeoJSON([]byte(data)); err == nil {
+			t.Errorf("%s: ParseGeoJSON should fail", name)
+		}
+	}
+}
+
+func TestAreaMarshalRoundTrip(t *testing.T) {
+	area, err := ParseGeoJSON([]byte(lShapedChurch))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(area)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := ParseGeoJSON(data)
+	if err != nil {
+		t.Fatalf("ParseGeoJSON(%s): %v", data, err)
+	}
+	if len(again.Polygons) != 1 || len(again.Polygons[0]) != 2 || len(again.Polygons[0][0]) != 6 {
+		t.Errorf("round trip changed the area: %s", data)
+	}
+}
+
+func TestIndexWithArea(t *testing.T) {
+	area, err := ParseGeoJSON([]byte(lShapedChurch))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := NewIndex([]Site{
+		// 半径很大，但设置了边界时以边界为准
+		{ID: 1, Center: Point{26.481, 119.541}, Radius: 5000, Area: area},
+	}, 0)
+
+	if _, ok := idx.Nearest(Point{26.483, 119.541}); !ok {
+		t.Error("point inside the area should match")
+	}
+	if _, ok := idx.Nearest(Point{26.483, 119.543}); ok {
+		t.Error("point inside the radius but outside the area should not match")
+	}
+}
@@ -10,4 +10,6 @@ require (
 	golang.org/x/crypto v0.43.0
 )
 
-require golang.org/x/image v0.32.0 // indirect
+require golang.org/x/image v0.32.0
+
+require github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
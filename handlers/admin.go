@@ -0,0 +1,763 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"h5project/achievement"
+	"h5project/auth"
+	"h5project/calendar"
+	"h5project/config"
+	"h5project/database"
+	"h5project/gacha"
+	"h5project/models"
+
+	"github.com/lib/pq"
+)
+
+// 管理后台接口（/api/admin/*）
+// 所有修改操作与审计日志在同一个事务中提交，保证每一次变更都有记录
+
+// recordAudit 记录一条管理操作审计日志
+func recordAudit(q database.DBTX, r *http.Request, action, targetType string, targetID interface{}, detail interface{}) error {
+	userID, err := auth.GetUserIDFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	detailJSON, err := json.Marshal(detail)
+	if err != nil {
+		return fmt.Errorf("序列化审计详情失败: %w", err)
+	}
+
+	_, err = q.Exec(
+		"INSERT INTO admin_audit_logs (user_id, username, action, target_type, target_id, detail) VALUES ($1, $2, $3, $4, $5, $6)",
+		userID, auth.GetUsernameFromRequest(r), action, targetType, fmt.Sprint(targetID), string(detailJSON),
+	)
+	if err != nil {
+		return fmt.Errorf("记录审计日志失败: %w", err)
+	}
+	return nil
+}
+
+// parseAdminPath 解析 /api/admin/xxx/{id}/yyy 形式的路径
+// 返回 id 和 id 之后的剩余路径；路径中没有 id 时 hasID 为 false
+func parseAdminPath(path, prefix string) (id int, rest string, hasID bool, err error) {
+	trimmed := strings.Trim(strings.TrimPrefix(path, prefix), "/")
+	if trimmed == "" {
+		return 0, "", false, nil
+	}
+
+	parts := strings.SplitN(trimmed, "/", 2)
+	id, err = strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, "", false, err
+	}
+	if len(parts) == 2 {
+		rest = parts[1]
+	}
+	return id, rest, true, nil
+}
+
+// AdminConfig 系统配置管理
+// GET 列出所有配置；PUT 修改（或新增）一项配置
+func AdminConfig(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		rows, err := database.DB.Query("SELECT key, value, description, updated_at FROM system_config ORDER BY key")
+		if err != nil {
+			sendError(w, "查询失败", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		var configs []models.SystemConfig
+		for rows.Next() {
+			var c models.SystemConfig
+			if err := rows.Scan(&c.Key, &c.Value, &c.Description, &c.UpdatedAt); err != nil {
+				continue
+			}
+			configs = append(configs, c)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"configs": configs,
+		})
+	case http.MethodPut:
+		var req models.UpdateConfigRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, "无效的请求数据", http.StatusBadRequest)
+			return
+		}
+		req.Key = strings.TrimSpace(req.Key)
+		if req.Key == "" {
+			sendError(w, "配置项不能为空", http.StatusBadRequest)
+			return
+		}
+		if err := validateConfigValue(req.Key, req.Value); err != nil {
+			sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err := database.WithTx(func(tx *sql.Tx) error {
+			var oldValue sql.NullString
+			tx.QueryRow("SELECT value FROM system_config WHERE key = $1", req.Key).Scan(&oldValue)
+
+			_, err := tx.Exec(
+				`INSERT INTO system_config (key, value, description) VALUES ($1, $2, $3)
+				 ON CONFLICT (key) DO UPDATE SET
+				 	value = EXCLUDED.value,
+				 	description = COALESCE(EXCLUDED.description, system_config.description),
+				 	updated_at = CURRENT_TIMESTAMP`,
+				req.Key, req.Value, req.Description,
+			)
+			if err != nil {
+				return err
+			}
+			// 游戏日历相关配置需要组合校验（如兑换周期为 event 时必须设置活动标识），
+			// 在提交前按修改后的配置加载一次，失败时回滚，避免下次启动时无法加载日历
+			if isCalendarConfig(req.Key) {
+				if _, err := calendar.Load(config.GetConfig(), tx); err != nil {
+					return &invalidConfigError{err}
+				}
+			}
+			// 修改地点成就分级后为所有地点补充新等级的成就
+			if req.Key == achievement.LocationTiersConfigKey {
+				created, err := achievement.EnsureAllLocationAchievements(tx)
+				if err != nil {
+					return err
+				}
+				if created > 0 {
+					if err := achievement.RecomputeMetrics(tx, calendar.Default().Now(), achievement.MetricLocationCheckins); err != nil {
+						return err
+					}
+				}
+			}
+			return recordAudit(tx, r, "update", "config", req.Key, map[string]interface{}{
+				"old_value": oldValue.String,
+				"new_value": req.Value,
+			})
+		})
+		var invalid *invalidConfigError
+		if errors.As(err, &invalid) {
+			sendError(w, invalid.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Printf("❌ 修改系统配置失败: %v", err)
+			sendError(w, "修改失败", http.StatusInternalServerError)
+			return
+		}
+
+		// 游戏日历相关配置修改后立即生效
+		if isCalendarConfig(req.Key) {
+			if err := calendar.Reload(config.GetConfig(), database.DB); err != nil {
+				log.Printf("⚠️  重新加载游戏日历失败: %v", err)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+		})
+	default:
+		sendError(w, "方法不允许", http.StatusMethodNotAllowed)
+	}
+}
+
+// invalidConfigError 配置项单独有效，但与其他配置组合后无效
+type invalidConfigError struct {
+	err error
+}
+
+func (e *invalidConfigError) Error() string {
+	return "配置无效: " + e.err.Error()
+}
+
+// isCalendarConfig 是否为游戏日历相关配置
+func isCalendarConfig(key string) bool {
+	switch key {
+	case "game_timezone", "game_reset_hour", "redemption_period", "redemption_event_key":
+		return true
+	}
+	return false
+}
+
+// validateConfigValue 校验已知配置项的取值
+func validateConfigValue(key, value string) error {
+	switch key {
+	case "location_check_enabled":
+		if value != "true" && value != "false" {
+			return fmt.Errorf("location_check_enabled 只能是 true 或 false")
+		}
+	case "game_timezone":
+		if _, err := time.LoadLocation(value); err != nil {
+			return fmt.Errorf("无效的时区: %s", value)
+		}
+	case "game_reset_hour":
+		hour, err := strconv.Atoi(value)
+		if err != nil || hour < 0 || hour > 23 {
+			return fmt.Errorf("game_reset_hour 必须是 0~23 之间的整数")
+		}
+	case "redemption_period_limit":
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			return fmt.Errorf("redemption_period_limit 必须是非负整数（0 表示不限）")
+		}
+	case "voucher_valid_days":
+		days, err := strconv.Atoi(value)
+		if err != nil || days <= 0 {
+			return fmt.Errorf("voucher_valid_days 必须是正整数")
+		}
+	case "checkin_code_required":
+		if value != "true" && value != "false" {
+			return fmt.Errorf("checkin_code_required 只能是 true 或 false")
+		}
+	case "checkin_max_accuracy", "checkin_max_speed_kmh":
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("%s 必须是正整数", key)
+		}
+	case achievement.LocationTiersConfigKey:
+		if _, err := achievement.ParseLocationTiers(value); err != nil {
+			return fmt.Errorf("%s: %v", key, err)
+		}
+	case "redemption_period":
+		if !calendar.ValidPeriodKind(value) {
+			return fmt.Errorf("redemption_period 只能是 monthly、weekly 或 event")
+		}
+	}
+	return nil
+}
+
+// AdminDrawWeights 抽卡概率配置管理
+// GET 列出所有权重；PUT 修改（或新增）一项权重，下一次抽卡即生效
+// 保存前按整张表校验：只接受 gacha.LoadConfig 认识的配置项，值超出范围时返回 400
+func AdminDrawWeights(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		rows, err := database.DB.Query("SELECT category, key, weight, description, updated_at FROM draw_weights ORDER BY category, key")
+		if err != nil {
+			sendError(w, "查询失败", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		var weights []models.DrawWeight
+		for rows.Next() {
+			var dw models.DrawWeight
+			if err := rows.Scan(&dw.Category, &dw.Key, &dw.Weight, &dw.Description, &dw.UpdatedAt); err != nil {
+				continue
+			}
+			weights = append(weights, dw)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"weights": weights,
+		})
+	case http.MethodPut:
+		var req models.DrawWeight
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, "无效的请求数据", http.StatusBadRequest)
+			return
+		}
+		if req.Category == "" || req.Key == "" {
+			sendError(w, "类别和配置项不能为空", http.StatusBadRequest)
+			return
+		}
+
+		err := database.WithTx(func(tx *sql.Tx) error {
+			var oldWeight sql.NullFloat64
+			tx.QueryRow(
+				"SELECT weight FROM draw_weights WHERE category = $1 AND key = $2",
+				req.Category, req.Key,
+			).Scan(&oldWeight)
+
+			_, err := tx.Exec(
+				`INSERT INTO draw_weights (category, key, weight, description) VALUES ($1, $2, $3, $4)
+				 ON CONFLICT (category, key) DO UPDATE SET
+				 	weight = EXCLUDED.weight,
+				 	description = COALESCE(EXCLUDED.description, draw_weights.description),
+				 	updated_at = CURRENT_TIMESTAMP`,
+				req.Category, req.Key, req.Weight, req.Description,
+			)
+			if err != nil {
+				return err
+			}
+			// 按修改后的整张表加载一次抽卡配置，未知的配置项或超出范围的值回滚，避免抽卡时悄悄使用默认值
+			if _, err := gacha.LoadConfig(tx); err != nil {
+				return &invalidConfigError{err}
+			}
+			detail := map[string]interface{}{"new_weight": req.Weight}
+			if oldWeight.Valid {
+				detail["old_weight"] = oldWeight.Float64
+			}
+			return recordAudit(tx, r, "update", "draw_weight", req.Category+"/"+req.Key, detail)
+		})
+		var invalid *invalidConfigError
+		if errors.As(err, &invalid) {
+			sendError(w, invalid.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Printf("❌ 修改抽卡权重失败: %v", err)
+			sendError(w, "修改失败", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+		})
+	default:
+		sendError(w, "方法不允许", http.StatusMethodNotAllowed)
+	}
+}
+
+// AdminUsers 用户管理
+// GET /api/admin/users?q=关键字 查询用户；PUT /api/admin/users/{id}/role 修改角色
+func AdminUsers(w http.ResponseWriter, r *http.Request) {
+	id, rest, hasID, err := parseAdminPath(r.URL.Path, "/api/admin/users")
+	if err != nil {
+		sendError(w, "无效的用户ID", http.StatusBadRequest)
+		return
+	}
+
+	if !hasID {
+		if r.Method != http.MethodGet {
+			sendError(w, "方法不允许", http.StatusMethodNotAllowed)
+			return
+		}
+
+		keyword := "%" + strings.TrimSpace(r.URL.Query().Get("q")) + "%"
+		rows, err := database.DB.Query(
+			`SELECT id, username, holy_name, nickname, checkin_count, exchange_points, role, created_at
+			 FROM users
+			 WHERE username ILIKE $1 OR COALESCE(nickname, '') ILIKE $1 OR COALESCE(holy_name, '') ILIKE $1
+			 ORDER BY id LIMIT 100`,
+			keyword,
+		)
+		if err != nil {
+			sendError(w, "查询失败", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		var users []models.User
+		for rows.Next() {
+			var user models.User
+			err := rows.Scan(
+				&user.ID, &user.Username, &user.HolyName, &user.Nickname,
+				&user.CheckinCount, &user.ExchangePoints, &user.Role, &user.CreatedAt,
+			)
+			if err != nil {
+				continue
+			}
+			users = append(users, user)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"users": users,
+		})
+		return
+	}
+
+	if rest != "role" || r.Method != http.MethodPut {
+		sendError(w, "接口不存在", http.StatusNotFound)
+		return
+	}
+
+	var req models.UpdateRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, "无效的请求数据", http.StatusBadRequest)
+		return
+	}
+	if !auth.ValidRole(req.Role) {
+		sendError(w, "无效的角色，必须是 user、staff 或 admin", http.StatusBadRequest)
+		return
+	}
+
+	currentUserID, _ := auth.GetUserIDFromRequest(r)
+	if currentUserID == id && req.Role != auth.RoleAdmin {
+		sendError(w, "不能取消自己的管理员权限", http.StatusBadRequest)
+		return
+	}
+
+	err = database.WithTx(func(tx *sql.Tx) error {
+		var oldRole string
+		err := tx.QueryRow("SELECT role FROM users WHERE id = $1 FOR UPDATE", id).Scan(&oldRole)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE users SET role = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2", req.Role, id); err != nil {
+			return err
+		}
+		return recordAudit(tx, r, "update_role", "user", id, map[string]interface{}{
+			"old_role": oldRole,
+			"new_role": req.Role,
+		})
+	})
+	if err == sql.ErrNoRows {
+		sendError(w, "用户不存在", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("❌ 修改用户角色失败: %v", err)
+		sendError(w, "修改失败", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "角色已修改，立即生效",
+	})
+}
+
+// AdminAuditLogs 查询审计日志
+// GET /api/admin/audit-logs?target_type=card&limit=100
+func AdminAuditLogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendError(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	targetType := r.URL.Query().Get("target_type")
+
+	rows, err := database.DB.Query(
+		`SELECT id, user_id, username, action, target_type, target_id, detail, created_at
+		 FROM admin_audit_logs
+		 WHERE $1 = '' OR target_type = $1
+		 ORDER BY id DESC LIMIT $2`,
+		targetType, limit,
+	)
+	if err != nil {
+		sendError(w, "查询失败", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	var logs []models.AuditLog
+	for rows.Next() {
+		var entry models.AuditLog
+		var detail []byte
+		err := rows.Scan(
+			&entry.ID, &entry.UserID, &entry.Username, &entry.Action,
+			&entry.TargetType, &entry.TargetID, &detail, &entry.CreatedAt,
+		)
+		if err != nil {
+			continue
+		}
+		entry.Detail = detail
+		logs = append(logs, entry)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"logs": logs,
+	})
+}
+
+// AdminFeedbacks 反馈管理（工作人员和管理员可用）
+// GET /api/admin/feedbacks?status=pending 查询反馈；PUT /api/admin/feedbacks/{id} 修改处理状态
+func AdminFeedbacks(w http.ResponseWriter, r *http.Request) {
+	id, _, hasID, err := parseAdminPath(r.URL.Path, "/api/admin/feedbacks")
+	if err != nil {
+		sendError(w, "无效的反馈ID", http.StatusBadRequest)
+		return
+	}
+
+	if !hasID {
+		if r.Method != http.MethodGet {
+			sendError(w, "方法不允许", http.StatusMethodNotAllowed)
+			return
+		}
+
+		status := r.URL.Query().Get("status")
+		rows, err := database.DB.Query(
+			`SELECT id, user_id, content, type, status, created_at FROM feedbacks
+			 WHERE $1 = '' OR status = $1
+			 ORDER BY created_at DESC LIMIT 200`,
+			status,
+		)
+		if err != nil {
+			sendError(w, "查询失败", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		var feedbacks []models.Feedback
+		for rows.Next() {
+			var feedback models.Feedback
+			err := rows.Scan(
+				&feedback.ID, &feedback.UserID, &feedback.Content,
+				&feedback.Type, &feedback.Status, &feedback.CreatedAt,
+			)
+			if err != nil {
+				continue
+			}
+			feedbacks = append(feedbacks, feedback)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"feedbacks": feedbacks,
+			"count":     len(feedbacks),
+		})
+		return
+	}
+
+	if r.Method != http.MethodPut {
+		sendError(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.UpdateFeedbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, "无效的请求数据", http.StatusBadRequest)
+		return
+	}
+	if req.Status != "pending" && req.Status != "resolved" {
+		sendError(w, "状态只能是 pending 或 resolved", http.StatusBadRequest)
+		return
+	}
+
+	err = database.WithTx(func(tx *sql.Tx) error {
+		var oldStatus string
+		err := tx.QueryRow("SELECT status FROM feedbacks WHERE id = $1 FOR UPDATE", id).Scan(&oldStatus)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE feedbacks SET status = $1 WHERE id = $2", req.Status, id); err != nil {
+			return err
+		}
+		return recordAudit(tx, r, "update", "feedback", id, map[string]interface{}{
+			"old_status": oldStatus,
+			"new_status": req.Status,
+		})
+	})
+	if err == sql.ErrNoRows {
+		sendError(w, "反馈不存在", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("❌ 修改反馈状态失败: %v", err)
+		sendError(w, "修改失败", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+	})
+}
+
+// AdminAchievements 成就类型管理
+// GET 列出；POST 新增；PUT /{id} 修改；DELETE /{id} 删除
+// 成就的达成条件由 metric、threshold、repeatable、auto_claim、scope_type、scope_id 描述，新增成就不需要改代码
+func AdminAchievements(w http.ResponseWriter, r *http.Request) {
+	id, _, hasID, err := parseAdminPath(r.URL.Path, "/api/admin/achievements")
+	if err != nil {
+		sendError(w, "无效的成就ID", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case !hasID && r.Method == http.MethodGet:
+		achievementTypes, err := achievement.LoadTypes(database.DB)
+		if err != nil {
+			sendError(w, "查询失败", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"achievements": achievementTypes,
+		})
+	case !hasID && r.Method == http.MethodPost:
+		var req models.AchievementTypeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, "无效的请求数据", http.StatusBadRequest)
+			return
+		}
+		req.Code = strings.TrimSpace(req.Code)
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Code == "" || req.Name == "" {
+			sendError(w, "成就代码和名称不能为空", http.StatusBadRequest)
+			return
+		}
+		if req.RewardPoints < 0 {
+			sendError(w, "奖励点数不能为负数", http.StatusBadRequest)
+			return
+		}
+		if err := validateAchievementRule(&req); err != nil {
+			sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var newID int
+		err := database.WithTx(func(tx *sql.Tx) error {
+			err := tx.QueryRow(
+				`INSERT INTO achievement_types
+				 (code, name, description, reward_points, metric, threshold, repeatable, auto_claim, scope_type, scope_id)
+				 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`,
+				req.Code, req.Name, req.Description, req.RewardPoints,
+				req.Metric, req.Threshold, req.Repeatable, req.AutoClaim, req.ScopeType, req.ScopeID,
+			).Scan(&newID)
+			if err != nil {
+				return err
+			}
+			if err := achievement.RecomputeType(tx, calendar.Default().Now(), newID); err != nil {
+				return err
+			}
+			return recordAudit(tx, r, "create", "achievement", newID, req)
+		})
+		if isUniqueViolation(err) {
+			sendError(w, "成就代码已存在", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("❌ 新增成就失败: %v", err)
+			sendError(w, "新增失败", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"id":      newID,
+		})
+	case hasID && r.Method == http.MethodPut:
+		var req models.AchievementTypeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, "无效的请求数据", http.StatusBadRequest)
+			return
+		}
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" {
+			sendError(w, "成就名称不能为空", http.StatusBadRequest)
+			return
+		}
+		if req.RewardPoints < 0 {
+			sendError(w, "奖励点数不能为负数", http.StatusBadRequest)
+			return
+		}
+		if err := validateAchievementRule(&req); err != nil {
+			sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// 成就代码被地点（achievement_code）和前端引用，不允许修改
+		err := database.WithTx(func(tx *sql.Tx) error {
+			result, err := tx.Exec(
+				`UPDATE achievement_types
+				 SET name = $1, description = $2, reward_points = $3,
+				     metric = $4, threshold = $5, repeatable = $6, auto_claim = $7, scope_type = $8, scope_id = $9
+				 WHERE id = $10`,
+				req.Name, req.Description, req.RewardPoints,
+				req.Metric, req.Threshold, req.Repeatable, req.AutoClaim, req.ScopeType, req.ScopeID, id,
+			)
+			if err != nil {
+				return err
+			}
+			if affected, _ := result.RowsAffected(); affected == 0 {
+				return sql.ErrNoRows
+			}
+			if err := achievement.RecomputeType(tx, calendar.Default().Now(), id); err != nil {
+				return err
+			}
+			return recordAudit(tx, r, "update", "achievement", id, req)
+		})
+		if err == sql.ErrNoRows {
+			sendError(w, "成就不存在", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("❌ 修改成就失败: %v", err)
+			sendError(w, "修改失败", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+		})
+	case hasID && r.Method == http.MethodDelete:
+		err := database.WithTx(func(tx *sql.Tx) error {
+			var code string
+			if err := tx.QueryRow("SELECT code FROM achievement_types WHERE id = $1", id).Scan(&code); err != nil {
+				return err
+			}
+
+			// 已有用户解锁的成就不允许删除（会级联删除用户的成就记录）
+			var unlockedCount int
+			tx.QueryRow("SELECT COUNT(*) FROM user_achievements WHERE achievement_type_id = $1", id).Scan(&unlockedCount)
+			if unlockedCount > 0 {
+				return errAchievementInUse
+			}
+
+			if _, err := tx.Exec("DELETE FROM achievement_types WHERE id = $1", id); err != nil {
+				return err
+			}
+			return recordAudit(tx, r, "delete", "achievement", id, map[string]interface{}{"code": code})
+		})
+		if err == sql.ErrNoRows {
+			sendError(w, "成就不存在", http.StatusNotFound)
+			return
+		}
+		if err == errAchievementInUse {
+			sendError(w, "已有用户解锁该成就，不能删除", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("❌ 删除成就失败: %v", err)
+			sendError(w, "删除失败", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+		})
+	default:
+		sendError(w, "方法不允许", http.StatusMethodNotAllowed)
+	}
+}
+
+var errAchievementInUse = fmt.Errorf("成就已被用户解锁")
+
+// validateAchievementRule 规范化并校验成就规则
+func validateAchievementRule(req *models.AchievementTypeRequest) error {
+	req.Metric = strings.TrimSpace(req.Metric)
+	req.ScopeType = strings.TrimSpace(req.ScopeType)
+	return achievement.Validate(models.AchievementType{
+		Code:       req.Code,
+		Metric:     req.Metric,
+		Threshold:  req.Threshold,
+		Repeatable: req.Repeatable,
+		AutoClaim:  req.AutoClaim,
+		ScopeType:  req.ScopeType,
+		ScopeID:    req.ScopeID,
+	})
+}
+
+// isUniqueViolation 是否为唯一约束冲突
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
@@ -0,0 +1,438 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"h5project/achievement"
+	"h5project/calendar"
+	"h5project/database"
+	"h5project/models"
+)
+
+// maxCardRarityLength cards.rarity 列的长度
+const maxCardRarityLength = 20
+
+// errInvalidAvailability 上架时间窗口不合法
+var errInvalidAvailability = errors.New("上架时间必须早于下架时间")
+
+// validateAvailability 校验上架时间窗口，两端都设置时开始时间必须早于结束时间
+func validateAvailability(from, until *time.Time) error {
+	if from != nil && until != nil && !from.Before(*until) {
+		return errInvalidAvailability
+	}
+	return nil
+}
+
+// parseAvailabilityField 解析表单中的可选时间字段（RFC3339 格式，如 2025-10-12T00:00:00+08:00）
+func parseAvailabilityField(r *http.Request, field string) (*time.Time, error) {
+	value := strings.TrimSpace(r.FormValue(field))
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("%s 格式错误，应为 RFC3339 时间", field)
+	}
+	return &t, nil
+}
+
+// AdminCards 卡片管理
+// GET 列出所有卡片（含已下架）；POST 上传图片并创建卡片；
+// PUT /{id} 修改卡片信息；PUT /{id}/image 替换图片；POST /{id}/retire、/{id}/restore 下架或恢复
+func AdminCards(w http.ResponseWriter, r *http.Request) {
+	id, rest, hasID, err := parseAdminPath(r.URL.Path, "/api/admin/cards")
+	if err != nil {
+		sendError(w, "无效的卡片ID", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case !hasID && r.Method == http.MethodGet:
+		listAdminCards(w)
+	case !hasID && r.Method == http.MethodPost:
+		createCard(w, r)
+	case hasID && rest == "" && r.Method == http.MethodPut:
+		updateCard(w, r, id)
+	case hasID && rest == "image" && r.Method == http.MethodPut:
+		replaceCardImage(w, r, id)
+	case hasID && rest == "retire" && r.Method == http.MethodPost:
+		setCardRetired(w, r, id, true)
+	case hasID && rest == "restore" && r.Method == http.MethodPost:
+		setCardRetired(w, r, id, false)
+	default:
+		sendError(w, "方法不允许", http.StatusMethodNotAllowed)
+	}
+}
+
+// AdminCardOrder 调整卡片展示顺序
+// PUT {"card_ids": [3, 1, 2]}，按数组顺序重新设置 sort_order，未列出的卡片保持不变
+func AdminCardOrder(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		sendError(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.ReorderCardsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.CardIDs) == 0 {
+		sendError(w, "无效的请求数据", http.StatusBadRequest)
+		return
+	}
+	seen := make(map[int]bool)
+	for _, cardID := range req.CardIDs {
+		if seen[cardID] {
+			sendError(w, "卡片ID重复", http.StatusBadRequest)
+			return
+		}
+		seen[cardID] = true
+	}
+
+	err := database.WithTx(func(tx *sql.Tx) error {
+		for i, cardID := range req.CardIDs {
+			result, err := tx.Exec("UPDATE cards SET sort_order = $1 WHERE id = $2", i+1, cardID)
+			if err != nil {
+				return err
+			}
+			if affected, _ := result.RowsAffected(); affected == 0 {
+				return fmt.Errorf("卡片 %d: %w", cardID, sql.ErrNoRows)
+			}
+		}
+		return recordAudit(tx, r, "reorder", "card", "", req)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		sendError(w, "卡片不存在", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("❌ 调整卡片顺序失败: %v", err)
+		sendError(w, "调整失败", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+	})
+}
+
+// listAdminCards 列出所有卡片，包括已下架的
+func listAdminCards(w http.ResponseWriter) {
+	rows, err := database.DB.Query("SELECT " + cardColumns + " FROM cards ORDER BY sort_order, id")
+	if err != nil {
+		sendError(w, "查询失败", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	var cards []models.Card
+	for rows.Next() {
+		card, err := scanCard(rows)
+		if err != nil {
+			continue
+		}
+		cards = append(cards, card)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"cards": cards,
+		"count": len(cards),
+	})
+}
+
+// createCard 上传图片并创建卡片
+// multipart/form-data：name、rarity（默认 common）、description、image（PNG/JPEG），
+// 可选 series_id 指定所属系列，available_from、available_until 设置限时上架窗口
+// 图片先写入 images/，卡片记录和审计日志在同一事务中写入，事务失败时删除已保存的图片
+func createCard(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxCardImageBytes+1<<20)
+	if err := r.ParseMultipartForm(maxCardImageBytes); err != nil {
+		sendError(w, errInvalidCardImage.Error(), http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	rarity := strings.TrimSpace(r.FormValue("rarity"))
+	if name == "" {
+		sendError(w, "卡片名称不能为空", http.StatusBadRequest)
+		return
+	}
+	if rarity == "" {
+		rarity = "common"
+	}
+	if len(rarity) > maxCardRarityLength {
+		sendError(w, "稀有度过长", http.StatusBadRequest)
+		return
+	}
+	var description *string
+	if d := strings.TrimSpace(r.FormValue("description")); d != "" {
+		description = &d
+	}
+
+	var seriesID *int
+	if v := strings.TrimSpace(r.FormValue("series_id")); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			sendError(w, "无效的系列ID", http.StatusBadRequest)
+			return
+		}
+		seriesID = &id
+	}
+
+	availableFrom, err := parseAvailabilityField(r, "available_from")
+	if err != nil {
+		sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	availableUntil, err := parseAvailabilityField(r, "available_until")
+	if err != nil {
+		sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateAvailability(availableFrom, availableUntil); err != nil {
+		sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	img, err := readCardImage(r)
+	if err != nil {
+		sendError(w, errInvalidCardImage.Error(), http.StatusBadRequest)
+		return
+	}
+
+	imageURL, path, err := saveCardImage(img)
+	if err != nil {
+		log.Printf("❌ 保存卡片图片失败: %v", err)
+		sendError(w, "保存图片失败", http.StatusInternalServerError)
+		return
+	}
+
+	var card models.Card
+	err = database.WithTx(func(tx *sql.Tx) error {
+		if err := ensureSeriesExists(tx, seriesID); err != nil {
+			return err
+		}
+
+		var cardID int
+		err := tx.QueryRow(
+			`INSERT INTO cards (name, image_url, rarity, description, series_id, available_from, available_until, sort_order)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, (SELECT COALESCE(MAX(sort_order), 0) + 1 FROM cards))
+			 RETURNING id`,
+			name, imageURL, rarity, description, seriesID, availableFrom, availableUntil,
+		).Scan(&cardID)
+		if err != nil {
+			return err
+		}
+		card, err = getCardByID(tx, cardID)
+		if err != nil {
+			return err
+		}
+		if err := achievement.RecomputeMetrics(tx, calendar.Default().Now(), achievement.CardCatalogMetrics...); err != nil {
+			return err
+		}
+		return recordAudit(tx, r, "create", "card", cardID, card)
+	})
+	if err != nil {
+		os.Remove(path)
+		if err == errSeriesNotFound {
+			sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("❌ 创建卡片失败: %v", err)
+		sendError(w, "创建失败", http.StatusInternalServerError)
+		return
+	}
+
+	if err := refreshImageList(); err != nil {
+		log.Printf("⚠️  更新 list.json 失败: %v", err)
+	}
+	log.Printf("🃏 新卡片已创建: id=%d, name=%s, image=%s", card.ID, card.Name, card.ImageURL)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"card":    card,
+	})
+}
+
+// updateCard 修改卡片信息（包括所属系列和上架时间窗口，未传的字段表示不属于系列、不限制时间）
+func updateCard(w http.ResponseWriter, r *http.Request, id int) {
+	var req models.UpdateCardRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, "无效的请求数据", http.StatusBadRequest)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	req.ImageURL = strings.TrimSpace(req.ImageURL)
+	req.Rarity = strings.TrimSpace(req.Rarity)
+	if req.Name == "" || req.ImageURL == "" {
+		sendError(w, "卡片名称和图片地址不能为空", http.StatusBadRequest)
+		return
+	}
+	if req.Rarity == "" {
+		req.Rarity = "common"
+	}
+	if len(req.Rarity) > maxCardRarityLength {
+		sendError(w, "稀有度过长", http.StatusBadRequest)
+		return
+	}
+	if err := validateAvailability(req.AvailableFrom, req.AvailableUntil); err != nil {
+		sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := database.WithTx(func(tx *sql.Tx) error {
+		old, err := getCardByID(tx, id)
+		if err != nil {
+			return err
+		}
+		if err := ensureSeriesExists(tx, req.SeriesID); err != nil {
+			return err
+		}
+		_, err = tx.Exec(
+			`UPDATE cards SET name = $1, image_url = $2, rarity = $3, description = $4,
+			 series_id = $5, available_from = $6, available_until = $7 WHERE id = $8`,
+			req.Name, req.ImageURL, req.Rarity, req.Description,
+			req.SeriesID, req.AvailableFrom, req.AvailableUntil, id,
+		)
+		if err != nil {
+			return err
+		}
+		// 卡片移出原系列或修改上架时间后，可能有用户集齐了原系列或新系列（在重新计算成就进度之前发放）
+		if _, err := grantSeriesCompletions(tx, old.SeriesID, req.SeriesID); err != nil {
+			return err
+		}
+		if err := achievement.RecomputeMetrics(tx, calendar.Default().Now(), achievement.CardCatalogMetrics...); err != nil {
+			return err
+		}
+		return recordAudit(tx, r, "update", "card", id, map[string]interface{}{
+			"old": old,
+			"new": req,
+		})
+	})
+	if err == sql.ErrNoRows {
+		sendError(w, "卡片不存在", http.StatusNotFound)
+		return
+	}
+	if err == errSeriesNotFound {
+		sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("❌ 修改卡片失败: %v", err)
+		sendError(w, "修改失败", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+	})
+}
+
+// replaceCardImage 替换卡片图片（multipart/form-data，字段 image）
+// 新图片保存为新文件，旧文件保留在磁盘上，已打开的页面和用户缓存不会失效
+func replaceCardImage(w http.ResponseWriter, r *http.Request, id int) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxCardImageBytes+1<<20)
+	if err := r.ParseMultipartForm(maxCardImageBytes); err != nil {
+		sendError(w, errInvalidCardImage.Error(), http.StatusBadRequest)
+		return
+	}
+
+	img, err := readCardImage(r)
+	if err != nil {
+		sendError(w, errInvalidCardImage.Error(), http.StatusBadRequest)
+		return
+	}
+
+	imageURL, path, err := saveCardImage(img)
+	if err != nil {
+		log.Printf("❌ 保存卡片图片失败: %v", err)
+		sendError(w, "保存图片失败", http.StatusInternalServerError)
+		return
+	}
+
+	err = database.WithTx(func(tx *sql.Tx) error {
+		old, err := getCardByID(tx, id)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("UPDATE cards SET image_url = $1 WHERE id = $2", imageURL, id)
+		if err != nil {
+			return err
+		}
+		return recordAudit(tx, r, "update_image", "card", id, map[string]interface{}{
+			"old": old.ImageURL,
+			"new": imageURL,
+		})
+	})
+	if err != nil {
+		os.Remove(path)
+		if err == sql.ErrNoRows {
+			sendError(w, "卡片不存在", http.StatusNotFound)
+			return
+		}
+		log.Printf("❌ 替换卡片图片失败: %v", err)
+		sendError(w, "替换失败", http.StatusInternalServerError)
+		return
+	}
+
+	if err := refreshImageList(); err != nil {
+		log.Printf("⚠️  更新 list.json 失败: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":   true,
+		"image_url": imageURL,
+	})
+}
+
+// setCardRetired 下架或恢复卡片
+// 下架代替删除：删除卡片会级联删除用户卡包中的记录，下架的卡片不再进入卡池，已拥有的用户仍可在卡包中查看
+func setCardRetired(w http.ResponseWriter, r *http.Request, id int, retired bool) {
+	action := "restore"
+	if retired {
+		action = "retire"
+	}
+
+	err := database.WithTx(func(tx *sql.Tx) error {
+		var seriesID *int
+		err := tx.QueryRow("UPDATE cards SET retired = $1 WHERE id = $2 RETURNING series_id", retired, id).Scan(&seriesID)
+		if err != nil {
+			return err
+		}
+		// 下架卡片后，已拥有该系列其余卡片的用户集齐了该系列
+		if _, err := grantSeriesCompletions(tx, seriesID); err != nil {
+			return err
+		}
+		if err := achievement.RecomputeMetrics(tx, calendar.Default().Now(), achievement.CardCatalogMetrics...); err != nil {
+			return err
+		}
+		return recordAudit(tx, r, action, "card", id, nil)
+	})
+	if err == sql.ErrNoRows {
+		sendError(w, "卡片不存在", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("❌ 修改卡片状态失败: %v", err)
+		sendError(w, "修改失败", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"retired": retired,
+	})
+}
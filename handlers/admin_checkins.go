@@ -0,0 +1,346 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"h5project/auth"
+	"h5project/database"
+	"h5project/events"
+	"h5project/models"
+	"h5project/presence"
+
+	"github.com/lib/pq"
+)
+
+// errCheckinReviewed 签到已经审核过（或不需要审核）
+var errCheckinReviewed = errors.New("该签到不在待审核状态")
+
+// AdminCheckins 可疑签到审核（工作人员和管理员可用）
+// GET /api/admin/checkins?status=flagged 查询签到（默认待审核）；
+// POST /api/admin/checkins/{id}/approve 审核通过，计入抽卡资格和成就；POST /api/admin/checkins/{id}/reject 驳回
+func AdminCheckins(w http.ResponseWriter, r *http.Request) {
+	id, action, hasID, err := parseAdminPath(r.URL.Path, "/api/admin/checkins")
+	if err != nil {
+		sendError(w, "无效的签到ID", http.StatusBadRequest)
+		return
+	}
+
+	if !hasID {
+		if r.Method != http.MethodGet {
+			sendError(w, "方法不允许", http.StatusMethodNotAllowed)
+			return
+		}
+		listCheckins(w, r)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		sendError(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+	var status string
+	switch action {
+	case "approve":
+		status = models.CheckinApproved
+	case "reject":
+		status = models.CheckinRejected
+	default:
+		sendError(w, "未知操作", http.StatusNotFound)
+		return
+	}
+
+	var req models.CheckinReviewRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, "无效的请求数据", http.StatusBadRequest)
+			return
+		}
+	}
+	req.Note = strings.TrimSpace(req.Note)
+	if len([]rune(req.Note)) > 200 {
+		sendError(w, "备注不能超过 200 字", http.StatusBadRequest)
+		return
+	}
+
+	reviewerID, err := auth.GetUserIDFromRequest(r)
+	if err != nil {
+		sendError(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	err = database.WithTx(func(tx *sql.Tx) error {
+		var userID int
+		err := tx.QueryRow(
+			`UPDATE location_checkins
+			 SET status = $1, reviewed_by = $2, reviewed_at = CURRENT_TIMESTAMP, review_note = NULLIF($3, '')
+			 WHERE id = $4 AND status = $5
+			 RETURNING user_id`,
+			status, reviewerID, req.Note, id, models.CheckinFlagged,
+		).Scan(&userID)
+		if err == sql.ErrNoRows {
+			var exists bool
+			if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM location_checkins WHERE id = $1)", id).Scan(&exists); err != nil {
+				return err
+			}
+			if exists {
+				return errCheckinReviewed
+			}
+			return sql.ErrNoRows
+		}
+		if err != nil {
+			return err
+		}
+
+		// 审核通过的签到计入地点成就
+		if status == models.CheckinApproved {
+			if _, err := publishEvents(tx, userID, events.CheckinRecorded); err != nil {
+				return err
+			}
+		}
+		return recordAudit(tx, r, action, "location_checkin", id, req)
+	})
+	if err == sql.ErrNoRows {
+		sendError(w, "签到记录不存在", http.StatusNotFound)
+		return
+	}
+	if err == errCheckinReviewed {
+		sendError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("❌ 审核签到 %d 失败: %v", id, err)
+		sendError(w, "审核失败", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"status":  status,
+	})
+}
+
+// listCheckins 按审核状态查询签到，最新的在前
+func listCheckins(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = models.CheckinFlagged
+	}
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > 500 {
+		limit = 100
+	}
+
+	rows, err := database.DB.Query(
+		`SELECT lc.id, lc.user_id, u.username, lc.location_id, cl.name, to_char(lc.checkin_date, 'YYYY-MM-DD'),
+		        lc.latitude, lc.longitude, lc.accuracy_meters, lc.status, lc.flags, lc.created_at, lc.reviewed_at
+		 FROM location_checkins lc
+		 JOIN users u ON u.id = lc.user_id
+		 JOIN checkin_locations cl ON cl.id = lc.location_id
+		 WHERE lc.status = $1
+		 ORDER BY lc.created_at DESC, lc.id DESC
+		 LIMIT $2`,
+		status, limit,
+	)
+	if err != nil {
+		sendError(w, "查询失败", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	checkins := []models.FlaggedCheckin{}
+	for rows.Next() {
+		var c models.FlaggedCheckin
+		var flags pq.StringArray
+		err := rows.Scan(
+			&c.ID, &c.UserID, &c.Username, &c.LocationID, &c.LocationName, &c.CheckinDate,
+			&c.Latitude, &c.Longitude, &c.Accuracy, &c.Status, &flags, &c.CreatedAt, &c.ReviewedAt,
+		)
+		if err != nil {
+			continue
+		}
+		c.Flags = flags
+		c.Reasons = make([]string, len(flags))
+		for i, flag := range flags {
+			c.Reasons[i] = checkinFlagText(flag)
+		}
+		checkins = append(checkins, c)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"checkins": checkins,
+	})
+}
+
+// checkinQRSize 现场签到二维码图片的边长（像素），在屏幕上展示，比兑换券二维码大
+const checkinQRSize = 512
+
+// errDisplayKey 展示密钥无效或已重新生成
+var errDisplayKey = errors.New("展示密钥无效或已失效，请工作人员重新生成展示链接")
+
+// AdminCheckinCodes 打卡地点当前的现场签到凭证（工作人员和管理员可用）
+// GET /api/admin/checkin-codes/{id} 返回签到码和二维码凭证；GET /api/admin/checkin-codes/{id}/qr 返回二维码 PNG 图片；
+// POST /api/admin/checkin-codes/{id}/display-key 生成该地点的展示密钥（旧密钥失效），返回现场屏幕使用的展示页面地址
+func AdminCheckinCodes(w http.ResponseWriter, r *http.Request) {
+	id, rest, hasID, err := parseAdminPath(r.URL.Path, "/api/admin/checkin-codes")
+	if err != nil || !hasID {
+		sendError(w, "无效的地点ID", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case rest == "display-key" && r.Method == http.MethodPost:
+		rotateDisplayKey(w, r, id)
+	case (rest == "" || rest == "qr") && r.Method == http.MethodGet:
+		var name string
+		err := database.DB.QueryRow("SELECT name FROM checkin_locations WHERE id = $1", id).Scan(&name)
+		if err == sql.ErrNoRows {
+			sendError(w, "地点不存在", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			sendError(w, "查询失败", http.StatusInternalServerError)
+			return
+		}
+		writeCheckinCodes(w, r, id, name, rest == "qr", fmt.Sprintf("/api/admin/checkin-codes/%d/qr", id))
+	case rest == "" || rest == "qr" || rest == "display-key":
+		sendError(w, "方法不允许", http.StatusMethodNotAllowed)
+	default:
+		sendError(w, "未知操作", http.StatusNotFound)
+	}
+}
+
+// CheckinDisplay 教堂现场屏幕获取签到码和二维码（不需要登录，凭展示密钥访问）
+// GET /api/checkin-display/{id} 返回签到码和二维码凭证；GET /api/checkin-display/{id}/qr 返回二维码 PNG 图片
+// 展示密钥通过 X-Display-Key 请求头提交，只能读取该地点的签到码
+func CheckinDisplay(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendError(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+	id, rest, hasID, err := parseAdminPath(r.URL.Path, "/api/checkin-display")
+	if err != nil || !hasID || (rest != "" && rest != "qr") {
+		sendError(w, "无效的地点ID", http.StatusBadRequest)
+		return
+	}
+
+	key := r.Header.Get("X-Display-Key")
+	if strings.TrimSpace(key) == "" {
+		sendError(w, errDisplayKey.Error(), http.StatusUnauthorized)
+		return
+	}
+	var name string
+	err = database.DB.QueryRow(
+		"SELECT name FROM checkin_locations WHERE id = $1 AND display_key_hash = $2",
+		id, presence.HashDisplayKey(key),
+	).Scan(&name)
+	if err == sql.ErrNoRows {
+		sendError(w, errDisplayKey.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		sendError(w, "查询失败", http.StatusInternalServerError)
+		return
+	}
+	writeCheckinCodes(w, r, id, name, rest == "qr", fmt.Sprintf("/api/checkin-display/%d/qr", id))
+}
+
+// rotateDisplayKey 生成地点新的展示密钥，旧密钥立即失效；密钥只在生成时返回一次
+func rotateDisplayKey(w http.ResponseWriter, r *http.Request, id int) {
+	key, hash, err := presence.NewDisplayKey()
+	if err != nil {
+		log.Printf("❌ %v", err)
+		sendError(w, "生成展示密钥失败", http.StatusInternalServerError)
+		return
+	}
+
+	err = database.WithTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec("UPDATE checkin_locations SET display_key_hash = $1 WHERE id = $2", hash, id)
+		if err != nil {
+			return err
+		}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			return sql.ErrNoRows
+		}
+		return recordAudit(tx, r, "rotate_display_key", "location", id, nil)
+	})
+	if err == sql.ErrNoRows {
+		sendError(w, "地点不存在", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("❌ 生成地点 %d 的展示密钥失败: %v", id, err)
+		sendError(w, "生成展示密钥失败", http.StatusInternalServerError)
+		return
+	}
+
+	// 密钥放在 # 之后，不会随页面请求发送到服务器，不会出现在访问日志中
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":     true,
+		"display_key": key,
+		"display_url": fmt.Sprintf("%s/checkin-display.html?location=%d#key=%s", requestOrigin(r), id, key),
+	})
+}
+
+// writeCheckinCodes 返回地点当前的签到码和二维码凭证，qr 为 true 时返回二维码 PNG 图片
+// 二维码内容为带凭证的首页地址，用户扫码打开后自动签到；签到码和二维码都会轮换，过期后重新获取
+func writeCheckinCodes(w http.ResponseWriter, r *http.Request, id int, name string, qr bool, qrURL string) {
+	now := time.Now()
+	content := checkinQRContent(r, presence.Token(id, now))
+	w.Header().Set("Cache-Control", "no-store")
+
+	if qr {
+		png, err := presence.QRCode(content, checkinQRSize)
+		if err != nil {
+			log.Printf("❌ 生成签到二维码失败: %v", err)
+			sendError(w, "生成二维码失败", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(png)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"location_id":       id,
+		"location_name":     name,
+		"code":              presence.Code(id, now),
+		"expires_at":        presence.CodeExpiresAt(now),
+		"period_seconds":    int(presence.CodePeriod / time.Second),
+		"qr_content":        content,
+		"qr_url":            qrURL,
+		"qr_expires_at":     presence.TokenExpiresAt(now),
+		"qr_period_seconds": int(presence.TokenPeriod / time.Second),
+	})
+}
+
+// checkinQRContent 现场签到二维码的内容：带凭证的首页地址
+func checkinQRContent(r *http.Request, token string) string {
+	return fmt.Sprintf("%s/index.html?checkin=%s", requestOrigin(r), url.QueryEscape(token))
+}
+
+// requestOrigin 按请求的域名生成站点地址（scheme://host）
+// 经过反向代理时使用 X-Forwarded-Proto 判断协议
+func requestOrigin(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto == "https" || proto == "http" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host
+}
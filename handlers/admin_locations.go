@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"h5project/achievement"
+	"h5project/calendar"
+	"h5project/database"
+	"h5project/models"
+)
+
+var (
+	// errLocationNameTaken 地点名称与其他地点重复（不区分大小写）
+	errLocationNameTaken = errors.New("地点名称已存在")
+	// errLocationAchievement 指定的成就不存在或不是地点打卡成就
+	errLocationAchievement = errors.New("关联的成就不存在或不是地点打卡成就")
+)
+
+// AdminLocations 打卡地点管理
+// GET 列出；GET /{id} 查询；POST 新增（按分级配置自动创建地点成就）；
+// PUT /{id} 修改（边界和开放时段整体替换）；DELETE /{id} 删除
+func AdminLocations(w http.ResponseWriter, r *http.Request) {
+	id, _, hasID, err := parseAdminPath(r.URL.Path, "/api/admin/locations")
+	if err != nil {
+		sendError(w, "无效的地点ID", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case !hasID && r.Method == http.MethodGet:
+		GetCheckinLocations(w, r)
+	case hasID && r.Method == http.MethodGet:
+		locations, err := loadCheckinLocations(database.DB, id)
+		if err != nil {
+			sendError(w, "查询失败", http.StatusInternalServerError)
+			return
+		}
+		if len(locations) == 0 {
+			sendError(w, "地点不存在", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"location": locations[0],
+		})
+	case !hasID && r.Method == http.MethodPost:
+		var req models.LocationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, "无效的请求数据", http.StatusBadRequest)
+			return
+		}
+		boundary, err := validateLocationRequest(&req)
+		if err != nil {
+			sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var newID int
+		err = database.WithTx(func(tx *sql.Tx) error {
+			if err := checkLocationFields(tx, 0, req); err != nil {
+				return err
+			}
+			err := tx.QueryRow(
+				`INSERT INTO checkin_locations (name, latitude, longitude, radius_meters, achievement_code, boundary)
+				 VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+				req.Name, req.Latitude, req.Longitude, req.RadiusMeters, req.AchievementCode, nullableJSON(boundary),
+			).Scan(&newID)
+			if err != nil {
+				return err
+			}
+			// 按分级配置生成该地点的成就（通过 scope_id 关联）
+			if _, err := achievement.EnsureLocationAchievements(tx, newID, req.Name); err != nil {
+				return err
+			}
+			if err := saveLocationSchedules(tx, newID, req.Schedules); err != nil {
+				return err
+			}
+			if err := achievement.RecomputeMetrics(tx, calendar.Default().Now(), achievement.LocationMetrics...); err != nil {
+				return err
+			}
+			return recordAudit(tx, r, "create", "location", newID, req)
+		})
+		if err == errLocationNameTaken || isUniqueViolation(err) {
+			sendError(w, errLocationNameTaken.Error(), http.StatusConflict)
+			return
+		}
+		if err == errLocationAchievement {
+			sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Printf("❌ 新增打卡地点失败: %v", err)
+			sendError(w, "新增失败", http.StatusInternalServerError)
+			return
+		}
+		invalidateLocationIndex()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":  true,
+			"id":       newID,
+			"location": loadLocationForResponse(newID),
+		})
+	case hasID && r.Method == http.MethodPut:
+		var req models.LocationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, "无效的请求数据", http.StatusBadRequest)
+			return
+		}
+		boundary, err := validateLocationRequest(&req)
+		if err != nil {
+			sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = database.WithTx(func(tx *sql.Tx) error {
+			if err := checkLocationFields(tx, id, req); err != nil {
+				return err
+			}
+			// 未指定 achievement_code 时保留原来关联的成就
+			result, err := tx.Exec(
+				`UPDATE checkin_locations
+				 SET name = $1, latitude = $2, longitude = $3, radius_meters = $4,
+				     achievement_code = COALESCE($5, achievement_code), boundary = $6
+				 WHERE id = $7`,
+				req.Name, req.Latitude, req.Longitude, req.RadiusMeters, req.AchievementCode, nullableJSON(boundary), id,
+			)
+			if err != nil {
+				return err
+			}
+			if affected, _ := result.RowsAffected(); affected == 0 {
+				return sql.ErrNoRows
+			}
+			if err := saveLocationSchedules(tx, id, req.Schedules); err != nil {
+				return err
+			}
+			if err := achievement.RecomputeMetrics(tx, calendar.Default().Now(), achievement.LocationMetrics...); err != nil {
+				return err
+			}
+			return recordAudit(tx, r, "update", "location", id, req)
+		})
+		if err == sql.ErrNoRows {
+			sendError(w, "地点不存在", http.StatusNotFound)
+			return
+		}
+		if err == errLocationNameTaken || isUniqueViolation(err) {
+			sendError(w, errLocationNameTaken.Error(), http.StatusConflict)
+			return
+		}
+		if err == errLocationAchievement {
+			sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Printf("❌ 修改打卡地点失败: %v", err)
+			sendError(w, "修改失败", http.StatusInternalServerError)
+			return
+		}
+		invalidateLocationIndex()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":  true,
+			"location": loadLocationForResponse(id),
+		})
+	case hasID && r.Method == http.MethodDelete:
+		// 注意：删除地点会同时删除所有相关的打卡记录；地点成就保留，已解锁的用户不受影响
+		err := database.WithTx(func(tx *sql.Tx) error {
+			var name string
+			if err := tx.QueryRow("SELECT name FROM checkin_locations WHERE id = $1", id).Scan(&name); err != nil {
+				return err
+			}
+			if _, err := tx.Exec("DELETE FROM checkin_locations WHERE id = $1", id); err != nil {
+				return err
+			}
+			if err := achievement.RecomputeMetrics(tx, calendar.Default().Now(), achievement.LocationMetrics...); err != nil {
+				return err
+			}
+			return recordAudit(tx, r, "delete", "location", id, map[string]interface{}{"name": name})
+		})
+		if err == sql.ErrNoRows {
+			sendError(w, "地点不存在", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("❌ 删除打卡地点失败: %v", err)
+			sendError(w, "删除失败", http.StatusInternalServerError)
+			return
+		}
+		invalidateLocationIndex()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+		})
+	default:
+		sendError(w, "方法不允许", http.StatusMethodNotAllowed)
+	}
+}
+
+// AdminLocationCheck 位置校验开关（替代 scripts/toggle_location.sh）
+// GET 查询；POST {"enabled": true/false} 设置，不带 enabled 时切换当前状态
+func AdminLocationCheck(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		GetLocationSetting(w, r)
+	case http.MethodPost:
+		var req models.LocationCheckToggleRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				sendError(w, "无效的请求数据", http.StatusBadRequest)
+				return
+			}
+		}
+
+		var enabled bool
+		err := database.WithTx(func(tx *sql.Tx) error {
+			// 锁定配置行，并发切换时按顺序执行
+			var current string
+			err := tx.QueryRow("SELECT value FROM system_config WHERE key = 'location_check_enabled' FOR UPDATE").Scan(&current)
+			if err != nil && err != sql.ErrNoRows {
+				return err
+			}
+			enabled = current != "true"
+			if req.Enabled != nil {
+				enabled = *req.Enabled
+			}
+
+			_, err = tx.Exec(
+				`INSERT INTO system_config (key, value, description, updated_at)
+				 VALUES ('location_check_enabled', $1, '是否启用位置校验（true/false）', CURRENT_TIMESTAMP)
+				 ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_at = CURRENT_TIMESTAMP`,
+				fmt.Sprint(enabled),
+			)
+			if err != nil {
+				return err
+			}
+			return recordAudit(tx, r, "update", "config", "location_check_enabled", map[string]interface{}{
+				"from": current,
+				"to":   enabled,
+			})
+		})
+		if err != nil {
+			log.Printf("❌ 切换位置校验失败: %v", err)
+			sendError(w, "切换失败", http.StatusInternalServerError)
+			return
+		}
+
+		message := "位置校验已关闭，不在打卡地点也可以抽卡"
+		if enabled {
+			message = "位置校验已开启，需要先在打卡地点签到才能抽卡"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"enabled": enabled,
+			"message": message,
+		})
+	default:
+		sendError(w, "方法不允许", http.StatusMethodNotAllowed)
+	}
+}
+
+// checkLocationFields 校验需要查询数据库的字段：名称不能与其他地点重复，关联的成就必须是地点打卡成就
+// id 为 0 表示新增
+func checkLocationFields(tx *sql.Tx, id int, req models.LocationRequest) error {
+	var taken bool
+	err := tx.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM checkin_locations WHERE lower(name) = lower($1) AND id <> $2)",
+		req.Name, id,
+	).Scan(&taken)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return errLocationNameTaken
+	}
+
+	if req.AchievementCode != nil {
+		var metric string
+		err := tx.QueryRow("SELECT metric FROM achievement_types WHERE code = $1", *req.AchievementCode).Scan(&metric)
+		if err == sql.ErrNoRows || (err == nil && metric != achievement.MetricLocationCheckins) {
+			return errLocationAchievement
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadLocationForResponse 修改后重新读取地点用于返回，读取失败时返回 nil（修改已经成功）
+func loadLocationForResponse(id int) *models.CheckinLocation {
+	locations, err := loadCheckinLocations(database.DB, id)
+	if err != nil || len(locations) == 0 {
+		return nil
+	}
+	return &locations[0]
+}
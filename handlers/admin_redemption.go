@@ -0,0 +1,441 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"h5project/database"
+	"h5project/models"
+)
+
+// itemImageDir 奖品图片目录（在卡片图片目录下单独存放，不进入 images/list.json）
+const itemImageDir = "./images/items"
+
+// itemCodePattern 奖品代码：小写字母、数字和下划线
+var itemCodePattern = regexp.MustCompile(`^[a-z0-9_]{1,50}$`)
+
+// AdminRedeemableItems 兑换目录管理
+// GET 列出所有奖品（含已下架）及库存不足提醒；POST 新增；PUT /{id} 修改（不修改库存）；PUT /{id}/image 上传图片；
+// POST /{id}/stock 调整库存（补货、盘亏或切换是否限量，不会覆盖并发兑换扣减的库存）；DELETE /{id} 删除（已有兑换记录的奖品只能下架）
+func AdminRedeemableItems(w http.ResponseWriter, r *http.Request) {
+	id, rest, hasID, err := parseAdminPath(r.URL.Path, "/api/admin/redeemable-items")
+	if err != nil {
+		sendError(w, "无效的奖品ID", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case !hasID && r.Method == http.MethodGet:
+		listRedeemableItems(w)
+	case !hasID && r.Method == http.MethodPost:
+		req, ok := decodeRedeemableItemRequest(w, r)
+		if !ok {
+			return
+		}
+		active := req.Active == nil || *req.Active
+
+		var newID int
+		err := database.WithTx(func(tx *sql.Tx) error {
+			err := tx.QueryRow(
+				`INSERT INTO redeemable_items (code, name, description, cost, stock, low_stock_threshold, period_limit, pickup_location, image_url, active, sort_order)
+				 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id`,
+				req.Code, req.Name, req.Description, req.Cost, req.Stock, req.LowStockThreshold, req.PeriodLimit,
+				req.PickupLocation, req.ImageURL, active, req.SortOrder,
+			).Scan(&newID)
+			if err != nil {
+				return err
+			}
+			return recordAudit(tx, r, "create", "redeemable_item", newID, req)
+		})
+		if isUniqueViolation(err) {
+			sendError(w, "奖品代码已存在", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("❌ 新增奖品失败: %v", err)
+			sendError(w, "新增失败", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"id":      newID,
+		})
+	case hasID && rest == "" && r.Method == http.MethodPut:
+		req, ok := decodeRedeemableItemRequest(w, r)
+		if !ok {
+			return
+		}
+		// 按绝对值写库存会覆盖期间兑换扣减的库存，库存只能通过 POST /{id}/stock 调整
+		if req.Stock != nil {
+			sendError(w, "修改奖品时不能设置库存，请通过 POST /api/admin/redeemable-items/{id}/stock 调整", http.StatusBadRequest)
+			return
+		}
+
+		err := database.WithTx(func(tx *sql.Tx) error {
+			result, err := tx.Exec(
+				`UPDATE redeemable_items
+				 SET code = $1, name = $2, description = $3, cost = $4, low_stock_threshold = $5, period_limit = $6,
+				 	pickup_location = $7, image_url = $8, active = COALESCE($9, active), sort_order = $10
+				 WHERE id = $11`,
+				req.Code, req.Name, req.Description, req.Cost, req.LowStockThreshold, req.PeriodLimit,
+				req.PickupLocation, req.ImageURL, req.Active, req.SortOrder, id,
+			)
+			if err != nil {
+				return err
+			}
+			if affected, _ := result.RowsAffected(); affected == 0 {
+				return sql.ErrNoRows
+			}
+			return recordAudit(tx, r, "update", "redeemable_item", id, req)
+		})
+		if err == sql.ErrNoRows {
+			sendError(w, "奖品不存在", http.StatusNotFound)
+			return
+		}
+		if isUniqueViolation(err) {
+			sendError(w, "奖品代码已存在", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("❌ 修改奖品失败: %v", err)
+			sendError(w, "修改失败", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+		})
+	case hasID && rest == "image" && r.Method == http.MethodPut:
+		replaceItemImage(w, r, id)
+	case hasID && rest == "stock" && r.Method == http.MethodPost:
+		adjustItemStock(w, r, id)
+	case hasID && rest == "" && r.Method == http.MethodDelete:
+		var redeemed bool
+		err := database.WithTx(func(tx *sql.Tx) error {
+			var name string
+			if err := tx.QueryRow("SELECT name FROM redeemable_items WHERE id = $1", id).Scan(&name); err != nil {
+				return err
+			}
+			if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM redemption_records WHERE item_id = $1)", id).Scan(&redeemed); err != nil {
+				return err
+			}
+			if redeemed {
+				return nil
+			}
+			if _, err := tx.Exec("DELETE FROM redeemable_items WHERE id = $1", id); err != nil {
+				return err
+			}
+			return recordAudit(tx, r, "delete", "redeemable_item", id, map[string]interface{}{"name": name})
+		})
+		if err == sql.ErrNoRows {
+			sendError(w, "奖品不存在", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("❌ 删除奖品失败: %v", err)
+			sendError(w, "删除失败", http.StatusInternalServerError)
+			return
+		}
+		if redeemed {
+			sendError(w, "奖品已有兑换记录，不能删除，请改为下架", http.StatusConflict)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+		})
+	default:
+		sendError(w, "方法不允许", http.StatusMethodNotAllowed)
+	}
+}
+
+// listRedeemableItems 列出所有奖品、累计兑换次数和待领取数量
+// 上架且剩余库存不超过提醒值的奖品同时列在 low_stock 中
+func listRedeemableItems(w http.ResponseWriter) {
+	rows, err := database.DB.Query(
+		`SELECT `+itemColumns+`, COUNT(rr.id) FILTER (WHERE rr.status IN ($1, $2)), COUNT(rr.id) FILTER (WHERE rr.status = $1)
+		 FROM redeemable_items i
+		 LEFT JOIN redemption_records rr ON rr.item_id = i.id
+		 GROUP BY i.id
+		 ORDER BY i.sort_order, i.id`,
+		models.RedemptionPending, models.RedemptionFulfilled,
+	)
+	if err != nil {
+		sendError(w, "查询失败", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	var items []map[string]interface{}
+	lowStock := []map[string]interface{}{}
+	for rows.Next() {
+		var item models.RedeemableItem
+		var redeemedCount, pendingCount int
+		if err := scanItem(rows, &item, &redeemedCount, &pendingCount); err != nil {
+			continue
+		}
+		low := item.Active && item.Stock != nil && *item.Stock <= item.LowStockThreshold
+		items = append(items, map[string]interface{}{
+			"item":           item,
+			"redeemed_count": redeemedCount,
+			"pending_count":  pendingCount, // 已兑换、等待领取的数量（已从库存扣减）
+			"low_stock":      low,
+		})
+		if low {
+			lowStock = append(lowStock, map[string]interface{}{
+				"id":        item.ID,
+				"code":      item.Code,
+				"name":      item.Name,
+				"stock":     *item.Stock,
+				"threshold": item.LowStockThreshold,
+				"message":   fmt.Sprintf("%s剩余库存 %d 件，请及时补货", item.Name, *item.Stock),
+			})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"items":     items,
+		"count":     len(items),
+		"low_stock": lowStock,
+	})
+}
+
+// decodeRedeemableItemRequest 解析并校验奖品请求，校验失败时已写入错误响应
+func decodeRedeemableItemRequest(w http.ResponseWriter, r *http.Request) (models.RedeemableItemRequest, bool) {
+	var req models.RedeemableItemRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, "无效的请求数据", http.StatusBadRequest)
+		return req, false
+	}
+	req.Code = strings.TrimSpace(req.Code)
+	req.Name = strings.TrimSpace(req.Name)
+	req.PickupLocation = strings.TrimSpace(req.PickupLocation)
+
+	switch {
+	case !itemCodePattern.MatchString(req.Code):
+		sendError(w, "奖品代码只能包含小写字母、数字和下划线", http.StatusBadRequest)
+	case req.Name == "":
+		sendError(w, "奖品名称不能为空", http.StatusBadRequest)
+	case req.Cost < 0:
+		sendError(w, "消耗兑换点不能为负数", http.StatusBadRequest)
+	case req.Stock != nil && *req.Stock < 0:
+		sendError(w, "库存不能为负数", http.StatusBadRequest)
+	case req.LowStockThreshold < 0:
+		sendError(w, "库存提醒值不能为负数", http.StatusBadRequest)
+	case req.PeriodLimit < 0:
+		sendError(w, "每周期兑换次数不能为负数", http.StatusBadRequest)
+	case req.PickupLocation == "":
+		sendError(w, "领取地点不能为空", http.StatusBadRequest)
+	default:
+		return req, true
+	}
+	return req, false
+}
+
+// adjustItemStock 调整奖品库存并记录审计日志，返回调整后的库存（不限库存时为 null）
+// 按增量调整在锁定的行上计算，不会覆盖并发兑换扣减的库存；切换是否限量需要显式设置 unlimited
+func adjustItemStock(w http.ResponseWriter, r *http.Request, id int) {
+	var req models.StockAdjustRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, "无效的请求数据", http.StatusBadRequest)
+		return
+	}
+	req.Note = strings.TrimSpace(req.Note)
+
+	switch {
+	case req.Unlimited == nil && req.Stock != nil:
+		sendError(w, "改为限量时需要同时设置 unlimited 为 false", http.StatusBadRequest)
+		return
+	case req.Unlimited == nil && req.Delta == 0:
+		sendError(w, "调整数量不能为 0", http.StatusBadRequest)
+		return
+	case req.Unlimited != nil && req.Delta != 0:
+		sendError(w, "切换是否限量时不能同时按增量调整", http.StatusBadRequest)
+		return
+	case req.Unlimited != nil && *req.Unlimited && req.Stock != nil:
+		sendError(w, "改为不限库存时不能设置库存", http.StatusBadRequest)
+		return
+	case req.Unlimited != nil && !*req.Unlimited && (req.Stock == nil || *req.Stock < 0):
+		sendError(w, "改为限量时需要设置不小于 0 的初始库存", http.StatusBadRequest)
+		return
+	}
+
+	var stock sql.NullInt64
+	err := database.WithTx(func(tx *sql.Tx) error {
+		if err := tx.QueryRow("SELECT stock FROM redeemable_items WHERE id = $1 FOR UPDATE", id).Scan(&stock); err != nil {
+			return err
+		}
+
+		var err error
+		switch {
+		case req.Unlimited == nil:
+			if !stock.Valid || stock.Int64+int64(req.Delta) < 0 {
+				return errInvalidStockAdjust
+			}
+			err = tx.QueryRow(
+				"UPDATE redeemable_items SET stock = stock + $1 WHERE id = $2 RETURNING stock",
+				req.Delta, id,
+			).Scan(&stock)
+		case *req.Unlimited:
+			stock = sql.NullInt64{}
+			_, err = tx.Exec("UPDATE redeemable_items SET stock = NULL WHERE id = $1", id)
+		default:
+			// 已经限量的奖品只能按增量调整，避免覆盖并发兑换扣减的库存
+			if stock.Valid {
+				return errStockAlreadyLimited
+			}
+			err = tx.QueryRow(
+				"UPDATE redeemable_items SET stock = $1 WHERE id = $2 RETURNING stock",
+				*req.Stock, id,
+			).Scan(&stock)
+		}
+		if err != nil {
+			return err
+		}
+
+		detail := map[string]interface{}{
+			"delta": req.Delta,
+			"stock": nil,
+			"note":  req.Note,
+		}
+		if stock.Valid {
+			detail["stock"] = stock.Int64
+		}
+		if req.Unlimited != nil {
+			detail["unlimited"] = *req.Unlimited
+		}
+		return recordAudit(tx, r, "adjust_stock", "redeemable_item", id, detail)
+	})
+	if err == sql.ErrNoRows {
+		sendError(w, "奖品不存在", http.StatusNotFound)
+		return
+	}
+	if err == errInvalidStockAdjust {
+		if !stock.Valid {
+			sendError(w, "该奖品不限库存，请先改为限量（unlimited 为 false 并设置 stock）", http.StatusBadRequest)
+		} else {
+			sendError(w, fmt.Sprintf("当前库存只有 %d 件", stock.Int64), http.StatusConflict)
+		}
+		return
+	}
+	if err == errStockAlreadyLimited {
+		sendError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("❌ 调整奖品库存失败: %v", err)
+		sendError(w, "调整失败", http.StatusInternalServerError)
+		return
+	}
+
+	var current interface{}
+	if stock.Valid {
+		current = stock.Int64
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"stock":   current,
+	})
+}
+
+var (
+	// errInvalidStockAdjust 不限库存的奖品不能按增量调整，库存也不能调整为负数
+	errInvalidStockAdjust = errors.New("无效的库存调整")
+	// errStockAlreadyLimited 奖品已经限量，不能直接设置库存
+	errStockAlreadyLimited = errors.New("该奖品已经限量，请按增量（delta）调整库存")
+)
+
+// replaceItemImage 上传奖品图片（multipart/form-data，字段 image），格式和尺寸限制与卡片图片相同
+func replaceItemImage(w http.ResponseWriter, r *http.Request, id int) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxCardImageBytes+1<<20)
+	if err := r.ParseMultipartForm(maxCardImageBytes); err != nil {
+		sendError(w, errInvalidCardImage.Error(), http.StatusBadRequest)
+		return
+	}
+
+	img, err := readCardImage(r)
+	if err != nil {
+		sendError(w, errInvalidCardImage.Error(), http.StatusBadRequest)
+		return
+	}
+
+	imageURL, path, err := saveItemImage(img, id)
+	if err != nil {
+		log.Printf("❌ 保存奖品图片失败: %v", err)
+		sendError(w, "保存图片失败", http.StatusInternalServerError)
+		return
+	}
+
+	err = database.WithTx(func(tx *sql.Tx) error {
+		var old sql.NullString
+		if err := tx.QueryRow("SELECT image_url FROM redeemable_items WHERE id = $1", id).Scan(&old); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE redeemable_items SET image_url = $1 WHERE id = $2", imageURL, id); err != nil {
+			return err
+		}
+		return recordAudit(tx, r, "update_image", "redeemable_item", id, map[string]interface{}{
+			"old": old.String,
+			"new": imageURL,
+		})
+	})
+	if err != nil {
+		os.Remove(path)
+		if err == sql.ErrNoRows {
+			sendError(w, "奖品不存在", http.StatusNotFound)
+			return
+		}
+		log.Printf("❌ 替换奖品图片失败: %v", err)
+		sendError(w, "替换失败", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":   true,
+		"image_url": imageURL,
+	})
+}
+
+// saveItemImage 保存奖品图片，文件名包含奖品ID和时间戳，旧图片保留在磁盘上
+// 返回 image_url 和文件路径；调用方在后续数据库操作失败时负责删除文件
+func saveItemImage(img image.Image, id int) (imageURL, path string, err error) {
+	if err := os.MkdirAll(itemImageDir, 0755); err != nil {
+		return "", "", err
+	}
+
+	name := fmt.Sprintf("item%d_%d.png", id, time.Now().UnixNano())
+	path = filepath.Join(itemImageDir, name)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return "", "", err
+	}
+
+	err = png.Encode(file, img)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", "", err
+	}
+	return "/images/items/" + name, path, nil
+}
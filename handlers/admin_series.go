@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"h5project/achievement"
+	"h5project/calendar"
+	"h5project/database"
+	"h5project/models"
+)
+
+// errSeriesNotFound 卡片指定的系列不存在
+var errSeriesNotFound = errors.New("系列不存在")
+
+// AdminSeries 卡片系列管理
+// GET 列出所有系列及卡片数量；POST 新增；PUT /{id} 修改；DELETE /{id} 删除（卡片保留，不再属于任何系列）
+func AdminSeries(w http.ResponseWriter, r *http.Request) {
+	id, _, hasID, err := parseAdminPath(r.URL.Path, "/api/admin/series")
+	if err != nil {
+		sendError(w, "无效的系列ID", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case !hasID && r.Method == http.MethodGet:
+		rows, err := database.DB.Query(
+			`SELECT ` + seriesColumns + `, COUNT(c.id)
+			 FROM card_series s
+			 LEFT JOIN cards c ON c.series_id = s.id
+			 GROUP BY s.id
+			 ORDER BY s.sort_order, s.id`,
+		)
+		if err != nil {
+			sendError(w, "查询失败", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		var series []map[string]interface{}
+		for rows.Next() {
+			var s models.CardSeries
+			var cardCount int
+			if err := scanSeries(rows, &s, &cardCount); err != nil {
+				continue
+			}
+			series = append(series, map[string]interface{}{
+				"series":     s,
+				"card_count": cardCount,
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"series": series,
+			"count":  len(series),
+		})
+	case !hasID && r.Method == http.MethodPost:
+		req, ok := decodeSeriesRequest(w, r)
+		if !ok {
+			return
+		}
+
+		var newID int
+		err := database.WithTx(func(tx *sql.Tx) error {
+			err := tx.QueryRow(
+				`INSERT INTO card_series (name, description, cover_image_url, sort_order, reward_points)
+				 VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+				req.Name, req.Description, req.CoverImageURL, req.SortOrder, req.RewardPoints,
+			).Scan(&newID)
+			if err != nil {
+				return err
+			}
+			if err := achievement.RecomputeMetrics(tx, calendar.Default().Now(), achievement.CardCatalogMetrics...); err != nil {
+				return err
+			}
+			return recordAudit(tx, r, "create", "series", newID, req)
+		})
+		if isUniqueViolation(err) {
+			sendError(w, "系列名称已存在", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("❌ 新增卡片系列失败: %v", err)
+			sendError(w, "新增失败", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"id":      newID,
+		})
+	case hasID && r.Method == http.MethodPut:
+		req, ok := decodeSeriesRequest(w, r)
+		if !ok {
+			return
+		}
+
+		err := database.WithTx(func(tx *sql.Tx) error {
+			result, err := tx.Exec(
+				`UPDATE card_series
+				 SET name = $1, description = $2, cover_image_url = $3, sort_order = $4, reward_points = $5
+				 WHERE id = $6`,
+				req.Name, req.Description, req.CoverImageURL, req.SortOrder, req.RewardPoints, id,
+			)
+			if err != nil {
+				return err
+			}
+			if affected, _ := result.RowsAffected(); affected == 0 {
+				return sql.ErrNoRows
+			}
+			return recordAudit(tx, r, "update", "series", id, req)
+		})
+		if err == sql.ErrNoRows {
+			sendError(w, "系列不存在", http.StatusNotFound)
+			return
+		}
+		if isUniqueViolation(err) {
+			sendError(w, "系列名称已存在", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("❌ 修改卡片系列失败: %v", err)
+			sendError(w, "修改失败", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+		})
+	case hasID && r.Method == http.MethodDelete:
+		// 系列下的卡片不会被删除，series_id 置空；已发放的集齐奖励记录随系列一起删除
+		err := database.WithTx(func(tx *sql.Tx) error {
+			var name string
+			if err := tx.QueryRow("SELECT name FROM card_series WHERE id = $1", id).Scan(&name); err != nil {
+				return err
+			}
+			if _, err := tx.Exec("DELETE FROM card_series WHERE id = $1", id); err != nil {
+				return err
+			}
+			if err := achievement.RecomputeMetrics(tx, calendar.Default().Now(), achievement.CardCatalogMetrics...); err != nil {
+				return err
+			}
+			return recordAudit(tx, r, "delete", "series", id, map[string]interface{}{"name": name})
+		})
+		if err == sql.ErrNoRows {
+			sendError(w, "系列不存在", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("❌ 删除卡片系列失败: %v", err)
+			sendError(w, "删除失败", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+		})
+	default:
+		sendError(w, "方法不允许", http.StatusMethodNotAllowed)
+	}
+}
+
+// decodeSeriesRequest 解析并校验系列请求，校验失败时已写入错误响应
+func decodeSeriesRequest(w http.ResponseWriter, r *http.Request) (models.SeriesRequest, bool) {
+	var req models.SeriesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, "无效的请求数据", http.StatusBadRequest)
+		return req, false
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		sendError(w, "系列名称不能为空", http.StatusBadRequest)
+		return req, false
+	}
+	if req.RewardPoints < 0 {
+		sendError(w, "奖励兑换点不能为负数", http.StatusBadRequest)
+		return req, false
+	}
+	return req, true
+}
+
+// ensureSeriesExists 校验卡片指定的系列是否存在，seriesID 为空表示不属于任何系列
+func ensureSeriesExists(q database.DBTX, seriesID *int) error {
+	if seriesID == nil {
+		return nil
+	}
+	var exists bool
+	err := q.QueryRow("SELECT EXISTS(SELECT 1 FROM card_series WHERE id = $1)", *seriesID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errSeriesNotFound
+	}
+	return nil
+}
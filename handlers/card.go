@@ -3,12 +3,14 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
 	"image/draw"
 	"image/jpeg"
 	"image/png"
+	"log"
 	"math/rand"
 	"net/http"
 	"os"
@@ -18,7 +20,10 @@ import (
 	"time"
 
 	"h5project/auth"
+	"h5project/calendar"
 	"h5project/database"
+	"h5project/events"
+	"h5project/gacha"
 	"h5project/models"
 
 	"golang.org/x/image/font"
@@ -39,18 +44,8 @@ func CheckTodayDraw(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 使用北京时间，下午4点为分界点
-	beijingLocation, _ := time.LoadLocation("Asia/Shanghai")
-	nowBeijing := time.Now().In(beijingLocation)
-
-	// 如果当前时间在下午4点之前，使用昨天的日期；否则使用今天的日期
-	var today string
-	if nowBeijing.Hour() < 16 {
-		yesterday := nowBeijing.AddDate(0, 0, -1)
-		today = yesterday.Format("2006-01-02")
-	} else {
-		today = nowBeijing.Format("2006-01-02")
-	}
+	// 当前游戏日（按游戏日历的时区和每日重置时间计算）
+	today := calendar.Default().Today()
 
 	var existingDraw models.DailyDraw
 	err = database.DB.QueryRow(
@@ -61,24 +56,39 @@ func CheckTodayDraw(w http.ResponseWriter, r *http.Request) {
 		&existingDraw.DrawDate, &existingDraw.IsNewCard,
 	)
 
+	// 保底进度
+	drawConfig, cfgErr := gacha.LoadConfig(database.DB)
+	if cfgErr != nil {
+		log.Printf("⚠️  读取抽卡配置失败，出错的项使用默认值: %v", cfgErr)
+	}
+	streak, streakErr := getDuplicateStreak(database.DB, userID)
+	if streakErr != nil {
+		log.Printf("❌ %v", streakErr)
+		sendError(w, "查询失败", http.StatusInternalServerError)
+		return
+	}
+	pity := drawConfig.PityPolicy().Status(streak)
+
+	// 今天的地点签到和由签到推导出的抽卡资格
+	checkins, checkinErr := loadTodayCheckins(database.DB, userID, today)
+	if checkinErr != nil {
+		log.Printf("⚠️  查询用户 %d 今日签到失败: %v", userID, checkinErr)
+	}
+	checkin := checkinResponse(database.DB, checkins)
+
 	if err != nil {
 		// 今天还没抽卡
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"has_drawn": false,
+			"pity":      pity,
+			"checkin":   checkin,
 		})
 		return
 	}
 
 	// 今天已抽卡，返回卡片信息
-	var card models.Card
-	err = database.DB.QueryRow(
-		"SELECT id, name, image_url, rarity, description, created_at FROM cards WHERE id = $1",
-		existingDraw.CardID,
-	).Scan(
-		&card.ID, &card.Name, &card.ImageURL, &card.Rarity,
-		&card.Description, &card.CreatedAt,
-	)
+	card, err := getCardByID(database.DB, existingDraw.CardID)
 	if err != nil {
 		sendError(w, "获取卡片信息失败", http.StatusInternalServerError)
 		return
@@ -89,9 +99,30 @@ func CheckTodayDraw(w http.ResponseWriter, r *http.Request) {
 		"has_drawn":   true,
 		"card":        card,
 		"is_new_card": existingDraw.IsNewCard,
+		"pity":        pity,
+		"checkin":     checkin,
 	})
 }
 
+// getDuplicateStreak 统计用户最近连续抽到重复卡的次数
+// 从最后一次抽到新卡或触发保底之后开始计算（与 gacha.NextStreak 一致），已集齐的用户不会每次都触发保底
+func getDuplicateStreak(q database.DBTX, userID int) (int, error) {
+	var streak int
+	err := q.QueryRow(
+		`SELECT COUNT(*) FROM daily_draws
+		 WHERE user_id = $1 AND is_new_card = false AND pity = false
+		   AND draw_date > COALESCE(
+		 		(SELECT MAX(draw_date) FROM daily_draws WHERE user_id = $1 AND (is_new_card = true OR pity = true)),
+		 		'1970-01-01'
+		   )`,
+		userID,
+	).Scan(&streak)
+	if err != nil {
+		return 0, fmt.Errorf("查询连续重复次数失败: %w", err)
+	}
+	return streak, nil
+}
+
 func DrawCard(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		sendError(w, "方法不允许", http.StatusMethodNotAllowed)
@@ -104,179 +135,275 @@ func DrawCard(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 解析请求体（可能包含位置信息）
+	// 解析请求体（旧版客户端可能附带位置信息）
 	var drawReq models.DrawCardRequest
 	if r.Body != nil {
 		json.NewDecoder(r.Body).Decode(&drawReq)
 	}
 
-	// 检查是否启用位置校验
-	var locationCheckEnabled bool
-	err = database.DB.QueryRow(
-		"SELECT value = 'true' FROM system_config WHERE key = 'location_check_enabled'",
-	).Scan(&locationCheckEnabled)
-	if err != nil {
-		locationCheckEnabled = false // 默认关闭
-	}
+	// 启用位置校验时，抽卡资格由当天的地点签到决定（见 /api/checkin）
+	checkinRequired := isLocationCheckEnabled(database.DB)
+
+	// 当前游戏日，抽卡记录和地点签到都记在这一天
+	today := calendar.Default().Today()
 
-	// 如果启用了位置校验，验证位置
-	var validLocationID int
-	var savedLatitude, savedLongitude float64
-	if locationCheckEnabled {
-		if drawReq.Latitude == nil || drawReq.Longitude == nil {
-			sendError(w, "需要提供位置信息才能打卡", http.StatusBadRequest)
+	// 兼容旧版客户端：随抽卡请求提交的位置在范围内且地点开放时，先按该位置签到
+	// 签到与 /api/checkin 一样单独提交，可疑的签到即使这次不能抽卡也会留给工作人员审核
+	var checkinAchievements []models.AchievementStatus
+	if checkinRequired && drawReq.Latitude != nil && drawReq.Longitude != nil {
+		if drawReq.Accuracy != nil && !(*drawReq.Accuracy >= 0) {
+			sendError(w, "无效的定位精度", http.StatusBadRequest)
 			return
 		}
-
-		// 查找用户是否在允许的打卡地点范围内
-		var locationName string
-		var distance float64
-		err = database.DB.QueryRow(
-			`SELECT id, name, 
-			 	6371000 * acos(
-			 		cos(radians($1)) * cos(radians(latitude)) * 
-			 		cos(radians(longitude) - radians($2)) + 
-			 		sin(radians($1)) * sin(radians(latitude))
-			 	) as distance
-			 FROM checkin_locations 
-			 WHERE (
-			 	6371000 * acos(
-			 		cos(radians($1)) * cos(radians(latitude)) * 
-			 		cos(radians(longitude) - radians($2)) + 
-			 		sin(radians($1)) * sin(radians(latitude))
-			 	)
-			 ) <= radius_meters
-			 ORDER BY distance ASC
-			 LIMIT 1`,
-			*drawReq.Latitude, *drawReq.Longitude,
-		).Scan(&validLocationID, &locationName, &distance)
-
-		if err != nil {
-			// 获取所有地点名称
-			var locationNames []string
-			rows, _ := database.DB.Query("SELECT name FROM checkin_locations ORDER BY id")
-			if rows != nil {
-				defer rows.Close()
-				for rows.Next() {
-					var name string
-					if err := rows.Scan(&name); err == nil {
-						locationNames = append(locationNames, name)
-					}
-				}
+		locationID, _, _, err := findCheckinLocation(database.DB, *drawReq.Latitude, *drawReq.Longitude, 0, calendar.Default().Now())
+		var closed *locationClosedError
+		if err == nil {
+			checkin := locationCheckin{
+				LocationID: locationID,
+				Method:     checkinMethodGPS,
+				Latitude:   drawReq.Latitude,
+				Longitude:  drawReq.Longitude,
+				Accuracy:   drawReq.Accuracy,
+				Code:       drawReq.Code,
 			}
-
-			// 构建地点名称列表
-			var locationsText string
-			if len(locationNames) > 0 {
-				locationsText = strings.Join(locationNames, "、")
-			} else {
-				locationsText = "指定地点"
+			if err := verifyCheckinCode(checkin, time.Now()); err != nil {
+				sendError(w, err.Error(), http.StatusBadRequest)
+				return
 			}
-
-			sendError(w, fmt.Sprintf("您不在允许的打卡地点范围内，请在以下地点打卡：%s", locationsText), http.StatusForbidden)
+			_, _, checkinAchievements, err = saveCheckin(userID, today, &checkin)
+			if err != nil {
+				log.Printf("❌ 用户 %d 签到失败: %v", userID, err)
+				sendError(w, "签到失败，请稍后重试", http.StatusInternalServerError)
+				return
+			}
+			if checkin.Status == models.CheckinFlagged {
+				log.Printf("⚠️  用户 %d 在地点 %d 的签到可疑: %v", userID, locationID, checkin.Flags)
+			}
+		} else if err != sql.ErrNoRows && !errors.As(err, &closed) {
+			sendError(w, "查询打卡地点失败", http.StatusInternalServerError)
 			return
 		}
+	}
 
-		// 保存位置信息用于后续记录
-		savedLatitude = *drawReq.Latitude
-		savedLongitude = *drawReq.Longitude
+	// 抽卡配置只读，放在事务外加载
+	drawConfig, err := gacha.LoadConfig(database.DB)
+	if err != nil {
+		log.Printf("⚠️  读取抽卡配置失败，出错的项使用默认值: %v", err)
+	}
+
+	// 整个抽卡过程（抽卡记录、卡包、打卡次数、地点打卡、成就、兑换点）在同一个事务中提交
+	var response *models.DrawResponse
+	err = database.WithTx(func(tx *sql.Tx) error {
+		var txErr error
+		response, txErr = drawInTx(tx, userID, today, drawConfig, checkinRequired)
+		return txErr
+	})
+	if err == errCheckinRequired {
+		sendError(w, fmt.Sprintf("今天还没有签到，请先在以下地点签到：%s", checkinLocationNames(database.DB)), http.StatusForbidden)
+		return
+	}
+	if err == errCheckinPendingReview {
+		sendError(w, "今天的签到正在等待工作人员审核，审核通过后才能抽卡", http.StatusForbidden)
+		return
+	}
+	if err == gacha.ErrEmptyPool {
+		sendError(w, "暂无可用卡片", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("❌ 用户 %d 抽卡失败: %v", userID, err)
+		sendError(w, "抽卡失败，请稍后重试", http.StatusInternalServerError)
+		return
 	}
 
-	// 检查今天是否已经抽过卡（使用北京时间，下午4点为分界点）
-	// 获取北京时间
-	beijingLocation, _ := time.LoadLocation("Asia/Shanghai")
-	nowBeijing := time.Now().In(beijingLocation)
+	response.NewAchievements = append(checkinAchievements, response.NewAchievements...)
 
-	// 如果当前时间在下午4点之前，使用昨天的日期；否则使用今天的日期
-	var today string
-	if nowBeijing.Hour() < 16 {
-		// 下午4点之前，使用昨天的日期
-		yesterday := nowBeijing.AddDate(0, 0, -1)
-		today = yesterday.Format("2006-01-02")
-	} else {
-		// 下午4点之后，使用今天的日期
-		today = nowBeijing.Format("2006-01-02")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// errCheckinRequired 启用位置校验时当天还没有签到
+var errCheckinRequired = errors.New("今天还没有签到")
+
+// errCheckinPendingReview 启用位置校验时当天的签到都在等待审核
+var errCheckinPendingReview = errors.New("今天的签到正在等待审核")
+
+// drawInTx 在事务中完成一次抽卡
+// 先锁定用户行，使同一用户的并发请求串行执行；今天已抽过卡则直接返回当天的卡片，
+// 因此重复点击或客户端重试总是得到同一张卡
+// checkinRequired 为 true 时当天必须已有计入的地点签到
+func drawInTx(tx *sql.Tx, userID int, today string, drawConfig gacha.Config, checkinRequired bool) (*models.DrawResponse, error) {
+	var lockedID int
+	err := tx.QueryRow("SELECT id FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&lockedID)
+	if err != nil {
+		return nil, fmt.Errorf("锁定用户失败: %w", err)
 	}
 
 	var existingDraw models.DailyDraw
-	err = database.DB.QueryRow(
+	err = tx.QueryRow(
 		"SELECT id, user_id, card_id, draw_date, is_new_card FROM daily_draws WHERE user_id = $1 AND draw_date = $2",
 		userID, today,
 	).Scan(
 		&existingDraw.ID, &existingDraw.UserID, &existingDraw.CardID,
 		&existingDraw.DrawDate, &existingDraw.IsNewCard,
 	)
-
 	if err == nil {
 		// 今天已经抽过卡，返回今天的卡片
-		var card models.Card
-		err = database.DB.QueryRow(
-			"SELECT id, name, image_url, rarity, description, created_at FROM cards WHERE id = $1",
-			existingDraw.CardID,
-		).Scan(
-			&card.ID, &card.Name, &card.ImageURL, &card.Rarity,
-			&card.Description, &card.CreatedAt,
-		)
+		card, err := getCardByID(tx, existingDraw.CardID)
 		if err != nil {
-			sendError(w, "获取卡片信息失败", http.StatusInternalServerError)
-			return
+			return nil, fmt.Errorf("获取卡片信息失败: %w", err)
 		}
-
-		response := models.DrawResponse{
+		return &models.DrawResponse{
 			Card:      card,
 			IsNewCard: existingDraw.IsNewCard,
+			Rarity:    card.Rarity,
 			Message:   "今天已经抽过卡了，这是你今天的卡片",
+		}, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, fmt.Errorf("查询今日抽卡记录失败: %w", err)
+	}
+
+	// 抽卡资格由当天的地点签到决定
+	if checkinRequired {
+		checkedIn, err := hasCheckedInToday(tx, userID, today)
+		if err != nil {
+			return nil, fmt.Errorf("查询今日签到失败: %w", err)
 		}
+		if !checkedIn {
+			pending, err := hasPendingCheckinToday(tx, userID, today)
+			if err != nil {
+				return nil, fmt.Errorf("查询今日签到失败: %w", err)
+			}
+			if pending {
+				return nil, errCheckinPendingReview
+			}
+			return nil, errCheckinRequired
+		}
+	}
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
-		return
+	newCards, oldCards, err := loadDrawPool(tx, userID, calendar.Default().Now())
+	if err != nil {
+		return nil, err
 	}
 
-	// 获取用户已拥有的卡片ID列表
-	rows, err := database.DB.Query(
-		"SELECT card_id FROM user_cards WHERE user_id = $1",
+	// 连续重复次数查询失败时中止抽卡，不能按 0 次处理而错过保底
+	streak, err := getDuplicateStreak(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	result, err := gacha.BuildPolicy(drawConfig).Draw(gacha.Pool{
+		NewCards:        newCards,
+		OwnedCards:      oldCards,
+		DuplicateStreak: streak,
+	}, rng)
+	if err != nil {
+		return nil, err
+	}
+	selectedCard := result.Card
+	isNewCard := result.IsNewCard
+
+	// 记录每日抽卡
+	_, err = tx.Exec(
+		"INSERT INTO daily_draws (user_id, card_id, draw_date, is_new_card, pity) VALUES ($1, $2, $3, $4, $5)",
+		userID, selectedCard.ID, today, isNewCard, result.Pity,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("记录抽卡结果失败: %w", err)
+	}
+
+	// 更新用户打卡次数（无论是否新卡都增加）
+	_, err = tx.Exec(
+		"UPDATE users SET checkin_count = checkin_count + 1 WHERE id = $1",
 		userID,
 	)
-	if err != nil && err != sql.ErrNoRows {
-		sendError(w, "查询用户卡包失败", http.StatusInternalServerError)
-		return
+	if err != nil {
+		return nil, fmt.Errorf("更新打卡次数失败: %w", err)
 	}
-	defer rows.Close()
 
+	// 如果是新卡，添加到用户卡包并检查系列收集进度
+	var completedSeries []models.SeriesProgress
+	if isNewCard {
+		_, err = tx.Exec(
+			"INSERT INTO user_cards (user_id, card_id) VALUES ($1, $2) ON CONFLICT (user_id, card_id) DO NOTHING",
+			userID, selectedCard.ID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("添加卡片到卡包失败: %w", err)
+		}
+
+		completedSeries, err = checkSeriesCompletions(tx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("检查系列收集进度失败: %w", err)
+		}
+	}
+
+	// 卡包、打卡天数和地点签到都已更新，发布领域事件，由成就订阅者更新进度并解锁成就
+	drawEvents := []events.Type{events.CheckinRecorded}
+	if isNewCard {
+		drawEvents = append(drawEvents, events.CardObtained)
+	}
+	newAchievements, err := publishEvents(tx, userID, drawEvents...)
+	if err != nil {
+		return nil, fmt.Errorf("检查成就失败: %w", err)
+	}
+
+	message := "恭喜你抽到了新卡！"
+	if !isNewCard {
+		message = "抽到了重复的卡片"
+	}
+	if result.Pity {
+		message = "保底触发！" + message
+	}
+
+	return &models.DrawResponse{
+		Card:            selectedCard,
+		IsNewCard:       isNewCard,
+		Rarity:          selectedCard.Rarity,
+		PityTriggered:   result.Pity,
+		Message:         message,
+		NewAchievements: newAchievements,
+		CompletedSeries: completedSeries,
+	}, nil
+}
+
+// loadDrawPool 获取用户的抽卡卡池，按是否已拥有分为新卡和旧卡
+// 只有 now 时刻可抽取的卡片（见 drawableCardsWhere）进入卡池
+func loadDrawPool(q database.DBTX, userID int, now time.Time) (newCards, oldCards []models.Card, err error) {
 	ownedCardIDs := make(map[int]bool)
+	rows, err := q.Query(
+		"SELECT card_id FROM user_cards WHERE user_id = $1",
+		userID,
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("查询用户卡包失败: %w", err)
+	}
 	for rows.Next() {
 		var cardID int
 		if err := rows.Scan(&cardID); err == nil {
 			ownedCardIDs[cardID] = true
 		}
 	}
+	rows.Close()
 
-	// 获取所有卡片
-	allRows, err := database.DB.Query(
-		"SELECT id, name, image_url, rarity, description, created_at FROM cards ORDER BY id",
+	// 已下架或不在上架时间内的卡片不进入卡池，但仍保留在用户卡包中
+	allRows, err := q.Query(
+		"SELECT "+cardColumns+" FROM cards WHERE "+drawableCardsWhere+" ORDER BY sort_order, id",
+		now,
 	)
 	if err != nil {
-		sendError(w, "获取卡片列表失败", http.StatusInternalServerError)
-		return
+		return nil, nil, fmt.Errorf("获取卡片列表失败: %w", err)
 	}
 	defer allRows.Close()
 
-	var allCards []models.Card
-	var newCards []models.Card
-	var oldCards []models.Card
-
 	for allRows.Next() {
-		var card models.Card
-		err := allRows.Scan(
-			&card.ID, &card.Name, &card.ImageURL, &card.Rarity,
-			&card.Description, &card.CreatedAt,
-		)
+		card, err := scanCard(allRows)
 		if err != nil {
 			continue
 		}
 
-		allCards = append(allCards, card)
 		if ownedCardIDs[card.ID] {
 			oldCards = append(oldCards, card)
 		} else {
@@ -284,155 +411,128 @@ func DrawCard(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if len(allCards) == 0 {
-		sendError(w, "暂无可用卡片", http.StatusNotFound)
-		return
-	}
+	return newCards, oldCards, allRows.Err()
+}
 
-	// 抽卡逻辑：新卡90%，旧卡10%
-	var selectedCard models.Card
-	var isNewCard bool
+// cardColumns 查询卡片时使用的列，顺序与 scanCard 一致
+const cardColumns = "id, name, image_url, rarity, description, created_at, sort_order, retired, series_id, available_from, available_until"
 
-	rand.Seed(time.Now().UnixNano())
-	random := rand.Float64()
+// drawableCardsWhere 当前可抽取的卡片：未下架，且在上架时间窗口 [available_from, available_until) 内
+// $1 为当前时间；窗口两端为空表示不限制
+const drawableCardsWhere = "retired = false AND (available_from IS NULL OR available_from <= $1) AND (available_until IS NULL OR available_until > $1)"
 
-	if random < 0.9 && len(newCards) > 0 {
-		// 90%概率抽新卡
-		selectedCard = newCards[rand.Intn(len(newCards))]
-		isNewCard = true
-	} else if len(oldCards) > 0 {
-		// 10%概率或没有新卡时抽旧卡
-		selectedCard = oldCards[rand.Intn(len(oldCards))]
-		isNewCard = false
-	} else {
-		// 如果没有旧卡，只能抽新卡
-		selectedCard = newCards[rand.Intn(len(newCards))]
-		isNewCard = true
-	}
+// rowScanner *sql.Row 和 *sql.Rows 共同的扫描接口
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
 
-	// 记录每日抽卡（使用北京时间，下午4点为分界点）
-	// 使用之前已经定义的today变量
-	_, err = database.DB.Exec(
-		"INSERT INTO daily_draws (user_id, card_id, draw_date, is_new_card) VALUES ($1, $2, $3, $4)",
-		userID, selectedCard.ID, today, isNewCard,
+// scanCard 按 cardColumns 的顺序扫描一张卡片
+func scanCard(row rowScanner) (models.Card, error) {
+	var card models.Card
+	err := row.Scan(
+		&card.ID, &card.Name, &card.ImageURL, &card.Rarity,
+		&card.Description, &card.CreatedAt, &card.SortOrder, &card.Retired,
+		&card.SeriesID, &card.AvailableFrom, &card.AvailableUntil,
 	)
-	if err != nil {
-		sendError(w, "记录抽卡结果失败", http.StatusInternalServerError)
+	return card, err
+}
+
+// getCardByID 获取单张卡片
+func getCardByID(q database.DBTX, cardID int) (models.Card, error) {
+	return scanCard(q.QueryRow("SELECT "+cardColumns+" FROM cards WHERE id = $1", cardID))
+}
+
+// GetDrawOdds 公示抽卡规则和概率（公开接口，无需登录）
+// 抽卡分两步，第二步的概率取决于用户已拥有哪些卡片，登录后的实际概率见 GetMyDrawOdds
+func GetDrawOdds(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendError(w, "方法不允许", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// 更新用户打卡次数（无论是否新卡都增加）
-	_, err = database.DB.Exec(
-		"UPDATE users SET checkin_count = checkin_count + 1 WHERE id = $1",
-		userID,
+	drawConfig, err := gacha.LoadConfig(database.DB)
+	if err != nil {
+		log.Printf("⚠️  读取抽卡配置失败，出错的项使用默认值: %v", err)
+	}
+
+	rows, err := database.DB.Query(
+		"SELECT "+cardColumns+" FROM cards WHERE "+drawableCardsWhere+" ORDER BY sort_order, id",
+		calendar.Default().Now(),
 	)
 	if err != nil {
-		// 记录错误但不影响返回结果
-		_ = err
+		sendError(w, "获取卡片列表失败", http.StatusInternalServerError)
+		return
 	}
+	defer rows.Close()
 
-	// 如果是新卡，添加到用户卡包
-	var newAchievements []models.AchievementStatus
-	if isNewCard {
-		_, err = database.DB.Exec(
-			"INSERT INTO user_cards (user_id, card_id) VALUES ($1, $2) ON CONFLICT (user_id, card_id) DO NOTHING",
-			userID, selectedCard.ID,
-		)
+	var cards []models.Card
+	for rows.Next() {
+		card, err := scanCard(rows)
 		if err != nil {
-			// 记录错误但不影响返回结果
-			_ = err
+			continue
 		}
-
-		// 检查成就
-		newAchievements, _ = CheckAchievements(userID)
+		cards = append(cards, card)
 	}
 
-	// 如果启用了位置校验且抽卡成功，记录地点打卡
-	if locationCheckEnabled && err == nil && validLocationID > 0 {
-		beijingLocation, _ := time.LoadLocation("Asia/Shanghai")
-		nowBeijing := time.Now().In(beijingLocation)
-		var checkinDate string
-		if nowBeijing.Hour() < 16 {
-			yesterday := nowBeijing.AddDate(0, 0, -1)
-			checkinDate = yesterday.Format("2006-01-02")
-		} else {
-			checkinDate = nowBeijing.Format("2006-01-02")
-		}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"new_card_rate":   drawConfig.NewCardRate,
+		"owned_card_rate": 1 - drawConfig.NewCardRate,
+		"pity_threshold":  drawConfig.PityThreshold,
+		"rarity_weights":  drawConfig.RarityPicker().RarityWeights(cards),
+		"total_cards":     len(cards),
+		"note": "抽卡分两步：第一步以 new_card_rate 的概率从未拥有的卡片中抽取，否则从已拥有的卡片中抽取（某一侧没有卡片时全部落到另一侧）；" +
+			"第二步在选中的卡池内按稀有度权重抽取，单张卡的概率为该卡权重除以该卡池所有卡片的权重之和，因此各稀有度的概率取决于已拥有的卡片；" +
+			"连续 pity_threshold 次抽到重复卡后下一次必出新卡，已集齐时必出最稀有的卡（pity_threshold 为 0 表示关闭保底）；" +
+			"已下架和不在上架时间内的卡片不计入。登录后可在 /api/draw/odds/me 查看按自己卡包计算的实际概率",
+	})
+}
 
-		database.DB.Exec(
-			`INSERT INTO location_checkins (user_id, location_id, checkin_date, latitude, longitude) 
-			 VALUES ($1, $2, $3, $4, $5)
-			 ON CONFLICT (user_id, location_id, checkin_date) DO NOTHING`,
-			userID, validLocationID, checkinDate, savedLatitude, savedLongitude,
-		)
+// GetMyDrawOdds 按用户当前的卡包和保底进度计算下一次抽卡的实际概率
+func GetMyDrawOdds(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendError(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
 
-		// 检查地点成就
-		var achievementCode string
-		err := database.DB.QueryRow(
-			"SELECT achievement_code FROM checkin_locations WHERE id = $1",
-			validLocationID,
-		).Scan(&achievementCode)
-		if err == nil && achievementCode != "" {
-			// 检查该地点打卡次数
-			var checkinCount int
-			database.DB.QueryRow(
-				"SELECT COUNT(*) FROM location_checkins WHERE user_id = $1 AND location_id = $2",
-				userID, validLocationID,
-			).Scan(&checkinCount)
-
-			if checkinCount >= 15 {
-				// 解锁地点成就
-				var achievementTypeID int
-				err = database.DB.QueryRow(
-					"SELECT id FROM achievement_types WHERE code = $1",
-					achievementCode,
-				).Scan(&achievementTypeID)
-				if err == nil {
-					// 检查是否已解锁
-					var exists bool
-					database.DB.QueryRow(
-						"SELECT EXISTS(SELECT 1 FROM user_achievements WHERE user_id = $1 AND achievement_type_id = $2)",
-						userID, achievementTypeID,
-					).Scan(&exists)
-
-					if !exists {
-						// 解锁并自动领取
-						database.DB.Exec(
-							"INSERT INTO user_achievements (user_id, achievement_type_id, unlocked_at, claimed_at) VALUES ($1, $2, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)",
-							userID, achievementTypeID,
-						)
-
-						var rewardPoints int
-						database.DB.QueryRow(
-							"SELECT reward_points FROM achievement_types WHERE id = $1",
-							achievementTypeID,
-						).Scan(&rewardPoints)
-
-						// 增加兑换点
-						database.DB.Exec(
-							"UPDATE users SET exchange_points = exchange_points + $1 WHERE id = $2",
-							rewardPoints, userID,
-						)
-					}
-				}
-			}
-		}
+	userID, err := auth.GetUserIDFromRequest(r)
+	if err != nil {
+		sendError(w, "未授权", http.StatusUnauthorized)
+		return
 	}
 
-	message := "恭喜你抽到了新卡！"
-	if !isNewCard {
-		message = "抽到了重复的卡片"
+	drawConfig, err := gacha.LoadConfig(database.DB)
+	if err != nil {
+		log.Printf("⚠️  读取抽卡配置失败，出错的项使用默认值: %v", err)
 	}
 
-	response := models.DrawResponse{
-		Card:            selectedCard,
-		IsNewCard:       isNewCard,
-		Message:         message,
-		NewAchievements: newAchievements,
+	newCards, oldCards, err := loadDrawPool(database.DB, userID, calendar.Default().Now())
+	if err != nil {
+		log.Printf("❌ 获取抽卡卡池失败: %v", err)
+		sendError(w, "获取卡片列表失败", http.StatusInternalServerError)
+		return
+	}
+
+	streak, err := getDuplicateStreak(database.DB, userID)
+	if err != nil {
+		log.Printf("❌ %v", err)
+		sendError(w, "查询失败", http.StatusInternalServerError)
+		return
 	}
 
+	odds := drawConfig.Odds(gacha.Pool{
+		NewCards:        newCards,
+		OwnedCards:      oldCards,
+		DuplicateStreak: streak,
+	})
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"new_card_pool":   odds.NewCardPool,
+		"owned_card_pool": odds.OwnedCardPool,
+		"pity":            odds.Pity,
+		"note":            "单张卡的概率 = 卡池的 probability × 该稀有度的 per_card；rarities 中的 probability 为从该卡池抽取时抽到该稀有度的概率",
+	})
 }
 
 // 初始化卡片数据（如果卡片表为空）
@@ -490,13 +590,21 @@ func GetUserCards(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 查询用户拥有的所有卡片，按系列（rarity）和编号（id）排序
+	// 系列奖励在抽卡和管理员调整卡片时发放，这里只读取进度
+	series, err := loadSeriesProgress(database.DB, userID)
+	if err != nil {
+		sendError(w, "查询失败", http.StatusInternalServerError)
+		return
+	}
+
+	// 查询用户拥有的所有卡片，按系列顺序、卡片展示顺序和编号（id）排序，不属于任何系列的卡片排在最后
 	rows, err := database.DB.Query(`
-		SELECT c.id, c.name, c.image_url, c.rarity, c.description, c.created_at, uc.obtained_at
+		SELECT c.id, c.name, c.image_url, c.rarity, c.description, c.series_id, c.created_at, uc.obtained_at
 		FROM user_cards uc
 		INNER JOIN cards c ON uc.card_id = c.id
+		LEFT JOIN card_series s ON c.series_id = s.id
 		WHERE uc.user_id = $1
-		ORDER BY c.rarity, c.id
+		ORDER BY s.sort_order NULLS LAST, s.id NULLS LAST, c.sort_order, c.id
 	`, userID)
 	if err != nil {
 		sendError(w, "查询失败", http.StatusInternalServerError)
@@ -509,7 +617,7 @@ func GetUserCards(w http.ResponseWriter, r *http.Request) {
 		var card models.UserCardDetail
 		err := rows.Scan(
 			&card.ID, &card.Name, &card.ImageURL, &card.Rarity,
-			&card.Description, &card.CreatedAt, &card.ObtainedAt,
+			&card.Description, &card.SeriesID, &card.CreatedAt, &card.ObtainedAt,
 		)
 		if err != nil {
 			continue
@@ -519,8 +627,9 @@ func GetUserCards(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"cards": cards,
-		"count": len(cards),
+		"cards":  cards,
+		"count":  len(cards),
+		"series": series,
 	})
 }
 
@@ -557,14 +666,7 @@ func GetCardDetail(w http.ResponseWriter, r *http.Request, cardID string) {
 		return
 	}
 
-	var card models.Card
-	err = database.DB.QueryRow(
-		"SELECT id, name, image_url, rarity, description, created_at FROM cards WHERE id = $1",
-		cardIDInt,
-	).Scan(
-		&card.ID, &card.Name, &card.ImageURL, &card.Rarity,
-		&card.Description, &card.CreatedAt,
-	)
+	card, err := getCardByID(database.DB, cardIDInt)
 	if err == sql.ErrNoRows {
 		sendError(w, "卡片不存在", http.StatusNotFound)
 		return
@@ -611,13 +713,7 @@ func DownloadCardWithWatermark(w http.ResponseWriter, r *http.Request, cardID st
 	}
 
 	// 获取卡片信息
-	var card models.Card
-	err = database.DB.QueryRow(
-		"SELECT id, name, image_url, rarity, description FROM cards WHERE id = $1",
-		cardIDInt,
-	).Scan(
-		&card.ID, &card.Name, &card.ImageURL, &card.Rarity, &card.Description,
-	)
+	card, err := getCardByID(database.DB, cardIDInt)
 	if err == sql.ErrNoRows {
 		sendError(w, "卡片不存在", http.StatusNotFound)
 		return
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// 卡片图片存储配置
+const (
+	cardImageDir       = "./images"
+	maxCardImageBytes  = 10 << 20   // 上传图片最大 10MB
+	maxCardImagePixels = 40_000_000 // 解码前检查像素数，防止超大图片占满内存
+	maxCardImageSide   = 1200       // 保存时最长边缩放到不超过该值
+)
+
+// errInvalidCardImage 上传的图片不合法（返回给客户端的错误信息）
+var errInvalidCardImage = errors.New("图片必须是不超过10MB的PNG或JPEG文件")
+
+// cardImageNamePattern 卡片图片文件名，如 card001.png
+var cardImageNamePattern = regexp.MustCompile(`^card(\d+)\.(png|jpg|jpeg)$`)
+
+// readCardImage 读取 multipart 表单中的 image 字段并校验格式和尺寸
+// PNG/JPEG 解码器由 card.go 中的 image/png、image/jpeg 导入注册
+// 调用前需要用 http.MaxBytesReader 限制请求体大小
+func readCardImage(r *http.Request) (image.Image, error) {
+	file, _, err := r.FormFile("image")
+	if err != nil {
+		return nil, errInvalidCardImage
+	}
+	defer file.Close()
+
+	cfg, format, err := image.DecodeConfig(file)
+	if err != nil || (format != "png" && format != "jpeg") {
+		return nil, errInvalidCardImage
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxCardImagePixels {
+		return nil, errInvalidCardImage
+	}
+
+	if _, err := file.Seek(0, 0); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, errInvalidCardImage
+	}
+	return resizeCardImage(img), nil
+}
+
+// resizeCardImage 最长边超过 maxCardImageSide 时等比缩小
+func resizeCardImage(img image.Image) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	longest := width
+	if height > longest {
+		longest = height
+	}
+	if longest <= maxCardImageSide {
+		return img
+	}
+
+	newWidth := width * maxCardImageSide / longest
+	newHeight := height * maxCardImageSide / longest
+	if newWidth < 1 {
+		newWidth = 1
+	}
+	if newHeight < 1 {
+		newHeight = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, xdraw.Over, nil)
+	return dst
+}
+
+// saveCardImage 以下一个可用编号（card001.png、card002.png……）保存图片
+// 返回 image_url 和文件路径；调用方在后续数据库操作失败时负责删除文件
+func saveCardImage(img image.Image) (imageURL, path string, err error) {
+	if err := os.MkdirAll(cardImageDir, 0755); err != nil {
+		return "", "", err
+	}
+
+	next, err := nextCardImageNumber()
+	if err != nil {
+		return "", "", err
+	}
+
+	// O_EXCL 保证并发上传不会覆盖同一个文件，冲突时顺延编号
+	for attempt := 0; attempt < 100; attempt++ {
+		name := fmt.Sprintf("card%03d.png", next+attempt)
+		path = filepath.Join(cardImageDir, name)
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", "", err
+		}
+
+		err = png.Encode(file, img)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(path)
+			return "", "", err
+		}
+		return "/images/" + name, path, nil
+	}
+	return "", "", fmt.Errorf("没有可用的图片文件名")
+}
+
+// nextCardImageNumber 扫描图片目录，返回已有最大编号 + 1
+func nextCardImageNumber() (int, error) {
+	entries, err := os.ReadDir(cardImageDir)
+	if err != nil {
+		return 0, err
+	}
+
+	maxNumber := 0
+	for _, entry := range entries {
+		match := cardImageNamePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		if n, err := strconv.Atoi(match[1]); err == nil && n > maxNumber {
+			maxNumber = n
+		}
+	}
+	return maxNumber + 1, nil
+}
+
+// refreshImageList 重新生成 images/list.json（与 scripts/generate_list_json.sh 输出一致）
+func refreshImageList() error {
+	entries, err := os.ReadDir(cardImageDir)
+	if err != nil {
+		return err
+	}
+
+	images := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".png", ".jpg", ".jpeg":
+			images = append(images, "/images/"+entry.Name())
+		}
+	}
+	sort.Strings(images)
+
+	data, err := json.MarshalIndent(map[string]interface{}{"images": images}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// 先写临时文件再重命名，避免前端读到写了一半的文件
+	tmp := filepath.Join(cardImageDir, ".list.json.tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(cardImageDir, "list.json"))
+}
@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"h5project/achievement"
+	"h5project/auth"
+	"h5project/calendar"
+	"h5project/database"
+	"h5project/events"
+	"h5project/geo"
+	"h5project/models"
+	"h5project/presence"
+)
+
+// Checkin 地点签到接口
+// GET 返回当前游戏日的签到记录和抽卡资格；
+// POST {"latitude": ..., "longitude": ..., "accuracy": 定位精度, "code": 现场签到码, "location_id": 可选} 在范围内的打卡地点签到，
+// 定位精度太差、移动速度不可能、坐标与以前完全相同或缺少签到码的签到记为待审核；
+// POST {"token": 现场二维码凭证} 扫码签到，不需要定位（室内定位不可靠时使用）
+// 一天可以在多个地点签到，同一地点每天只记一次；启用位置校验时，当天至少签到一次才能抽卡
+func Checkin(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserIDFromRequest(r)
+	if err != nil {
+		sendError(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		today := calendar.Default().Today()
+		checkins, err := loadTodayCheckins(database.DB, userID, today)
+		if err != nil {
+			sendError(w, "查询失败", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(checkinResponse(database.DB, checkins))
+	case http.MethodPost:
+		var req models.CheckinRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, "无效的请求数据", http.StatusBadRequest)
+			return
+		}
+		// 开放时段按游戏时区判断
+		now := calendar.Default().Now()
+		var checkin locationCheckin
+		var locationName string
+		var distance float64
+		if req.Token != "" {
+			// 扫码签到：二维码签名证明用户在现场，不需要定位
+			locationID, err := presence.ParseToken(req.Token, time.Now())
+			if err != nil {
+				sendError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			locationName, err = findCheckinLocationByID(database.DB, locationID, now)
+			if err == sql.ErrNoRows {
+				sendError(w, "签到二维码对应的地点不存在", http.StatusNotFound)
+				return
+			}
+			var closed *locationClosedError
+			if errors.As(err, &closed) {
+				sendError(w, closed.Error(), http.StatusForbidden)
+				return
+			}
+			if err != nil {
+				sendError(w, "查询打卡地点失败", http.StatusInternalServerError)
+				return
+			}
+			checkin = locationCheckin{LocationID: locationID, Method: checkinMethodQR}
+		} else {
+			if req.Latitude == nil || req.Longitude == nil {
+				sendError(w, "需要提供位置信息才能签到", http.StatusBadRequest)
+				return
+			}
+			if !(geo.Point{Lat: *req.Latitude, Lng: *req.Longitude}).Valid() {
+				sendError(w, "无效的位置信息", http.StatusBadRequest)
+				return
+			}
+			if req.Accuracy != nil && !(*req.Accuracy >= 0) {
+				sendError(w, "无效的定位精度", http.StatusBadRequest)
+				return
+			}
+
+			var locationID int
+			locationID, locationName, distance, err = findCheckinLocation(database.DB, *req.Latitude, *req.Longitude, req.LocationID, now)
+			if err == sql.ErrNoRows {
+				sendError(w, fmt.Sprintf("您不在打卡地点范围内，请在以下地点签到：%s", checkinLocationNames(database.DB)), http.StatusForbidden)
+				return
+			}
+			var closed *locationClosedError
+			if errors.As(err, &closed) {
+				sendError(w, closed.Error(), http.StatusForbidden)
+				return
+			}
+			if err != nil {
+				sendError(w, "查询打卡地点失败", http.StatusInternalServerError)
+				return
+			}
+
+			checkin = locationCheckin{
+				LocationID: locationID,
+				Method:     checkinMethodGPS,
+				Latitude:   req.Latitude,
+				Longitude:  req.Longitude,
+				Accuracy:   req.Accuracy,
+				Code:       req.Code,
+			}
+			if err := verifyCheckinCode(checkin, time.Now()); err != nil {
+				sendError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		locationID := checkin.LocationID
+
+		// 当前游戏日，与抽卡使用同一时区和重置时间
+		today := calendar.Default().Today()
+
+		recorded, checkins, newAchievements, err := saveCheckin(userID, today, &checkin)
+		if err != nil {
+			log.Printf("❌ 用户 %d 签到失败: %v", userID, err)
+			sendError(w, "签到失败，请稍后重试", http.StatusInternalServerError)
+			return
+		}
+
+		// 这个地点今天的签到状态（没有替换已有签到时为已有签到的状态）
+		status := checkin.Status
+		for _, c := range checkins {
+			if c.LocationID == locationID {
+				status = c.Status
+			}
+		}
+
+		var message string
+		switch {
+		case recorded && status == models.CheckinFlagged:
+			reasons := make([]string, len(checkin.Flags))
+			for i, flag := range checkin.Flags {
+				reasons[i] = checkinFlagText(flag)
+			}
+			message = fmt.Sprintf("已在%s签到，但签到存在异常（%s），工作人员审核通过后才计入", locationName, strings.Join(reasons, "、"))
+			log.Printf("⚠️  用户 %d 在地点 %d 的签到可疑: %v", userID, locationID, checkin.Flags)
+		case recorded:
+			message = fmt.Sprintf("已在%s签到", locationName)
+		case status == models.CheckinFlagged:
+			message = fmt.Sprintf("今天在%s的签到正在等待工作人员审核", locationName)
+		case status == models.CheckinRejected:
+			message = fmt.Sprintf("今天在%s的签到未通过审核", locationName)
+		default:
+			message = fmt.Sprintf("今天已经在%s签到过了", locationName)
+		}
+
+		response := checkinResponse(database.DB, checkins)
+		response["success"] = true
+		response["message"] = message
+		response["already_checked_in"] = !recorded
+		response["status"] = status
+		location := map[string]interface{}{
+			"id":     locationID,
+			"name":   locationName,
+			"method": checkin.Method,
+		}
+		if checkin.Method == checkinMethodGPS {
+			location["distance"] = math.Round(distance)
+		}
+		response["location"] = location
+		response["new_achievements"] = newAchievements
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	default:
+		sendError(w, "方法不允许", http.StatusMethodNotAllowed)
+	}
+}
+
+// saveCheckin 在单独的事务中记录一次地点签到，返回是否新记录了签到、当天的签到记录和新解锁的成就
+// 可疑的签到记为待审核，审核通过前不计入抽卡资格和成就；签到码需要在调用前用 verifyCheckinCode 校验
+func saveCheckin(userID int, today string, checkin *locationCheckin) (recorded bool, checkins []models.TodayCheckin, newAchievements []models.AchievementStatus, err error) {
+	err = database.WithTx(func(tx *sql.Tx) error {
+		// 锁定用户行，与抽卡串行执行
+		var lockedID int
+		if err := tx.QueryRow("SELECT id FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&lockedID); err != nil {
+			return err
+		}
+
+		if err := assessCheckin(tx, userID, today, checkin); err != nil {
+			return err
+		}
+		var err error
+		recorded, err = recordLocationCheckin(tx, userID, today, *checkin)
+		if err != nil {
+			return err
+		}
+		if recorded && checkin.Status == models.CheckinAccepted {
+			newAchievements, err = publishEvents(tx, userID, events.CheckinRecorded)
+			if err != nil {
+				return err
+			}
+		}
+
+		checkins, err = loadTodayCheckins(tx, userID, today)
+		return err
+	})
+	return recorded, checkins, newAchievements, err
+}
+
+// checkinResponse 签到记录和由签到推导出的抽卡资格（待审核的签到通过审核后才计入）
+func checkinResponse(q database.DBTX, checkins []models.TodayCheckin) map[string]interface{} {
+	required := isLocationCheckEnabled(q)
+	checkedIn, pending := false, false
+	for _, c := range checkins {
+		switch c.Status {
+		case models.CheckinAccepted, models.CheckinApproved:
+			checkedIn = true
+		case models.CheckinFlagged:
+			pending = true
+		}
+	}
+	return map[string]interface{}{
+		"checkins":         checkins,
+		"checked_in_today": checkedIn,
+		"pending_review":   pending,
+		"checkin_required": required,
+		"can_draw":         !required || checkedIn,
+	}
+}
+
+// hasCheckedInToday 用户在某个游戏日是否至少有一次计入的地点签到
+func hasCheckedInToday(q database.DBTX, userID int, checkinDate string) (bool, error) {
+	var exists bool
+	err := q.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM location_checkins lc WHERE lc.user_id = $1 AND lc.checkin_date = $2 AND "+achievement.CountedCheckinsWhere+")",
+		userID, checkinDate,
+	).Scan(&exists)
+	return exists, err
+}
+
+// hasPendingCheckinToday 用户在某个游戏日是否有等待审核的地点签到
+func hasPendingCheckinToday(q database.DBTX, userID int, checkinDate string) (bool, error) {
+	var exists bool
+	err := q.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM location_checkins WHERE user_id = $1 AND checkin_date = $2 AND status = $3)",
+		userID, checkinDate, models.CheckinFlagged,
+	).Scan(&exists)
+	return exists, err
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"h5project/database"
+	"h5project/geo"
+	"h5project/models"
+	"h5project/presence"
+)
+
+// 签到可信度检查的默认阈值，可通过 system_config 覆盖
+const (
+	defaultCheckinMaxAccuracy = 100 // checkin_max_accuracy：定位精度超过该值（米）视为可疑
+	defaultCheckinMaxSpeed    = 200 // checkin_max_speed_kmh：与上一次签到之间的移动速度超过该值（公里/小时）视为可疑
+)
+
+// travelTolerance 移动距离在该范围（米）内时不检查速度，避免定位漂移造成误判
+const travelTolerance = 1000
+
+// errInvalidCheckinCode 提供了签到码但不是该地点当前的签到码
+var errInvalidCheckinCode = errors.New("签到码错误或已过期，请核对教堂现场展示的签到码")
+
+// positiveConfigInt 读取正整数配置，未设置或无效时返回默认值
+func positiveConfigInt(q database.DBTX, key string, def int) int {
+	var value string
+	if err := q.QueryRow("SELECT value FROM system_config WHERE key = $1", key).Scan(&value); err != nil {
+		return def
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || n <= 0 {
+		return def
+	}
+	return n
+}
+
+// isCheckinCodeRequired 签到是否需要现场签到码，读取失败时视为不需要
+func isCheckinCodeRequired(q database.DBTX) bool {
+	var required bool
+	err := q.QueryRow(
+		"SELECT value = 'true' FROM system_config WHERE key = 'checkin_code_required'",
+	).Scan(&required)
+	return err == nil && required
+}
+
+// verifyCheckinCode 校验签到码，没有提供签到码时不报错（是否需要由 assessCheckin 判断）
+func verifyCheckinCode(checkin locationCheckin, now time.Time) error {
+	if strings.TrimSpace(checkin.Code) == "" || presence.VerifyCode(checkin.LocationID, checkin.Code, now) {
+		return nil
+	}
+	return errInvalidCheckinCode
+}
+
+// assessCheckin 检查签到的可信度，设置 checkin 的 Status 和 Flags
+// 需要在锁定用户行后、记录签到前调用，使上一次签到的查询结果不受并发请求影响
+// 可疑的签到不会被拒绝，而是记为 flagged 等待审核；扫码签到已由二维码签名证明在现场，不再检查
+func assessCheckin(q database.DBTX, userID int, checkinDate string, checkin *locationCheckin) error {
+	checkin.Status = models.CheckinAccepted
+	checkin.Flags = nil
+	if checkin.Method == checkinMethodQR || checkin.Latitude == nil || checkin.Longitude == nil {
+		return nil
+	}
+	point := geo.Point{Lat: *checkin.Latitude, Lng: *checkin.Longitude}
+
+	var flags []string
+
+	// 定位精度
+	if checkin.Accuracy != nil && *checkin.Accuracy > float64(positiveConfigInt(q, "checkin_max_accuracy", defaultCheckinMaxAccuracy)) {
+		flags = append(flags, models.CheckinFlagLowAccuracy)
+	}
+
+	// 与上一次签到之间的移动速度
+	var prevLat, prevLng, elapsed float64
+	err := q.QueryRow(
+		`SELECT latitude, longitude, EXTRACT(EPOCH FROM (CURRENT_TIMESTAMP - created_at))
+		 FROM location_checkins
+		 WHERE user_id = $1 AND latitude IS NOT NULL AND longitude IS NOT NULL
+		   AND status <> $4 AND NOT (location_id = $2 AND checkin_date = $3)
+		 ORDER BY created_at DESC, id DESC
+		 LIMIT 1`,
+		userID, checkin.LocationID, checkinDate, models.CheckinRejected,
+	).Scan(&prevLat, &prevLng, &elapsed)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("查询上一次签到失败: %w", err)
+	}
+	if err == nil {
+		distance := geo.Distance(geo.Point{Lat: prevLat, Lng: prevLng}, point)
+		if elapsed < 1 {
+			elapsed = 1
+		}
+		maxSpeed := float64(positiveConfigInt(q, "checkin_max_speed_kmh", defaultCheckinMaxSpeed))
+		if distance > travelTolerance && distance/elapsed*3.6 > maxSpeed {
+			flags = append(flags, models.CheckinFlagTravelSpeed)
+		}
+	}
+
+	// 真实定位每次都会有细微差别，与以前的签到（包括其他用户）坐标完全相同说明坐标很可能是伪造的
+	var repeated bool
+	err = q.QueryRow(
+		`SELECT EXISTS(
+			SELECT 1 FROM location_checkins
+			WHERE latitude = round($1::numeric, 8) AND longitude = round($2::numeric, 8)
+			  AND NOT (user_id = $3 AND location_id = $4 AND checkin_date = $5)
+		 )`,
+		point.Lat, point.Lng, userID, checkin.LocationID, checkinDate,
+	).Scan(&repeated)
+	if err != nil {
+		return fmt.Errorf("查询重复坐标失败: %w", err)
+	}
+	if repeated {
+		flags = append(flags, models.CheckinFlagRepeatedCoord)
+	}
+
+	// 现场签到码（签到码错误时在此之前已经拒绝）
+	if strings.TrimSpace(checkin.Code) == "" && isCheckinCodeRequired(q) {
+		flags = append(flags, models.CheckinFlagMissingCode)
+	}
+
+	checkin.Flags = flags
+	if len(flags) > 0 {
+		checkin.Status = models.CheckinFlagged
+	}
+	return nil
+}
+
+// checkinFlagText 可疑签到原因的说明
+func checkinFlagText(flag string) string {
+	switch flag {
+	case models.CheckinFlagLowAccuracy:
+		return "定位精度太差"
+	case models.CheckinFlagTravelSpeed:
+		return "与上一次签到的距离和时间不符"
+	case models.CheckinFlagRepeatedCoord:
+		return "坐标与以前的签到完全相同"
+	case models.CheckinFlagMissingCode:
+		return "没有提供现场签到码"
+	default:
+		return flag
+	}
+}
@@ -3,10 +3,23 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 
+	"h5project/achievement"
 	"h5project/auth"
+	"h5project/calendar"
 	"h5project/database"
+	"h5project/geo"
+	"h5project/models"
+	"h5project/schedule"
+
+	"github.com/lib/pq"
 )
 
 // GetLocationSetting 获取位置校验设置
@@ -16,66 +29,82 @@ func GetLocationSetting(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"enabled": isLocationCheckEnabled(database.DB),
+	})
+}
+
+// isLocationCheckEnabled 是否启用位置校验（启用后需要先在打卡地点签到才能抽卡），读取失败时视为关闭
+func isLocationCheckEnabled(q database.DBTX) bool {
 	var enabled bool
-	err := database.DB.QueryRow(
+	err := q.QueryRow(
 		"SELECT value = 'true' FROM system_config WHERE key = 'location_check_enabled'",
 	).Scan(&enabled)
+	return err == nil && enabled
+}
+
+// GetCheckinLocations 获取所有打卡地点（含边界、开放时段和当前是否开放）
+func GetCheckinLocations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendError(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	locations, err := loadCheckinLocations(database.DB, 0)
 	if err != nil {
-		enabled = false
+		sendError(w, "查询失败", http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"enabled": enabled,
+		"locations": locations,
 	})
 }
 
-// GetCheckinLocations 获取所有打卡地点
-func GetCheckinLocations(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		sendError(w, "方法不允许", http.StatusMethodNotAllowed)
-		return
+// loadCheckinLocations 查询打卡地点，id 为 0 时返回所有地点
+func loadCheckinLocations(q database.DBTX, id int) ([]models.CheckinLocation, error) {
+	schedules, err := loadLocationSchedules(q)
+	if err != nil {
+		return nil, err
 	}
 
-	rows, err := database.DB.Query(
-		"SELECT id, name, latitude, longitude, radius_meters, achievement_code FROM checkin_locations ORDER BY id",
+	rows, err := q.Query(
+		`SELECT id, name, latitude, longitude, radius_meters, achievement_code, boundary, created_at
+		 FROM checkin_locations
+		 WHERE $1 = 0 OR id = $1
+		 ORDER BY id`,
+		id,
 	)
 	if err != nil {
-		sendError(w, "查询失败", http.StatusInternalServerError)
-		return
+		return nil, err
 	}
 	defer rows.Close()
 
-	var locations []map[string]interface{}
+	now := calendar.Default().Now()
+	locations := []models.CheckinLocation{}
 	for rows.Next() {
-		var id int
-		var name string
-		var latitude, longitude float64
-		var radiusMeters int
-		var achievementCode sql.NullString
-
-		err := rows.Scan(&id, &name, &latitude, &longitude, &radiusMeters, &achievementCode)
+		var loc models.CheckinLocation
+		var boundary []byte
+		err := rows.Scan(
+			&loc.ID, &loc.Name, &loc.Latitude, &loc.Longitude, &loc.RadiusMeters,
+			&loc.AchievementCode, &boundary, &loc.CreatedAt,
+		)
 		if err != nil {
-			continue
+			return nil, err
 		}
-
-		loc := map[string]interface{}{
-			"id":            id,
-			"name":          name,
-			"latitude":      latitude,
-			"longitude":     longitude,
-			"radius_meters": radiusMeters,
+		if boundary != nil {
+			loc.Boundary = json.RawMessage(boundary)
 		}
-		if achievementCode.Valid {
-			loc["achievement_code"] = achievementCode.String
+		loc.Schedules = schedules[loc.ID]
+		if loc.Schedules == nil {
+			loc.Schedules = []models.LocationSchedule{}
 		}
+		loc.OpenNow = buildSchedule(loc.ID, schedules[loc.ID]).Open(now)
 		locations = append(locations, loc)
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"locations": locations,
-	})
+	return locations, rows.Err()
 }
 
 // GetUserLocationCheckins 获取用户地点打卡统计
@@ -95,7 +124,7 @@ func GetUserLocationCheckins(w http.ResponseWriter, r *http.Request) {
 		`SELECT lc.location_id, cl.name, COUNT(*) as checkin_count
 		 FROM location_checkins lc
 		 JOIN checkin_locations cl ON lc.location_id = cl.id
-		 WHERE lc.user_id = $1
+		 WHERE lc.user_id = $1 AND `+achievement.CountedCheckinsWhere+`
 		 GROUP BY lc.location_id, cl.name
 		 ORDER BY lc.location_id`,
 		userID,
@@ -126,3 +155,383 @@ func GetUserLocationCheckins(w http.ResponseWriter, r *http.Request) {
 		"stats": stats,
 	})
 }
+
+// 地点签到方式
+const (
+	checkinMethodGPS = "gps" // 按定位签到
+	checkinMethodQR  = "qr"  // 扫描现场展示的二维码签到
+)
+
+// locationCheckin 一次地点签到
+type locationCheckin struct {
+	LocationID int
+	Method     string
+	Latitude   *float64 // 扫码签到没有坐标
+	Longitude  *float64
+	Accuracy   *float64 // 定位精度（米），旧版客户端不提供
+	Code       string   // 现场签到码
+
+	// 由 assessCheckin 设置
+	Status string
+	Flags  []string
+}
+
+// locationIndexTTL 打卡地点索引的最长缓存时间
+// 通过管理接口修改地点时立即失效；通过脚本直接修改数据库时最迟在这段时间后生效
+const locationIndexTTL = 5 * time.Minute
+
+// locationIndex 打卡地点的内存网格索引和开放时段，签到时在 Go 中按边界或 haversine 距离匹配地点
+var locationIndex struct {
+	sync.RWMutex
+	idx       *geo.Index
+	schedules map[int]schedule.Schedule // 地点ID -> 开放时段，没有时段的地点全天开放
+	loadedAt  time.Time
+}
+
+// checkinLocationIndex 获取打卡地点索引和开放时段，未加载或已过期时从数据库重新加载
+func checkinLocationIndex(q database.DBTX) (*geo.Index, map[int]schedule.Schedule, error) {
+	locationIndex.RLock()
+	idx, schedules, loadedAt := locationIndex.idx, locationIndex.schedules, locationIndex.loadedAt
+	locationIndex.RUnlock()
+	if idx != nil && time.Since(loadedAt) < locationIndexTTL {
+		return idx, schedules, nil
+	}
+
+	rows, err := q.Query("SELECT id, name, latitude, longitude, radius_meters, boundary FROM checkin_locations ORDER BY id")
+	if err != nil {
+		return nil, nil, fmt.Errorf("加载打卡地点失败: %w", err)
+	}
+	defer rows.Close()
+
+	var sites []geo.Site
+	for rows.Next() {
+		var s geo.Site
+		var radius int
+		var boundary []byte
+		if err := rows.Scan(&s.ID, &s.Name, &s.Center.Lat, &s.Center.Lng, &radius, &boundary); err != nil {
+			return nil, nil, fmt.Errorf("加载打卡地点失败: %w", err)
+		}
+		s.Radius = float64(radius)
+		if boundary != nil {
+			// 边界写入时已校验，这里解析失败说明数据被直接改过，退回按半径判定
+			if s.Area, err = geo.ParseGeoJSON(boundary); err != nil {
+				log.Printf("⚠️  打卡地点 %d 的边界无效，按半径判定: %v", s.ID, err)
+			}
+		}
+		sites = append(sites, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("加载打卡地点失败: %w", err)
+	}
+
+	rawSchedules, err := loadLocationSchedules(q)
+	if err != nil {
+		return nil, nil, fmt.Errorf("加载开放时段失败: %w", err)
+	}
+	schedules = make(map[int]schedule.Schedule, len(rawSchedules))
+	for id, items := range rawSchedules {
+		schedules[id] = buildSchedule(id, items)
+	}
+
+	idx = geo.NewIndex(sites, geo.DefaultCellDegrees)
+	locationIndex.Lock()
+	locationIndex.idx, locationIndex.schedules, locationIndex.loadedAt = idx, schedules, time.Now()
+	locationIndex.Unlock()
+	return idx, schedules, nil
+}
+
+// invalidateLocationIndex 打卡地点变更后使索引失效，下一次签到时重新加载
+func invalidateLocationIndex() {
+	locationIndex.Lock()
+	locationIndex.idx, locationIndex.schedules = nil, nil
+	locationIndex.Unlock()
+}
+
+// locationClosedError 坐标在打卡地点范围内，但地点当前不在开放时段
+type locationClosedError struct {
+	Name    string
+	Next    time.Time
+	HasNext bool
+}
+
+func (e *locationClosedError) Error() string {
+	if e.HasNext {
+		return fmt.Sprintf("%s当前不在开放时段，下次开放时间：%s", e.Name, e.Next.Format("01月02日 15:04"))
+	}
+	return fmt.Sprintf("%s当前不在开放时段", e.Name)
+}
+
+// findCheckinLocation 查找坐标所在打卡范围内、now 时刻开放的最近地点，locationID 大于 0 时只匹配该地点
+// 不在任何地点范围内时返回 sql.ErrNoRows；在范围内但地点都未开放时返回 *locationClosedError
+func findCheckinLocation(q database.DBTX, latitude, longitude float64, locationID int, now time.Time) (id int, name string, distance float64, err error) {
+	idx, schedules, err := checkinLocationIndex(q)
+	if err != nil {
+		return 0, "", 0, err
+	}
+
+	var closed *locationClosedError
+	for _, m := range idx.Within(geo.Point{Lat: latitude, Lng: longitude}) {
+		if locationID > 0 && m.ID != locationID {
+			continue
+		}
+		if schedules[m.ID].Open(now) {
+			return m.ID, m.Name, m.Distance, nil
+		}
+		// 多个地点都未开放时，提示最早开放的一个
+		next, ok := schedules[m.ID].NextOpen(now)
+		if closed == nil || (ok && (!closed.HasNext || next.Before(closed.Next))) {
+			closed = &locationClosedError{Name: m.Name, Next: next, HasNext: ok}
+		}
+	}
+	if closed != nil {
+		return 0, "", 0, closed
+	}
+	return 0, "", 0, sql.ErrNoRows
+}
+
+// findCheckinLocationByID 查找地点并检查 now 时刻是否开放，返回地点名称（扫码签到使用）
+// 地点不存在时返回 sql.ErrNoRows；地点未开放时返回 *locationClosedError
+func findCheckinLocationByID(q database.DBTX, locationID int, now time.Time) (string, error) {
+	idx, schedules, err := checkinLocationIndex(q)
+	if err != nil {
+		return "", err
+	}
+	for _, s := range idx.Sites() {
+		if s.ID != locationID {
+			continue
+		}
+		if schedules[s.ID].Open(now) {
+			return s.Name, nil
+		}
+		next, ok := schedules[s.ID].NextOpen(now)
+		return "", &locationClosedError{Name: s.Name, Next: next, HasNext: ok}
+	}
+	return "", sql.ErrNoRows
+}
+
+// loadLocationSchedules 所有打卡地点的开放时段，按地点ID分组
+func loadLocationSchedules(q database.DBTX) (map[int][]models.LocationSchedule, error) {
+	rows, err := q.Query(
+		`SELECT id, location_id, weekday, to_char(date, 'YYYY-MM-DD'),
+		        to_char(start_time, 'HH24:MI'), to_char(end_time, 'HH24:MI'), note
+		 FROM location_schedules
+		 ORDER BY location_id, date NULLS FIRST, weekday, start_time, id`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules := make(map[int][]models.LocationSchedule)
+	for rows.Next() {
+		var s models.LocationSchedule
+		var locationID int
+		var weekday sql.NullInt64
+		var date, note sql.NullString
+		if err := rows.Scan(&s.ID, &locationID, &weekday, &date, &s.StartTime, &s.EndTime, &note); err != nil {
+			return nil, err
+		}
+		if weekday.Valid {
+			wd := int(weekday.Int64)
+			s.Weekday = &wd
+		}
+		if date.Valid {
+			s.Date = &date.String
+		}
+		if note.Valid {
+			s.Note = &note.String
+		}
+		schedules[locationID] = append(schedules[locationID], s)
+	}
+	return schedules, rows.Err()
+}
+
+// buildSchedule 将数据库中的开放时段转换为 schedule.Schedule，跳过无效的时段
+func buildSchedule(locationID int, items []models.LocationSchedule) schedule.Schedule {
+	var s schedule.Schedule
+	for _, item := range items {
+		w, err := newScheduleWindow(item)
+		if err != nil {
+			log.Printf("⚠️  打卡地点 %d 的开放时段 %d 无效: %v", locationID, item.ID, err)
+			continue
+		}
+		s = append(s, w)
+	}
+	return s
+}
+
+// newScheduleWindow 校验并转换一个开放时段
+func newScheduleWindow(item models.LocationSchedule) (schedule.Window, error) {
+	date := ""
+	if item.Date != nil {
+		date = *item.Date
+	}
+	return schedule.NewWindow(item.Weekday, date, item.StartTime, item.EndTime)
+}
+
+// 打卡地点的有效半径（米），未设置时使用默认值
+const (
+	defaultLocationRadius = 500
+	minLocationRadius     = 20
+	maxLocationRadius     = 5000
+)
+
+// validateLocationRequest 校验并规范化地点请求，返回规范化后的边界（未设置时为 nil）
+func validateLocationRequest(req *models.LocationRequest) ([]byte, error) {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return nil, errors.New("地点名称不能为空")
+	}
+	if len([]rune(req.Name)) > 100 {
+		return nil, errors.New("地点名称不能超过 100 字")
+	}
+	if !(geo.Point{Lat: req.Latitude, Lng: req.Longitude}).Valid() {
+		return nil, errors.New("无效的经纬度")
+	}
+	if req.RadiusMeters == 0 {
+		req.RadiusMeters = defaultLocationRadius
+	}
+	if req.RadiusMeters < minLocationRadius || req.RadiusMeters > maxLocationRadius {
+		return nil, fmt.Errorf("有效半径必须在 %d~%d 米之间", minLocationRadius, maxLocationRadius)
+	}
+	if req.AchievementCode != nil {
+		if code := strings.TrimSpace(*req.AchievementCode); code == "" {
+			req.AchievementCode = nil
+		} else {
+			req.AchievementCode = &code
+		}
+	}
+
+	var boundary []byte
+	if len(req.Boundary) > 0 && string(req.Boundary) != "null" {
+		area, err := geo.ParseGeoJSON(req.Boundary)
+		if err != nil {
+			return nil, fmt.Errorf("无效的边界: %v", err)
+		}
+		if boundary, err = json.Marshal(area); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range req.Schedules {
+		item := &req.Schedules[i]
+		if item.Date != nil && *item.Date == "" {
+			item.Date = nil
+		}
+		if item.Note != nil {
+			if note := strings.TrimSpace(*item.Note); note == "" {
+				item.Note = nil
+			} else if len([]rune(note)) > 200 {
+				return nil, fmt.Errorf("第 %d 个开放时段的备注不能超过 200 字", i+1)
+			} else {
+				item.Note = &note
+			}
+		}
+		if _, err := newScheduleWindow(*item); err != nil {
+			return nil, fmt.Errorf("第 %d 个开放时段: %v", i+1, err)
+		}
+	}
+	return boundary, nil
+}
+
+// nullableJSON 写入 JSONB 列的参数，nil 写入 NULL（[]byte 会被驱动按 bytea 编码，需转换为字符串）
+func nullableJSON(data []byte) interface{} {
+	if data == nil {
+		return nil
+	}
+	return string(data)
+}
+
+// saveLocationSchedules 用 schedules 替换地点的所有开放时段
+func saveLocationSchedules(tx *sql.Tx, locationID int, schedules []models.LocationSchedule) error {
+	if _, err := tx.Exec("DELETE FROM location_schedules WHERE location_id = $1", locationID); err != nil {
+		return err
+	}
+	for _, s := range schedules {
+		_, err := tx.Exec(
+			`INSERT INTO location_schedules (location_id, weekday, date, start_time, end_time, note)
+			 VALUES ($1, $2, $3, $4, $5, $6)`,
+			locationID, s.Weekday, s.Date, s.StartTime, s.EndTime, s.Note,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkinLocationNames 所有打卡地点名称，用于提示用户可以去哪里签到
+func checkinLocationNames(q database.DBTX) string {
+	var names []string
+	rows, err := q.Query("SELECT name FROM checkin_locations ORDER BY id")
+	if err == nil {
+		defer rows.Close()
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err == nil {
+				names = append(names, name)
+			}
+		}
+	}
+	if len(names) == 0 {
+		return "指定地点"
+	}
+	return strings.Join(names, "、")
+}
+
+// recordLocationCheckin 记录地点签到，同一地点每个游戏日只记一次
+// 当天在该地点已有待审核的签到时，通过检查的新签到会替换它；其他情况下保留已有的签到
+// 返回是否新增或替换了签到记录（地点成就由调用方随后发布 checkin_recorded 事件更新）
+func recordLocationCheckin(q database.DBTX, userID int, checkinDate string, checkin locationCheckin) (bool, error) {
+	if checkin.Status == "" {
+		checkin.Status = models.CheckinAccepted
+	}
+	if checkin.Flags == nil {
+		checkin.Flags = []string{}
+	}
+	if checkin.Method == "" {
+		checkin.Method = checkinMethodGPS
+	}
+	result, err := q.Exec(
+		`INSERT INTO location_checkins (user_id, location_id, checkin_date, method, latitude, longitude, accuracy_meters, status, flags)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		 ON CONFLICT (user_id, location_id, checkin_date) DO UPDATE
+		 SET method = EXCLUDED.method, latitude = EXCLUDED.latitude, longitude = EXCLUDED.longitude,
+		     accuracy_meters = EXCLUDED.accuracy_meters, status = EXCLUDED.status, flags = EXCLUDED.flags,
+		     created_at = CURRENT_TIMESTAMP
+		 WHERE location_checkins.status = $10 AND EXCLUDED.status = $11`,
+		userID, checkin.LocationID, checkinDate, checkin.Method, checkin.Latitude, checkin.Longitude, checkin.Accuracy,
+		checkin.Status, pq.Array(checkin.Flags), models.CheckinFlagged, models.CheckinAccepted,
+	)
+	if err != nil {
+		return false, fmt.Errorf("记录地点签到失败: %w", err)
+	}
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
+
+// loadTodayCheckins 用户在某个游戏日的所有地点签到（包括待审核和未通过审核的），按签到时间排序
+func loadTodayCheckins(q database.DBTX, userID int, checkinDate string) ([]models.TodayCheckin, error) {
+	rows, err := q.Query(
+		`SELECT lc.location_id, cl.name, lc.status, lc.created_at
+		 FROM location_checkins lc
+		 JOIN checkin_locations cl ON cl.id = lc.location_id
+		 WHERE lc.user_id = $1 AND lc.checkin_date = $2
+		 ORDER BY lc.created_at, lc.id`,
+		userID, checkinDate,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	checkins := []models.TodayCheckin{}
+	for rows.Next() {
+		var c models.TodayCheckin
+		if err := rows.Scan(&c.LocationID, &c.LocationName, &c.Status, &c.CheckedInAt); err != nil {
+			return nil, err
+		}
+		checkins = append(checkins, c)
+	}
+	return checkins, rows.Err()
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"h5project/auth"
+	"h5project/database"
+	"h5project/points"
+)
+
+// GetPointsHistory 获取用户的兑换点流水
+// GET /api/user/points/history?limit=20&before_id=123（before_id 为上一页最后一条记录的ID）
+func GetPointsHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendError(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := auth.GetUserIDFromRequest(r)
+	if err != nil {
+		sendError(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	beforeID := 0
+	if v := r.URL.Query().Get("before_id"); v != "" {
+		beforeID, err = strconv.Atoi(v)
+		if err != nil || beforeID < 0 {
+			sendError(w, "无效的 before_id", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var balance int
+	err = database.DB.QueryRow("SELECT exchange_points FROM users WHERE id = $1", userID).Scan(&balance)
+	if err != nil {
+		sendError(w, "查询用户信息失败", http.StatusInternalServerError)
+		return
+	}
+
+	entries, err := points.History(database.DB, userID, limit, beforeID)
+	if err != nil {
+		sendError(w, "查询失败", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"exchange_points": balance,
+		"entries":         entries,
+	}
+	if len(entries) == limit {
+		response["next_before_id"] = entries[len(entries)-1].ID
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -5,7 +5,8 @@ import (
 	"hash/fnv"
 	"net/http"
 	"os"
-	"time"
+
+	"h5project/calendar"
 )
 
 type QuoteResponse struct {
@@ -41,8 +42,8 @@ func GetDailyQuote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 根据日期选择语录（确保每天都是同一句，所有人看到的都一样）
-	today := time.Now().Format("2006-01-02")
+	// 根据游戏日选择语录（确保每天都是同一句，所有人看到的都一样，与抽卡同时切换）
+	today := calendar.Default().Today()
 
 	// 使用日期字符串的哈希值来选择语录，确保同一天总是选择同一句
 	h := fnv.New32a()
@@ -0,0 +1,367 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"h5project/auth"
+	"h5project/calendar"
+	"h5project/database"
+	"h5project/events"
+	"h5project/models"
+	"h5project/points"
+)
+
+// itemColumns 查询奖品时使用的列（奖品表别名为 i），顺序与 scanItem 一致
+const itemColumns = "i.id, i.code, i.name, i.description, i.cost, i.stock, i.low_stock_threshold, i.period_limit, i.pickup_location, i.image_url, i.active, i.sort_order, i.created_at"
+
+// scanItem 按 itemColumns 的顺序扫描一个奖品，extra 追加在后面
+func scanItem(row rowScanner, item *models.RedeemableItem, extra ...interface{}) error {
+	var stock sql.NullInt64
+	dest := []interface{}{
+		&item.ID, &item.Code, &item.Name, &item.Description, &item.Cost, &stock, &item.LowStockThreshold,
+		&item.PeriodLimit, &item.PickupLocation, &item.ImageURL, &item.Active, &item.SortOrder, &item.CreatedAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+	item.Stock = nil
+	if stock.Valid {
+		n := int(stock.Int64)
+		item.Stock = &n
+	}
+	return nil
+}
+
+// errOutOfStock 奖品已兑完
+var errOutOfStock = errors.New("奖品已兑完")
+
+// redemptionLimitError 本周期兑换次数已达上限
+type redemptionLimitError struct {
+	itemName string // 为空表示所有奖品合计的上限
+	limit    int
+	lastAt   time.Time
+}
+
+func (e *redemptionLimitError) Error() string {
+	if e.itemName == "" {
+		return fmt.Sprintf("本周期已兑换 %d 次", e.limit)
+	}
+	return fmt.Sprintf("本周期已兑换 %s %d 次", e.itemName, e.limit)
+}
+
+// redemptionPeriodLimit 每个兑换周期所有奖品合计的兑换次数上限（system_config.redemption_period_limit）
+// 未配置时为 1，0 表示不限
+func redemptionPeriodLimit(q database.DBTX) int {
+	var value string
+	err := q.QueryRow("SELECT value FROM system_config WHERE key = 'redemption_period_limit'").Scan(&value)
+	if err != nil {
+		return 1
+	}
+	limit, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || limit < 0 {
+		return 1
+	}
+	return limit
+}
+
+// findRedeemableItem 按ID或代码查找上架的奖品，不存在或已下架时返回 sql.ErrNoRows
+func findRedeemableItem(q database.DBTX, req models.RedeemRequest) (models.RedeemableItem, error) {
+	var item models.RedeemableItem
+	err := scanItem(q.QueryRow(
+		"SELECT "+itemColumns+" FROM redeemable_items i WHERE i.active AND (i.id = $1 OR ($1 = 0 AND i.code = $2))",
+		req.ItemID, req.Type,
+	), &item)
+	return item, err
+}
+
+// checkRedemptionLimits 检查用户本周期的兑换次数是否已达上限（调用方需先锁定用户行）
+// 已取消或过期的兑换退还了兑换点，不计入次数
+func checkRedemptionLimits(q database.DBTX, userID int, item models.RedeemableItem, periodKey string) error {
+	var total, itemCount int
+	var lastAt sql.NullTime
+	err := q.QueryRow(
+		`SELECT COUNT(*), COUNT(*) FILTER (WHERE item_id = $3), MAX(redeemed_at)
+		 FROM redemption_records
+		 WHERE user_id = $1 AND redemption_month = $2 AND status IN ($4, $5)`,
+		userID, periodKey, item.ID, models.RedemptionPending, models.RedemptionFulfilled,
+	).Scan(&total, &itemCount, &lastAt)
+	if err != nil {
+		return err
+	}
+
+	if limit := redemptionPeriodLimit(q); limit > 0 && total >= limit {
+		return &redemptionLimitError{limit: limit, lastAt: lastAt.Time}
+	}
+	if item.PeriodLimit > 0 && itemCount >= item.PeriodLimit {
+		return &redemptionLimitError{itemName: item.Name, limit: item.PeriodLimit, lastAt: lastAt.Time}
+	}
+	return nil
+}
+
+// Redeem 兑换接口
+// 请求体 {"item_id": 1}；兼容旧版客户端的 {"type": "basic"}（按奖品代码查找）
+func Redeem(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendError(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := auth.GetUserIDFromRequest(r)
+	if err != nil {
+		sendError(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	// 解析请求体
+	var req models.RedeemRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, "请求格式错误", http.StatusBadRequest)
+		return
+	}
+	req.Type = strings.TrimSpace(req.Type)
+	if req.ItemID <= 0 && req.Type == "" {
+		sendError(w, "请选择要兑换的奖品", http.StatusBadRequest)
+		return
+	}
+
+	item, err := findRedeemableItem(database.DB, req)
+	if err == sql.ErrNoRows {
+		sendError(w, "奖品不存在或已下架", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		sendError(w, "查询失败", http.StatusInternalServerError)
+		return
+	}
+
+	// 获取当前兑换周期（按游戏日历计算，与抽卡使用同一时区和重置时间）
+	period := calendar.Default().CurrentPeriod()
+
+	// 检查次数、扣减库存、记录兑换、扣除兑换点（记录流水）和成就事件在同一个事务中完成
+	var redemptionID int
+	var redeemedAt, expiresAt time.Time
+	var voucherCode string
+	var remaining int
+	var newAchievements []models.AchievementStatus
+	err = database.WithTx(func(tx *sql.Tx) error {
+		// 锁定用户行，同一用户的兑换串行执行，次数检查不会被并发请求绕过
+		var lockedID int
+		if err := tx.QueryRow("SELECT id FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&lockedID); err != nil {
+			return err
+		}
+		if err := checkRedemptionLimits(tx, userID, item, period.Key); err != nil {
+			return err
+		}
+
+		// 扣减库存（不限库存的奖品 stock 为空，保持为空）
+		var stock sql.NullInt64
+		err := tx.QueryRow(
+			"UPDATE redeemable_items SET stock = stock - 1 WHERE id = $1 AND active AND (stock IS NULL OR stock > 0) RETURNING stock",
+			item.ID,
+		).Scan(&stock)
+		if err == sql.ErrNoRows {
+			return errOutOfStock
+		}
+		if err != nil {
+			return err
+		}
+		if stock.Valid {
+			remaining = int(stock.Int64)
+			item.Stock = &remaining
+		}
+
+		// 记录兑换
+		err = tx.QueryRow(
+			`INSERT INTO redemption_records (user_id, redemption_month, redemption_type, item_id, cost)
+			 VALUES ($1, $2, $3, $4, $5) RETURNING id, redeemed_at`,
+			userID, period.Key, item.Code, item.ID, item.Cost,
+		).Scan(&redemptionID, &redeemedAt)
+		if err != nil {
+			return err
+		}
+
+		// 生成兑换券，到领取地点出示给工作人员核销
+		voucherCode, expiresAt, err = issueVoucher(tx, redemptionID)
+		if err != nil {
+			return err
+		}
+
+		// 扣除兑换点，余额不足时整个兑换回滚
+		src := points.Source{Type: points.SourceRedemption, ID: redemptionID}
+		if _, err := points.Debit(tx, userID, item.Cost, points.ReasonRedemption, src); err != nil {
+			return err
+		}
+
+		newAchievements, err = publishEvents(tx, userID, events.PointsSpent)
+		return err
+	})
+
+	var limitErr *redemptionLimitError
+	if errors.As(err, &limitErr) {
+		message := fmt.Sprintf("%s最多兑换 %d 次，请%s再来", period.Every(), limitErr.limit, period.Next())
+		if limitErr.itemName != "" {
+			message = fmt.Sprintf("%s%s最多兑换 %d 次，请%s再来", limitErr.itemName, period.Every(), limitErr.limit, period.Next())
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":       period.Name + "已兑换",
+			"message":     message,
+			"redeemed_at": limitErr.lastAt.Format("2006-01-02 15:04:05"),
+		})
+		return
+	}
+	if errors.Is(err, errOutOfStock) {
+		sendError(w, item.Name+"已兑完", http.StatusConflict)
+		return
+	}
+	if errors.Is(err, points.ErrInsufficient) {
+		sendError(w, fmt.Sprintf("兑换点不足，需要至少%d个兑换点", item.Cost), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		log.Printf("❌ 用户 %d 兑换失败: %v", userID, err)
+		sendError(w, "兑换失败", http.StatusInternalServerError)
+		return
+	}
+	if item.Stock != nil && remaining <= item.LowStockThreshold {
+		log.Printf("⚠️  奖品 %s（%s）库存不足，剩余 %d 件", item.Code, item.Name, remaining)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":          true,
+		"message":          fmt.Sprintf("兑换成功！请在%s前到%s出示兑换券领取奖励", expiresAt.Format("2006-01-02"), item.PickupLocation),
+		"redemption_id":    redemptionID,
+		"voucher_code":     voucherCode,
+		"qr_url":           fmt.Sprintf("/api/user/redemptions/%d/qr", redemptionID),
+		"expires_at":       expiresAt.Format("2006-01-02 15:04:05"),
+		"redeemed_at":      redeemedAt.Format("2006-01-02 15:04:05"),
+		"type":             item.Code,
+		"item":             item,
+		"cost":             item.Cost,
+		"new_achievements": newAchievements,
+	})
+}
+
+// GetRedemptionInfo 获取兑换信息（兑换目录、每个奖品的领取地点和本周期兑换状态）
+func GetRedemptionInfo(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendError(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := auth.GetUserIDFromRequest(r)
+	if err != nil {
+		sendError(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	// 先处理已过期的兑换券，退还的兑换点和次数在下面的查询中生效
+	if _, err := ExpireVouchers(userID); err != nil {
+		log.Printf("⚠️  处理用户 %d 过期兑换券失败: %v", userID, err)
+	}
+
+	// 获取当前兑换周期（按游戏日历计算，与抽卡使用同一时区和重置时间）
+	period := calendar.Default().CurrentPeriod()
+	currentMonth := period.Key
+
+	// 获取用户兑换点
+	var exchangePoints int
+	database.DB.QueryRow(
+		"SELECT exchange_points FROM users WHERE id = $1",
+		userID,
+	).Scan(&exchangePoints)
+
+	// 本周期最近一次兑换
+	var redeemedAt sql.NullTime
+	var redeemedType sql.NullString
+	var redeemedCount int
+	database.DB.QueryRow(
+		`SELECT COUNT(*) OVER (), redeemed_at, redemption_type FROM redemption_records
+		 WHERE user_id = $1 AND redemption_month = $2 AND status IN ($3, $4)
+		 ORDER BY redeemed_at DESC LIMIT 1`,
+		userID, currentMonth, models.RedemptionPending, models.RedemptionFulfilled,
+	).Scan(&redeemedCount, &redeemedAt, &redeemedType)
+
+	periodLimit := redemptionPeriodLimit(database.DB)
+	periodFull := periodLimit > 0 && redeemedCount >= periodLimit
+
+	rows, err := database.DB.Query(
+		`SELECT `+itemColumns+`, COUNT(rr.id)
+		 FROM redeemable_items i
+		 LEFT JOIN redemption_records rr ON rr.item_id = i.id AND rr.user_id = $1 AND rr.redemption_month = $2 AND rr.status IN ($3, $4)
+		 WHERE i.active
+		 GROUP BY i.id
+		 ORDER BY i.sort_order, i.id`,
+		userID, currentMonth, models.RedemptionPending, models.RedemptionFulfilled,
+	)
+	if err != nil {
+		sendError(w, "查询失败", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	items := []models.RedeemableItemStatus{}
+	redeemedCodes := make(map[string]bool)
+	for rows.Next() {
+		var status models.RedeemableItemStatus
+		if err := scanItem(rows, &status.RedeemableItem, &status.RedeemedCount); err != nil {
+			continue
+		}
+		if status.RedeemedCount > 0 {
+			redeemedCodes[status.Code] = true
+		}
+
+		switch {
+		case periodFull:
+			status.Reason = period.Name + "已兑换"
+		case status.PeriodLimit > 0 && status.RedeemedCount >= status.PeriodLimit:
+			status.Reason = period.Name + "已达兑换上限"
+		case status.Stock != nil && *status.Stock <= 0:
+			status.Reason = "已兑完"
+		case exchangePoints < status.Cost:
+			status.Reason = "兑换点不足"
+		default:
+			status.CanRedeem = true
+		}
+		items = append(items, status)
+	}
+
+	// 兑换地点信息（旧版页面只显示一个地点，取第一个奖品的领取地点）
+	redemptionLocation := ""
+	if len(items) > 0 {
+		redemptionLocation = items[0].PickupLocation
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]interface{}{
+		"items":               items,
+		"period_limit":        periodLimit,
+		"redeemed_count":      redeemedCount,
+		"has_redeemed":        redeemedCount > 0,
+		"basic_redeemed":      redeemedCodes["basic"],
+		"premium_redeemed":    redeemedCodes["premium"],
+		"exchange_points":     exchangePoints,
+		"redemption_location": redemptionLocation,
+		"current_month":       currentMonth,
+		"period":              period,
+	}
+
+	if redeemedAt.Valid {
+		response["redeemed_at"] = redeemedAt.Time.Format("2006-01-02 15:04:05")
+		if redeemedType.Valid {
+			response["redeemed_type"] = redeemedType.String
+		}
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
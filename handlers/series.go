@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"h5project/achievement"
+	"h5project/calendar"
+	"h5project/database"
+	"h5project/models"
+	"h5project/points"
+
+	"github.com/lib/pq"
+)
+
+// seriesColumns 查询系列时使用的列，顺序与 scanSeries 一致
+const seriesColumns = "s.id, s.name, s.description, s.cover_image_url, s.sort_order, s.reward_points, s.created_at"
+
+// scanSeries 按 seriesColumns 的顺序扫描一个系列，extra 追加在后面
+func scanSeries(row rowScanner, series *models.CardSeries, extra ...interface{}) error {
+	dest := []interface{}{
+		&series.ID, &series.Name, &series.Description, &series.CoverImageURL,
+		&series.SortOrder, &series.RewardPoints, &series.CreatedAt,
+	}
+	return row.Scan(append(dest, extra...)...)
+}
+
+// loadSeriesProgress 获取用户在每个系列中的收集进度
+// 与 complete_all 一致，只统计用户可以获得过的卡片（见 achievement.ObtainableCardsWhere）
+func loadSeriesProgress(q database.DBTX, userID int) ([]models.SeriesProgress, error) {
+	rows, err := q.Query(
+		`SELECT `+seriesColumns+`, COUNT(c.id), COUNT(owned.card_id), sc.completed_at
+		 FROM card_series s
+		 INNER JOIN users u ON u.id = $1
+		 LEFT JOIN cards c ON c.series_id = s.id AND `+achievement.ObtainableCardsWhere+`
+		 LEFT JOIN (SELECT DISTINCT card_id FROM user_cards WHERE user_id = $1) owned ON owned.card_id = c.id
+		 LEFT JOIN series_completions sc ON sc.series_id = s.id AND sc.user_id = $1
+		 GROUP BY s.id, sc.completed_at
+		 ORDER BY s.sort_order, s.id`,
+		userID, calendar.Default().Now(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var progress []models.SeriesProgress
+	for rows.Next() {
+		var p models.SeriesProgress
+		var completedAt sql.NullTime
+		if err := scanSeries(rows, &p.Series, &p.TotalCount, &p.OwnedCount, &completedAt); err != nil {
+			return nil, err
+		}
+		p.Completed = p.TotalCount > 0 && p.OwnedCount >= p.TotalCount
+		if completedAt.Valid {
+			p.CompletedAt = &completedAt.Time
+		}
+		progress = append(progress, p)
+	}
+	return progress, rows.Err()
+}
+
+// checkSeriesCompletions 为新集齐的系列发放奖励，返回本次集齐的系列
+// 每个系列每个用户只奖励一次；之后系列新增卡片不会再次发放。q 应为事务，使集齐记录和奖励一起提交
+func checkSeriesCompletions(q database.DBTX, userID int) ([]models.SeriesProgress, error) {
+	series, err := loadSeriesProgress(q, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var completed []models.SeriesProgress
+	for _, progress := range series {
+		if !progress.Completed || progress.CompletedAt != nil {
+			continue
+		}
+
+		// 并发请求只有一个会插入成功，插入成功的请求发放奖励
+		var completionID int
+		var completedAt time.Time
+		err := q.QueryRow(
+			`INSERT INTO series_completions (user_id, series_id, reward_points)
+			 VALUES ($1, $2, $3)
+			 ON CONFLICT (user_id, series_id) DO NOTHING
+			 RETURNING id, completed_at`,
+			userID, progress.Series.ID, progress.Series.RewardPoints,
+		).Scan(&completionID, &completedAt)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("记录系列 %d 集齐失败: %w", progress.Series.ID, err)
+		}
+
+		src := points.Source{Type: points.SourceSeriesCompletion, ID: completionID}
+		if _, err := points.Credit(q, userID, progress.Series.RewardPoints, points.ReasonSeries, src); err != nil {
+			return nil, fmt.Errorf("发放系列 %d 集齐奖励失败: %w", progress.Series.ID, err)
+		}
+
+		progress.CompletedAt = &completedAt
+		completed = append(completed, progress)
+	}
+
+	return completed, nil
+}
+
+// grantSeriesCompletions 管理员调整卡片后，为因此集齐系列的用户发放奖励，返回发放奖励的次数
+// 卡片移出系列或下架后，已拥有系列中其余卡片的用户就集齐了该系列；只检查拥有这些系列卡片的用户
+func grantSeriesCompletions(q database.DBTX, seriesIDs ...*int) (int, error) {
+	var ids []int64
+	for _, id := range seriesIDs {
+		if id != nil {
+			ids = append(ids, int64(*id))
+		}
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	rows, err := q.Query(
+		`SELECT DISTINCT uc.user_id FROM user_cards uc
+		 INNER JOIN cards c ON c.id = uc.card_id
+		 WHERE c.series_id = ANY($1)`,
+		pq.Array(ids),
+	)
+	if err != nil {
+		return 0, err
+	}
+	var userIDs []int
+	for rows.Next() {
+		var userID int
+		if err := rows.Scan(&userID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	granted := 0
+	for _, userID := range userIDs {
+		completed, err := checkSeriesCompletions(q, userID)
+		if err != nil {
+			return granted, err
+		}
+		granted += len(completed)
+	}
+	return granted, nil
+}
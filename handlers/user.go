@@ -7,7 +7,9 @@ import (
 	"net/http"
 	"time"
 
+	"h5project/achievement"
 	"h5project/auth"
+	"h5project/calendar"
 	"h5project/database"
 	"h5project/models"
 
@@ -77,8 +79,13 @@ func Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 保存新用户的初始成就进度，成就页面只读取已保存的进度
+	if _, err := achievement.Evaluate(database.DB, userID, calendar.Default().Now()); err != nil {
+		log.Printf("⚠️  计算用户 %d 的初始成就进度失败: %v", userID, err)
+	}
+
 	// 生成token
-	token, err := auth.GenerateToken(userID, req.Username)
+	token, err := auth.GenerateToken(userID, req.Username, auth.RoleUser)
 	if err != nil {
 		sendError(w, "Token生成失败", http.StatusInternalServerError)
 		return
@@ -115,12 +122,12 @@ func Login(w http.ResponseWriter, r *http.Request) {
 	// 查询用户
 	var user models.User
 	err := database.DB.QueryRow(
-		"SELECT id, username, password_hash, holy_name, nickname, birthday, checkin_count, exchange_points FROM users WHERE username = $1",
+		"SELECT id, username, password_hash, holy_name, nickname, birthday, checkin_count, exchange_points, role FROM users WHERE username = $1",
 		req.Username,
 	).Scan(
 		&user.ID, &user.Username, &user.PasswordHash,
 		&user.HolyName, &user.Nickname, &user.Birthday,
-		&user.CheckinCount, &user.ExchangePoints,
+		&user.CheckinCount, &user.ExchangePoints, &user.Role,
 	)
 
 	if err == sql.ErrNoRows {
@@ -139,7 +146,7 @@ func Login(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 生成token
-	token, err := auth.GenerateToken(user.ID, user.Username)
+	token, err := auth.GenerateToken(user.ID, user.Username, user.Role)
 	if err != nil {
 		sendError(w, "Token生成失败", http.StatusInternalServerError)
 		return
@@ -245,11 +252,11 @@ func UpdateProfile(w http.ResponseWriter, r *http.Request) {
 func getUserByID(userID int) *models.User {
 	var user models.User
 	err := database.DB.QueryRow(
-		"SELECT id, username, holy_name, nickname, birthday, checkin_count, exchange_points, created_at, updated_at FROM users WHERE id = $1",
+		"SELECT id, username, holy_name, nickname, birthday, checkin_count, exchange_points, role, created_at, updated_at FROM users WHERE id = $1",
 		userID,
 	).Scan(
 		&user.ID, &user.Username, &user.HolyName, &user.Nickname,
-		&user.Birthday, &user.CheckinCount, &user.ExchangePoints,
+		&user.Birthday, &user.CheckinCount, &user.ExchangePoints, &user.Role,
 		&user.CreatedAt, &user.UpdatedAt,
 	)
 
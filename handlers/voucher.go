@@ -0,0 +1,390 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"h5project/auth"
+	"h5project/database"
+	"h5project/models"
+	"h5project/points"
+	"h5project/voucher"
+)
+
+// voucherQRSize 兑换券二维码图片边长（像素）
+const voucherQRSize = 320
+
+// redemptionColumns 查询兑换记录时使用的列（兑换记录别名为 rr，奖品别名为 i），顺序与 scanRedemption 一致
+const redemptionColumns = `rr.id, rr.user_id, rr.item_id, rr.redemption_type, COALESCE(i.name, rr.redemption_type), COALESCE(i.pickup_location, ''),
+	rr.cost, rr.redemption_month, rr.status, COALESCE(rr.voucher_code, ''), rr.redeemed_at, rr.expires_at,
+	rr.fulfilled_at, rr.cancelled_at, rr.cancel_reason`
+
+// scanRedemption 按 redemptionColumns 的顺序扫描一条兑换记录，extra 追加在后面
+func scanRedemption(row rowScanner, rec *models.Redemption, extra ...interface{}) error {
+	var itemID sql.NullInt64
+	var expiresAt, fulfilledAt, cancelledAt sql.NullTime
+	var cancelReason sql.NullString
+	dest := []interface{}{
+		&rec.ID, &rec.UserID, &itemID, &rec.ItemCode, &rec.ItemName, &rec.PickupLocation,
+		&rec.Cost, &rec.Period, &rec.Status, &rec.VoucherCode, &rec.RedeemedAt, &expiresAt,
+		&fulfilledAt, &cancelledAt, &cancelReason,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+
+	rec.ItemID, rec.ExpiresAt, rec.FulfilledAt, rec.CancelledAt, rec.CancelReason = nil, nil, nil, nil, nil
+	if itemID.Valid {
+		id := int(itemID.Int64)
+		rec.ItemID = &id
+	}
+	if expiresAt.Valid {
+		rec.ExpiresAt = &expiresAt.Time
+	}
+	if fulfilledAt.Valid {
+		rec.FulfilledAt = &fulfilledAt.Time
+	}
+	if cancelledAt.Valid {
+		rec.CancelledAt = &cancelledAt.Time
+	}
+	if cancelReason.Valid {
+		rec.CancelReason = &cancelReason.String
+	}
+	return nil
+}
+
+// voucherValidDays 兑换券有效天数（system_config.voucher_valid_days），未配置时为 30
+func voucherValidDays(q database.DBTX) int {
+	return positiveConfigInt(q, "voucher_valid_days", 30)
+}
+
+// issueVoucher 为刚创建的兑换记录生成兑换券，返回兑换券代码和过期时间
+func issueVoucher(q database.DBTX, redemptionID int) (string, time.Time, error) {
+	code, err := voucher.Issue(redemptionID)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	var expiresAt time.Time
+	err = q.QueryRow(
+		`UPDATE redemption_records
+		 SET voucher_code = $1, expires_at = redeemed_at + make_interval(days => $2)
+		 WHERE id = $3
+		 RETURNING expires_at`,
+		code, voucherValidDays(q), redemptionID,
+	).Scan(&expiresAt)
+	return code, expiresAt, err
+}
+
+// ExpireVouchers 将过期仍未领取的兑换记录标记为过期，并退还兑换点、恢复库存
+// userID 为 0 时处理所有用户；返回本次处理的兑换记录
+// 正在被其他事务处理（如工作人员正在核销）的记录会被跳过，留给下一次处理
+func ExpireVouchers(userID int) ([]models.Redemption, error) {
+	var expired []models.Redemption
+	err := database.WithTx(func(tx *sql.Tx) error {
+		rows, err := tx.Query(
+			`SELECT `+redemptionColumns+`
+			 FROM redemption_records rr
+			 LEFT JOIN redeemable_items i ON i.id = rr.item_id
+			 WHERE rr.status = $1 AND rr.expires_at < CURRENT_TIMESTAMP AND ($2 = 0 OR rr.user_id = $2)
+			 ORDER BY rr.id
+			 FOR UPDATE OF rr SKIP LOCKED`,
+			models.RedemptionPending, userID,
+		)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var rec models.Redemption
+			if err := scanRedemption(rows, &rec); err != nil {
+				rows.Close()
+				return err
+			}
+			expired = append(expired, rec)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, rec := range expired {
+			if _, err := tx.Exec("UPDATE redemption_records SET status = $1 WHERE id = $2", models.RedemptionExpired, rec.ID); err != nil {
+				return err
+			}
+			if err := refundRedemption(tx, rec); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rec := range expired {
+		log.Printf("✅ 兑换券 %d 过期未领取，已退还用户 %d 的 %d 个兑换点", rec.ID, rec.UserID, rec.Cost)
+	}
+	return expired, nil
+}
+
+// refundRedemption 退还兑换消耗的兑换点并恢复库存（兑换取消或过期时调用，调用方负责更新兑换记录状态）
+func refundRedemption(q database.DBTX, rec models.Redemption) error {
+	src := points.Source{Type: points.SourceRedemption, ID: rec.ID}
+	if _, err := points.Credit(q, rec.UserID, rec.Cost, points.ReasonRefund, src); err != nil {
+		return err
+	}
+	if rec.ItemID == nil {
+		return nil
+	}
+	_, err := q.Exec("UPDATE redeemable_items SET stock = stock + 1 WHERE id = $1 AND stock IS NOT NULL", *rec.ItemID)
+	return err
+}
+
+// GetUserRedemptions 获取用户的兑换记录和兑换券
+// GET /api/user/redemptions 列出兑换记录；GET /api/user/redemptions/{id}/qr 获取待领取兑换券的二维码 PNG
+func GetUserRedemptions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendError(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := auth.GetUserIDFromRequest(r)
+	if err != nil {
+		sendError(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	id, rest, hasID, err := parseAdminPath(r.URL.Path, "/api/user/redemptions")
+	if err != nil || (hasID && rest != "qr") {
+		sendError(w, "无效的兑换记录", http.StatusBadRequest)
+		return
+	}
+
+	// 先处理已过期的兑换券（退还兑换点），列表和二维码都只反映当前状态
+	if _, err := ExpireVouchers(userID); err != nil {
+		log.Printf("⚠️  处理用户 %d 过期兑换券失败: %v", userID, err)
+	}
+
+	if hasID {
+		sendVoucherQRCode(w, userID, id)
+		return
+	}
+
+	rows, err := database.DB.Query(
+		`SELECT `+redemptionColumns+`
+		 FROM redemption_records rr
+		 LEFT JOIN redeemable_items i ON i.id = rr.item_id
+		 WHERE rr.user_id = $1
+		 ORDER BY rr.redeemed_at DESC, rr.id DESC
+		 LIMIT 50`,
+		userID,
+	)
+	if err != nil {
+		sendError(w, "查询失败", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	redemptions := []models.Redemption{}
+	pending := 0
+	for rows.Next() {
+		var rec models.Redemption
+		if err := scanRedemption(rows, &rec); err != nil {
+			continue
+		}
+		if rec.Status == models.RedemptionPending {
+			pending++
+		} else {
+			// 已核销、过期或取消的兑换券不再展示代码
+			rec.VoucherCode = ""
+		}
+		redemptions = append(redemptions, rec)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"redemptions": redemptions,
+		"pending":     pending,
+	})
+}
+
+// sendVoucherQRCode 输出用户待领取兑换券的二维码 PNG
+func sendVoucherQRCode(w http.ResponseWriter, userID, redemptionID int) {
+	var code, status string
+	err := database.DB.QueryRow(
+		"SELECT COALESCE(voucher_code, ''), status FROM redemption_records WHERE id = $1 AND user_id = $2",
+		redemptionID, userID,
+	).Scan(&code, &status)
+	if err == sql.ErrNoRows {
+		sendError(w, "兑换记录不存在", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		sendError(w, "查询失败", http.StatusInternalServerError)
+		return
+	}
+	if status != models.RedemptionPending || code == "" {
+		sendError(w, "兑换券"+voucherStatusText(status), http.StatusGone)
+		return
+	}
+
+	png, err := voucher.QRCode(code, voucherQRSize)
+	if err != nil {
+		log.Printf("❌ 生成兑换券二维码失败: %v", err)
+		sendError(w, "生成二维码失败", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Write(png)
+}
+
+// voucherStatusText 兑换券状态的中文说明
+func voucherStatusText(status string) string {
+	switch status {
+	case models.RedemptionPending:
+		return "待领取"
+	case models.RedemptionFulfilled:
+		return "已核销"
+	case models.RedemptionExpired:
+		return "已过期"
+	case models.RedemptionCancelled:
+		return "已取消"
+	}
+	return "不可用"
+}
+
+// errVoucherState 兑换券当前状态不允许该操作
+var errVoucherState = errors.New("兑换券状态不允许该操作")
+
+// AdminVouchers 工作人员核销兑换券
+// POST /verify 校验兑换券并返回兑换详情（不修改状态）；POST /fulfill 核销；POST /cancel 取消并退还兑换点
+// 请求体 {"code": "R123-..."}，取消时可附带 "reason"
+func AdminVouchers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendError(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	action := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin/vouchers"), "/")
+	if action != "verify" && action != "fulfill" && action != "cancel" {
+		sendError(w, "未知的操作", http.StatusNotFound)
+		return
+	}
+
+	var req models.VoucherRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, "无效的请求数据", http.StatusBadRequest)
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+
+	// 先校验签名，伪造或输错的代码不查库
+	redemptionID, err := voucher.Parse(req.Code)
+	if err != nil {
+		sendError(w, voucher.ErrInvalid.Error(), http.StatusBadRequest)
+		return
+	}
+	staffID, err := auth.GetUserIDFromRequest(r)
+	if err != nil {
+		sendError(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	var rec models.Redemption
+	var username string
+	var nickname sql.NullString
+	var due bool
+	err = database.WithTx(func(tx *sql.Tx) error {
+		err := scanRedemption(tx.QueryRow(
+			`SELECT `+redemptionColumns+`, u.username, u.nickname,
+				rr.status = $3 AND rr.expires_at < CURRENT_TIMESTAMP
+			 FROM redemption_records rr
+			 JOIN users u ON u.id = rr.user_id
+			 LEFT JOIN redeemable_items i ON i.id = rr.item_id
+			 WHERE rr.id = $1 AND rr.voucher_code = $2
+			 FOR UPDATE OF rr`,
+			redemptionID, voucher.Normalize(req.Code), models.RedemptionPending,
+		), &rec, &username, &nickname, &due)
+		if err != nil {
+			return err
+		}
+		if due {
+			rec.Status = models.RedemptionExpired
+		}
+
+		switch {
+		case action == "verify":
+			return nil
+		case rec.Status != models.RedemptionPending:
+			return errVoucherState
+		case action == "fulfill":
+			_, err = tx.Exec(
+				`UPDATE redemption_records SET status = $1, fulfilled_at = CURRENT_TIMESTAMP, fulfilled_by = $2
+				 WHERE id = $3`,
+				models.RedemptionFulfilled, staffID, rec.ID,
+			)
+			if err != nil {
+				return err
+			}
+			rec.Status = models.RedemptionFulfilled
+		case action == "cancel":
+			_, err = tx.Exec(
+				`UPDATE redemption_records SET status = $1, cancelled_at = CURRENT_TIMESTAMP, cancelled_by = $2, cancel_reason = NULLIF($3, '')
+				 WHERE id = $4`,
+				models.RedemptionCancelled, staffID, req.Reason, rec.ID,
+			)
+			if err != nil {
+				return err
+			}
+			if err := refundRedemption(tx, rec); err != nil {
+				return err
+			}
+			rec.Status = models.RedemptionCancelled
+		}
+		return recordAudit(tx, r, action, "redemption", rec.ID, map[string]interface{}{
+			"user_id": rec.UserID,
+			"item":    rec.ItemCode,
+			"cost":    rec.Cost,
+			"reason":  req.Reason,
+		})
+	})
+
+	// 扫到已过期的兑换券时顺便处理（核销或取消失败时事务已回滚，单独执行）
+	if due {
+		if _, err := ExpireVouchers(rec.UserID); err != nil {
+			log.Printf("⚠️  处理用户 %d 过期兑换券失败: %v", rec.UserID, err)
+		}
+	}
+
+	if err == sql.ErrNoRows {
+		sendError(w, voucher.ErrInvalid.Error(), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, errVoucherState) {
+		sendError(w, "兑换券"+voucherStatusText(rec.Status), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("❌ 兑换券操作 %s 失败: %v", action, err)
+		sendError(w, "操作失败", http.StatusInternalServerError)
+		return
+	}
+
+	rec.VoucherCode = ""
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":     true,
+		"valid":       rec.Status == models.RedemptionPending,
+		"status_text": voucherStatusText(rec.Status),
+		"redemption":  rec,
+		"username":    username,
+		"nickname":    nickname.String,
+	})
+}
@@ -8,6 +8,7 @@ CREATE TABLE IF NOT EXISTS users (
     birthday DATE,
     checkin_count INTEGER DEFAULT 0,
     exchange_points INTEGER DEFAULT 0,
+    role VARCHAR(20) NOT NULL DEFAULT 'user', -- 'user'、'staff' 或 'admin'
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
@@ -15,6 +16,17 @@ CREATE TABLE IF NOT EXISTS users (
 -- 创建索引
 CREATE INDEX IF NOT EXISTS idx_username ON users(username);
 
+-- 卡片系列表
+CREATE TABLE IF NOT EXISTS card_series (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) UNIQUE NOT NULL,
+    description TEXT,
+    cover_image_url VARCHAR(255),
+    sort_order INTEGER NOT NULL DEFAULT 0, -- 展示顺序，越小越靠前
+    reward_points INTEGER NOT NULL DEFAULT 0, -- 集齐该系列奖励的兑换点
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
 -- 卡片表
 CREATE TABLE IF NOT EXISTS cards (
     id SERIAL PRIMARY KEY,
@@ -22,7 +34,12 @@ CREATE TABLE IF NOT EXISTS cards (
     image_url VARCHAR(255) NOT NULL,
     rarity VARCHAR(20) DEFAULT 'common',
     description TEXT,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    sort_order INTEGER NOT NULL DEFAULT 0, -- 展示顺序，越小越靠前
+    retired BOOLEAN NOT NULL DEFAULT FALSE, -- 已下架：不再进入卡池
+    series_id INTEGER REFERENCES card_series(id) ON DELETE SET NULL, -- 所属系列
+    available_from TIMESTAMPTZ,             -- 上架时间（限时卡片），为空表示不限制
+    available_until TIMESTAMPTZ             -- 下架时间（不含），为空表示不限制
 );
 
 -- 用户卡包表（记录用户拥有的卡片）
@@ -41,6 +58,7 @@ CREATE TABLE IF NOT EXISTS daily_draws (
     card_id INTEGER NOT NULL REFERENCES cards(id) ON DELETE CASCADE,
     draw_date DATE NOT NULL DEFAULT CURRENT_DATE,
     is_new_card BOOLEAN DEFAULT FALSE,
+    pity BOOLEAN NOT NULL DEFAULT FALSE, -- 是否由保底触发（保底后重新计算连续重复次数）
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     UNIQUE(user_id, draw_date)
 );
@@ -59,7 +77,14 @@ CREATE TABLE IF NOT EXISTS achievement_types (
     name VARCHAR(100) NOT NULL,
     description TEXT,
     reward_points INTEGER DEFAULT 1,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    -- 达成规则：指标 metric 达到 threshold（0 表示指标总量，如集齐全部卡片）
+    metric VARCHAR(50) NOT NULL DEFAULT '', -- distinct_cards / all_cards / checkin_days / distinct_locations / location_checkins / series_cards / series_completed / redemptions，为空表示不自动判定
+    threshold INTEGER NOT NULL DEFAULT 0,
+    repeatable BOOLEAN NOT NULL DEFAULT FALSE, -- 每达到一次 threshold 的整数倍奖励一次
+    auto_claim BOOLEAN NOT NULL DEFAULT FALSE, -- 达成后自动领取奖励
+    scope_type VARCHAR(20) NOT NULL DEFAULT '', -- 作用范围：location / series
+    scope_id INTEGER -- 作用的地点或系列ID；地点成就为空时使用 checkin_locations.achievement_code 关联
 );
 
 -- 用户成就记录表
@@ -72,32 +97,101 @@ CREATE TABLE IF NOT EXISTS user_achievements (
     UNIQUE(user_id, achievement_type_id)
 );
 
+-- 兑换目录表（圣物部可兑换的奖品，通过 /api/admin/redeemable-items 管理）
+CREATE TABLE IF NOT EXISTS redeemable_items (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(50) UNIQUE NOT NULL, -- 兑换时使用的代码，如 basic、premium
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    cost INTEGER NOT NULL CHECK (cost >= 0), -- 消耗的兑换点
+    stock INTEGER CHECK (stock >= 0), -- 剩余库存，为空表示不限
+    low_stock_threshold INTEGER NOT NULL DEFAULT 5 CHECK (low_stock_threshold >= 0), -- 剩余库存不超过该值时在管理后台提醒补货
+    period_limit INTEGER NOT NULL DEFAULT 1 CHECK (period_limit >= 0), -- 每个兑换周期每人最多兑换次数，0 表示不限
+    pickup_location VARCHAR(200) NOT NULL, -- 领取地点
+    image_url VARCHAR(255),
+    active BOOLEAN NOT NULL DEFAULT TRUE, -- 下架的奖品不出现在兑换目录中
+    sort_order INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
 -- 兑换记录表
+-- 每个周期的兑换次数由 system_config.redemption_period_limit（所有奖品合计）和 redeemable_items.period_limit（单个奖品）限制
+-- 兑换后生成签名的一次性兑换券，工作人员扫码核销；已取消或过期未领取的兑换退还兑换点，不计入次数
 CREATE TABLE IF NOT EXISTS redemption_records (
     id SERIAL PRIMARY KEY,
     user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
-    redemption_month VARCHAR(7) NOT NULL, -- 格式: YYYY-MM
-    redemption_type VARCHAR(20) NOT NULL DEFAULT 'basic', -- 'basic' 或 'premium'
+    redemption_month VARCHAR(50) NOT NULL, -- 兑换周期标识: YYYY-MM（每月）、YYYY-Www（每周）或 event:<活动标识>
+    redemption_type VARCHAR(50) NOT NULL DEFAULT 'basic', -- 兑换的奖品代码（redeemable_items.code）
+    item_id INTEGER REFERENCES redeemable_items(id),
+    cost INTEGER NOT NULL DEFAULT 0, -- 兑换时消耗的兑换点
     redeemed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     location_lat DECIMAL(10, 8),
     location_lng DECIMAL(11, 8),
-    UNIQUE(user_id, redemption_month) -- 一个月总共只能兑换一次
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'fulfilled', 'expired', 'cancelled')),
+    voucher_code VARCHAR(64) UNIQUE, -- 兑换券代码（R<ID>-<随机串>-<签名>）
+    expires_at TIMESTAMP, -- 兑换券过期时间，过期未领取的记录标记为 expired
+    fulfilled_at TIMESTAMP,
+    fulfilled_by INTEGER REFERENCES users(id) ON DELETE SET NULL, -- 核销的工作人员
+    cancelled_at TIMESTAMP,
+    cancelled_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    cancel_reason TEXT
 );
 
--- 里程碑领取记录表（用于追踪milestone_7的多次领取）
+-- 里程碑领取记录表（用于追踪可重复成就的多次领取，如 milestone_7）
 CREATE TABLE IF NOT EXISTS milestone_claims (
     id SERIAL PRIMARY KEY,
     user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
-    card_count INTEGER NOT NULL, -- 达到这个数量时领取的
+    achievement_type_id INTEGER NOT NULL REFERENCES achievement_types(id) ON DELETE CASCADE,
+    card_count INTEGER NOT NULL, -- 达到的里程碑（指标值，如卡片数）
     claimed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    UNIQUE(user_id, card_count)
+    UNIQUE(user_id, achievement_type_id, card_count)
+);
+
+-- 兑换点流水表（只追加，users.exchange_points 为余额缓存）
+CREATE TABLE IF NOT EXISTS points_ledger (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    delta INTEGER NOT NULL, -- 变动值，增加为正、扣除为负
+    balance_after INTEGER NOT NULL CHECK (balance_after >= 0), -- 变动后的余额
+    reason VARCHAR(30) NOT NULL, -- achievement / milestone / series / redemption / opening_balance / reconciliation
+    source_type VARCHAR(30), -- 来源记录所在的表：user_achievement / milestone_claim / series_completion / redemption
+    source_id INTEGER,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_points_ledger_user_id ON points_ledger(user_id, id);
+
+-- 用户成就进度表（由抽卡、打卡、兑换等领域事件更新，成就页面直接读取）
+CREATE TABLE IF NOT EXISTS user_achievement_progress (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    achievement_type_id INTEGER NOT NULL REFERENCES achievement_types(id) ON DELETE CASCADE,
+    progress JSONB NOT NULL, -- 进度：current / target / next_milestone / visited / remaining
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, achievement_type_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_achievement_progress_type ON user_achievement_progress(achievement_type_id);
+
+-- 系列集齐记录表（每个用户每个系列只奖励一次）
+CREATE TABLE IF NOT EXISTS series_completions (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    series_id INTEGER NOT NULL REFERENCES card_series(id) ON DELETE CASCADE,
+    reward_points INTEGER NOT NULL DEFAULT 0, -- 集齐时发放的兑换点
+    completed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id, series_id)
 );
 
 -- 创建索引
+CREATE INDEX IF NOT EXISTS idx_cards_series_id ON cards(series_id);
+CREATE INDEX IF NOT EXISTS idx_series_completions_user_id ON series_completions(user_id);
 CREATE INDEX IF NOT EXISTS idx_user_achievements_user_id ON user_achievements(user_id);
 CREATE INDEX IF NOT EXISTS idx_user_achievements_type_id ON user_achievements(achievement_type_id);
 CREATE INDEX IF NOT EXISTS idx_redemption_records_user_id ON redemption_records(user_id);
 CREATE INDEX IF NOT EXISTS idx_redemption_records_month ON redemption_records(redemption_month);
+CREATE INDEX IF NOT EXISTS idx_redemption_records_user_month ON redemption_records(user_id, redemption_month);
+CREATE INDEX IF NOT EXISTS idx_redemption_records_item_id ON redemption_records(item_id);
+CREATE INDEX IF NOT EXISTS idx_redemption_records_pending ON redemption_records(expires_at) WHERE status = 'pending';
 CREATE INDEX IF NOT EXISTS idx_milestone_claims_user_id ON milestone_claims(user_id);
 CREATE INDEX IF NOT EXISTS idx_milestone_claims_card_count ON milestone_claims(card_count);
 
@@ -109,17 +203,44 @@ CREATE TABLE IF NOT EXISTS checkin_locations (
     longitude DECIMAL(11, 8) NOT NULL,
     radius_meters INTEGER DEFAULT 500,
     achievement_code VARCHAR(50), -- 关联的成就代码
+    boundary JSONB, -- GeoJSON Polygon/MultiPolygon 边界（可选），设置后按边界判定是否在范围内，忽略 radius_meters
+    display_key_hash VARCHAR(64), -- 现场屏幕展示密钥的 SHA-256（十六进制），为空表示未生成
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
+-- 地点名称不区分大小写唯一
+CREATE UNIQUE INDEX IF NOT EXISTS idx_checkin_locations_name ON checkin_locations (lower(name));
+
+-- 打卡地点开放时段表（弥撒时间、瞻礼日等），地点没有任何时段时全天开放
+-- weekday（0 表示星期日）和 date 只能设置一个；end_time 不晚于 start_time 表示跨过午夜
+CREATE TABLE IF NOT EXISTS location_schedules (
+    id SERIAL PRIMARY KEY,
+    location_id INTEGER NOT NULL REFERENCES checkin_locations(id) ON DELETE CASCADE,
+    weekday SMALLINT CHECK (weekday BETWEEN 0 AND 6),
+    date DATE,
+    start_time TIME NOT NULL,
+    end_time TIME NOT NULL,
+    note VARCHAR(200),
+    CHECK ((weekday IS NULL) <> (date IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_location_schedules_location_id ON location_schedules(location_id);
+
 -- 用户地点打卡记录表
 CREATE TABLE IF NOT EXISTS location_checkins (
     id SERIAL PRIMARY KEY,
     user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
     location_id INTEGER NOT NULL REFERENCES checkin_locations(id) ON DELETE CASCADE,
     checkin_date DATE NOT NULL,
+    method VARCHAR(10) NOT NULL DEFAULT 'gps' CHECK (method IN ('gps', 'qr')), -- gps 按定位签到；qr 扫描现场二维码签到（没有坐标）
     latitude DECIMAL(10, 8),
     longitude DECIMAL(11, 8),
+    accuracy_meters REAL, -- 客户端报告的定位精度（米）
+    status VARCHAR(20) NOT NULL DEFAULT 'accepted' CHECK (status IN ('accepted', 'flagged', 'approved', 'rejected')), -- flagged 为可疑签到，审核通过前不计入
+    flags TEXT[] NOT NULL DEFAULT '{}', -- 可疑原因：low_accuracy / impossible_travel / repeated_coordinates / missing_code
+    reviewed_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_at TIMESTAMP,
+    review_note VARCHAR(200),
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     UNIQUE(user_id, location_id, checkin_date)
 );
@@ -127,6 +248,8 @@ CREATE TABLE IF NOT EXISTS location_checkins (
 CREATE INDEX IF NOT EXISTS idx_location_checkins_user_id ON location_checkins(user_id);
 CREATE INDEX IF NOT EXISTS idx_location_checkins_location_id ON location_checkins(location_id);
 CREATE INDEX IF NOT EXISTS idx_location_checkins_date ON location_checkins(checkin_date);
+CREATE INDEX IF NOT EXISTS idx_location_checkins_flagged ON location_checkins(created_at) WHERE status = 'flagged';
+CREATE INDEX IF NOT EXISTS idx_location_checkins_coordinates ON location_checkins(latitude, longitude);
 
 -- 系统配置表（用于控制定位功能开关等）
 CREATE TABLE IF NOT EXISTS system_config (
@@ -143,15 +266,56 @@ ON CONFLICT (key) DO UPDATE SET
     value = EXCLUDED.value,
     description = EXCLUDED.description;
 
+INSERT INTO system_config (key, value, description) VALUES
+    ('redemption_period_limit', '1', '每个兑换周期所有奖品合计最多兑换次数（0 表示不限）'),
+    ('voucher_valid_days', '30', '兑换券有效天数，过期未领取的兑换券作废')
+ON CONFLICT (key) DO NOTHING;
+
+INSERT INTO system_config (key, value, description) VALUES
+    ('checkin_code_required', 'false', '签到是否需要教堂现场展示的签到码，缺少签到码的签到需要审核（true/false）'),
+    ('checkin_max_accuracy', '100', '定位精度超过该值（米）的签到需要审核'),
+    ('checkin_max_speed_kmh', '200', '与上一次签到之间的移动速度超过该值（公里/小时）的签到需要审核')
+ON CONFLICT (key) DO NOTHING;
+
+-- 地点成就分级：每个打卡地点自动生成这些次数的成就（服务启动时和修改配置后补充）
+INSERT INTO system_config (key, value, description) VALUES
+    ('location_achievement_tiers', '5:初访者,15:朝圣者,50:守护者', '地点成就分级，逗号分隔的 次数:称号，每个打卡地点按此自动生成成就')
+ON CONFLICT (key) DO NOTHING;
+
+-- 默认兑换目录（原来的基础兑换和高级兑换）
+INSERT INTO redeemable_items (code, name, description, cost, period_limit, pickup_location, sort_order) VALUES
+    ('basic', '基础兑换', '钓圣人徽章机会', 1, 1, '罗源南门堂圣物部', 1),
+    ('premium', '高级兑换', '指定圣人徽章', 5, 1, '罗源南门堂圣物部', 2)
+ON CONFLICT (code) DO NOTHING;
+
+-- 抽卡概率配置表（运营可直接修改，下一次抽卡即生效，无需重新部署）
+CREATE TABLE IF NOT EXISTS draw_weights (
+    category VARCHAR(30) NOT NULL, -- 配置类别：policy 等
+    key VARCHAR(50) NOT NULL,
+    weight DOUBLE PRECISION NOT NULL,
+    description TEXT,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (category, key)
+);
+
+-- 插入默认抽卡配置（已存在的不覆盖，保留运营调整过的值）
+INSERT INTO draw_weights (category, key, weight, description) VALUES
+    ('policy', 'new_card_rate', 0.9, '抽到未拥有卡片的概率（0~1）'),
+    ('policy', 'pity_threshold', 5, '连续抽到重复卡多少次后下一次必出新卡（0 表示关闭保底）'),
+    ('rarity', 'common', 10, '普通卡单卡权重'),
+    ('rarity', 'rare', 4, '稀有卡单卡权重'),
+    ('rarity', 'legendary', 1, '传说卡单卡权重')
+ON CONFLICT (category, key) DO NOTHING;
+
 -- 插入默认成就类型
-INSERT INTO achievement_types (code, name, description, reward_points) VALUES
-    ('first_card', '一点星星之光', '获得任意第一张卡牌 (实体或数字)', 1),
-    ('pilgrim_nova', '朝圣新星', '累计在 3 个不同的教堂打卡成功', 1),
-    ('milestone_7', '收集天上的宝藏', '每 7 张不同卡片就会点亮一次', 1),
-    ('complete_all', '圣卡洛的圣体奇迹集', '集齐所有打卡图片', 3),
-    ('location_a_15', '稣稣的小羊', '在打卡点A累计打卡15次', 1),
-    ('location_b_15', '主的门徒', '在打卡点B累计打卡15次', 1),
-    ('location_c_15', '天主的子民', '在打卡点C累计打卡15次', 1)
+INSERT INTO achievement_types (code, name, description, reward_points, metric, threshold, repeatable, auto_claim, scope_type) VALUES
+    ('first_card', '一点星星之光', '获得任意第一张卡牌 (实体或数字)', 1, 'distinct_cards', 1, FALSE, FALSE, ''),
+    ('pilgrim_nova', '朝圣新星', '累计在 3 个不同的教堂打卡成功', 1, 'distinct_locations', 3, FALSE, FALSE, ''),
+    ('milestone_7', '收集天上的宝藏', '每 7 张不同卡片就会点亮一次', 1, 'distinct_cards', 7, TRUE, TRUE, ''),
+    ('complete_all', '圣卡洛的圣体奇迹集', '集齐所有打卡图片', 3, 'all_cards', 0, FALSE, FALSE, ''),
+    ('location_a_15', '稣稣的小羊', '在打卡点A累计打卡15次', 1, 'location_checkins', 15, FALSE, TRUE, 'location'),
+    ('location_b_15', '主的门徒', '在打卡点B累计打卡15次', 1, 'location_checkins', 15, FALSE, TRUE, 'location'),
+    ('location_c_15', '天主的子民', '在打卡点C累计打卡15次', 1, 'location_checkins', 15, FALSE, TRUE, 'location')
 ON CONFLICT (code) DO UPDATE SET 
     name = EXCLUDED.name,
     description = EXCLUDED.description,
@@ -183,3 +347,33 @@ CREATE INDEX IF NOT EXISTS idx_feedbacks_user_id ON feedbacks(user_id);
 CREATE INDEX IF NOT EXISTS idx_feedbacks_status ON feedbacks(status);
 CREATE INDEX IF NOT EXISTS idx_feedbacks_created_at ON feedbacks(created_at);
 
+
+-- 幂等键表（Idempotency-Key 对应的第一次响应，重试时直接重放）
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    idem_key VARCHAR(100) NOT NULL,
+    request_hash VARCHAR(64) NOT NULL, -- 方法+路径+请求体的指纹
+    status_code INTEGER, -- 为空表示第一次请求仍在处理中
+    content_type VARCHAR(100),
+    response_body BYTEA,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP,
+    PRIMARY KEY (user_id, idem_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created_at ON idempotency_keys(created_at);
+
+-- 管理操作审计日志表（/api/admin/* 的每一次修改都会记录）
+CREATE TABLE IF NOT EXISTS admin_audit_logs (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    username VARCHAR(50) NOT NULL DEFAULT '',
+    action VARCHAR(30) NOT NULL, -- create、update、delete 等
+    target_type VARCHAR(30) NOT NULL, -- card、location、achievement、config 等
+    target_id VARCHAR(100) NOT NULL DEFAULT '',
+    detail JSONB,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_admin_audit_logs_target ON admin_audit_logs(target_type, target_id);
+CREATE INDEX IF NOT EXISTS idx_admin_audit_logs_created_at ON admin_audit_logs(created_at);
@@ -3,17 +3,27 @@ package main
 import (
 	"log"
 	"net/http"
+	"time"
 
+	"h5project/achievement"
 	"h5project/auth"
+	"h5project/calendar"
 	"h5project/config"
 	"h5project/database"
+	"h5project/events"
 	"h5project/handlers"
 	"h5project/middleware"
+	"h5project/presence"
+	"h5project/voucher"
 )
 
 func main() {
 	// 加载配置
 	cfg := config.LoadConfig()
+	if err := cfg.CheckSecrets(); err != nil {
+		log.Fatal("配置错误: ", err)
+	}
+	auth.SetSecret(cfg.JWTSecret)
 
 	// 初始化数据库
 	if err := database.InitDB(); err != nil {
@@ -21,6 +31,39 @@ func main() {
 	}
 	defer database.CloseDB()
 
+	// 加载游戏日历（每日重置时间和时区）
+	if err := calendar.Reload(cfg, database.DB); err != nil {
+		log.Fatal("游戏日历加载失败:", err)
+	}
+
+	// 兑换券签名密钥
+	voucher.SetSecret(cfg.VoucherSecret, cfg.VoucherSecretPrevious)
+
+	// 打卡地点现场签到码签名密钥
+	presence.SetSecret(cfg.CheckinSecret)
+
+	// 成就订阅抽卡、打卡和兑换的领域事件，维护用户的成就进度
+	achievement.Subscribe(events.Default())
+
+	// 按分级配置为所有打卡地点补充地点成就（包括直接在数据库中新增的地点）
+	if created, err := achievement.EnsureAllLocationAchievements(database.DB); err != nil {
+		log.Printf("⚠️  生成地点成就失败: %v", err)
+	} else if created > 0 {
+		log.Printf("✅ 已生成 %d 个地点成就", created)
+		if err := achievement.RecomputeMetrics(database.DB, calendar.Default().Now(), achievement.MetricLocationCheckins); err != nil {
+			log.Printf("⚠️  计算新地点成就的进度失败: %v", err)
+		}
+	}
+
+	// 定时处理过期未领取的兑换券（标记过期、退还兑换点、恢复库存）
+	go func() {
+		for range time.Tick(time.Hour) {
+			if _, err := handlers.ExpireVouchers(0); err != nil {
+				log.Printf("⚠️  处理过期兑换券失败: %v", err)
+			}
+		}
+	}()
+
 	// 初始化卡片数据
 	if err := handlers.InitCards(); err != nil {
 		log.Printf("⚠️  卡片初始化失败: %v", err)
@@ -31,11 +74,24 @@ func main() {
 	// 创建限流器（每秒10个请求，突发20个）
 	rateLimiter := middleware.NewRateLimiter(10, 20)
 
+	// 创建幂等中间件（Idempotency-Key 对应的响应保存24小时）
+	idempotencyGuard := middleware.NewIdempotencyGuard(database.DB, 24*time.Hour)
+
 	// 辅助函数：组合限流和JWT中间件
 	withAuthAndRateLimit := func(handler http.HandlerFunc) http.HandlerFunc {
 		return rateLimiter.Limit(auth.JWTMiddleware(handler)).ServeHTTP
 	}
 
+	// 辅助函数：在限流和JWT认证之外再加上幂等保护（用于会修改数据的接口）
+	withIdempotency := func(handler http.HandlerFunc) http.HandlerFunc {
+		return withAuthAndRateLimit(idempotencyGuard.Guard(handler))
+	}
+
+	// 辅助函数：限流 + JWT认证 + 角色校验（用于管理后台接口）
+	withRole := func(handler http.HandlerFunc, roles ...string) http.HandlerFunc {
+		return withAuthAndRateLimit(auth.RequireRole(handler, roles...))
+	}
+
 	// 健康检查端点（不需要认证和限流）
 	http.HandleFunc("/health", handlers.HealthCheck)
 	http.HandleFunc("/api/health", handlers.HealthCheck)
@@ -45,25 +101,58 @@ func main() {
 	http.HandleFunc("/api/register", rateLimiter.Limit(http.HandlerFunc(handlers.Register)).ServeHTTP)
 	http.HandleFunc("/api/login", rateLimiter.Limit(http.HandlerFunc(handlers.Login)).ServeHTTP)
 	http.HandleFunc("/api/daily-quote", rateLimiter.Limit(http.HandlerFunc(handlers.GetDailyQuote)).ServeHTTP)
+	http.HandleFunc("/api/draw/odds", rateLimiter.Limit(http.HandlerFunc(handlers.GetDrawOdds)).ServeHTTP)
+	// 教堂现场屏幕凭地点的展示密钥获取签到码（不需要登录）
+	http.HandleFunc("/api/checkin-display/", rateLimiter.Limit(http.HandlerFunc(handlers.CheckinDisplay)).ServeHTTP)
 
 	// 需要认证的接口（限流 + JWT认证）
 	http.HandleFunc("/api/user/profile", withAuthAndRateLimit(handlers.GetProfile))
 	http.HandleFunc("/api/user/profile/update", withAuthAndRateLimit(handlers.UpdateProfile))
 	http.HandleFunc("/api/user/checkin-history", withAuthAndRateLimit(handlers.GetCheckinHistory))
+	http.HandleFunc("/api/user/points/history", withAuthAndRateLimit(handlers.GetPointsHistory))
 	http.HandleFunc("/api/draw/check", withAuthAndRateLimit(handlers.CheckTodayDraw))
-	http.HandleFunc("/api/draw", withAuthAndRateLimit(handlers.DrawCard))
+	http.HandleFunc("/api/draw/odds/me", withAuthAndRateLimit(handlers.GetMyDrawOdds))
+	http.HandleFunc("/api/checkin", withIdempotency(handlers.Checkin))
+	http.HandleFunc("/api/draw", withIdempotency(handlers.DrawCard))
 	http.HandleFunc("/api/user/cards", withAuthAndRateLimit(handlers.GetUserCards))
 	http.HandleFunc("/api/card/", withAuthAndRateLimit(handlers.HandleCardRequest))
 	http.HandleFunc("/api/achievements", withAuthAndRateLimit(handlers.GetAchievements))
-	http.HandleFunc("/api/claim-reward", withAuthAndRateLimit(handlers.ClaimReward))
-	http.HandleFunc("/api/redeem", withAuthAndRateLimit(handlers.Redeem))
+	http.HandleFunc("/api/claim-reward", withIdempotency(handlers.ClaimReward))
+	http.HandleFunc("/api/redeem", withIdempotency(handlers.Redeem))
 	http.HandleFunc("/api/redemption-info", withAuthAndRateLimit(handlers.GetRedemptionInfo))
-	http.HandleFunc("/api/feedback", withAuthAndRateLimit(handlers.SubmitFeedback))
+	http.HandleFunc("/api/user/redemptions", withAuthAndRateLimit(handlers.GetUserRedemptions))
+	http.HandleFunc("/api/user/redemptions/", withAuthAndRateLimit(handlers.GetUserRedemptions))
+	http.HandleFunc("/api/feedback", withIdempotency(handlers.SubmitFeedback))
 	http.HandleFunc("/api/feedbacks", withAuthAndRateLimit(handlers.GetFeedbacks))
 	http.HandleFunc("/api/location-setting", withAuthAndRateLimit(handlers.GetLocationSetting))
 	http.HandleFunc("/api/checkin-locations", withAuthAndRateLimit(handlers.GetCheckinLocations))
 	http.HandleFunc("/api/user/location-checkins", withAuthAndRateLimit(handlers.GetUserLocationCheckins))
 
+	// 管理后台接口（限流 + JWT认证 + 角色校验）
+	http.HandleFunc("/api/admin/config", withRole(handlers.AdminConfig, auth.RoleAdmin))
+	http.HandleFunc("/api/admin/draw-weights", withRole(handlers.AdminDrawWeights, auth.RoleAdmin))
+	http.HandleFunc("/api/admin/users", withRole(handlers.AdminUsers, auth.RoleAdmin))
+	http.HandleFunc("/api/admin/users/", withRole(handlers.AdminUsers, auth.RoleAdmin))
+	http.HandleFunc("/api/admin/audit-logs", withRole(handlers.AdminAuditLogs, auth.RoleAdmin))
+	http.HandleFunc("/api/admin/cards", withRole(handlers.AdminCards, auth.RoleAdmin))
+	http.HandleFunc("/api/admin/cards/", withRole(handlers.AdminCards, auth.RoleAdmin))
+	http.HandleFunc("/api/admin/cards/order", withRole(handlers.AdminCardOrder, auth.RoleAdmin))
+	http.HandleFunc("/api/admin/series", withRole(handlers.AdminSeries, auth.RoleAdmin))
+	http.HandleFunc("/api/admin/series/", withRole(handlers.AdminSeries, auth.RoleAdmin))
+	http.HandleFunc("/api/admin/locations", withRole(handlers.AdminLocations, auth.RoleAdmin))
+	http.HandleFunc("/api/admin/locations/", withRole(handlers.AdminLocations, auth.RoleAdmin))
+	http.HandleFunc("/api/admin/location-check", withRole(handlers.AdminLocationCheck, auth.RoleAdmin))
+	http.HandleFunc("/api/admin/redeemable-items", withRole(handlers.AdminRedeemableItems, auth.RoleAdmin))
+	http.HandleFunc("/api/admin/redeemable-items/", withRole(handlers.AdminRedeemableItems, auth.RoleAdmin))
+	http.HandleFunc("/api/admin/achievements", withRole(handlers.AdminAchievements, auth.RoleAdmin))
+	http.HandleFunc("/api/admin/achievements/", withRole(handlers.AdminAchievements, auth.RoleAdmin))
+	http.HandleFunc("/api/admin/vouchers/", withRole(handlers.AdminVouchers, auth.RoleStaff, auth.RoleAdmin))
+	http.HandleFunc("/api/admin/checkins", withRole(handlers.AdminCheckins, auth.RoleStaff, auth.RoleAdmin))
+	http.HandleFunc("/api/admin/checkins/", withRole(handlers.AdminCheckins, auth.RoleStaff, auth.RoleAdmin))
+	http.HandleFunc("/api/admin/checkin-codes/", withRole(handlers.AdminCheckinCodes, auth.RoleStaff, auth.RoleAdmin))
+	http.HandleFunc("/api/admin/feedbacks", withRole(handlers.AdminFeedbacks, auth.RoleStaff, auth.RoleAdmin))
+	http.HandleFunc("/api/admin/feedbacks/", withRole(handlers.AdminFeedbacks, auth.RoleStaff, auth.RoleAdmin))
+
 	// 图片目录
 	imageFs := http.FileServer(http.Dir("./images"))
 	http.Handle("/images/", http.StripPrefix("/images/", imageFs))
@@ -0,0 +1,245 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"h5project/auth"
+)
+
+// IdempotencyHeader 客户端用于标识一次操作的请求头
+const IdempotencyHeader = "Idempotency-Key"
+
+// maxIdempotencyKeyLength Idempotency-Key 的最大长度
+const maxIdempotencyKeyLength = 100
+
+// idempotencyLease 处理中的请求占用 key 的最长时间
+// 超过该时间仍没有保存响应（如进程在处理中退出），同一个 key 的重试请求可以接管并重新执行
+const idempotencyLease = time.Minute
+
+// IdempotencyGuard 幂等中间件
+// 同一用户使用同一个 Idempotency-Key 的重试请求不会再次执行，而是重放第一次的响应，
+// 避免弱网环境下重复提交导致兑换点重复增加或扣除
+type IdempotencyGuard struct {
+	db  *sql.DB
+	ttl time.Duration // 响应保存时长，过期后同一个 key 可以重新使用
+}
+
+// NewIdempotencyGuard 创建幂等中间件
+// ttl: 第一次响应的保存时长
+func NewIdempotencyGuard(db *sql.DB, ttl time.Duration) *IdempotencyGuard {
+	g := &IdempotencyGuard{
+		db:  db,
+		ttl: ttl,
+	}
+
+	// 启动清理协程
+	go g.cleanupExpired()
+
+	return g
+}
+
+// Guard 幂等中间件（需放在JWT认证之后）
+// 没有携带 Idempotency-Key 的请求按原样处理
+func (g *IdempotencyGuard) Guard(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyHeader)
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			writeJSONError(w, "Idempotency-Key 过长", http.StatusBadRequest)
+			return
+		}
+
+		userID, err := auth.GetUserIDFromRequest(r)
+		if err != nil {
+			writeJSONError(w, "未授权", http.StatusUnauthorized)
+			return
+		}
+
+		// 读取请求体计算指纹，再放回去供后续处理器使用
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeJSONError(w, "读取请求失败", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		requestHash := fingerprint(r, body)
+
+		claimed, err := g.claim(userID, key, requestHash)
+		if err != nil {
+			log.Printf("❌ [ERROR] 幂等键登记失败: %v", err)
+			writeJSONError(w, "服务器繁忙，请稍后重试", http.StatusInternalServerError)
+			return
+		}
+		if !claimed {
+			g.replay(w, userID, key, requestHash)
+			return
+		}
+
+		// 处理器 panic 时释放登记记录，客户端可以用同一个 key 重试
+		defer func() {
+			if p := recover(); p != nil {
+				g.release(userID, key)
+				panic(p)
+			}
+		}()
+
+		// 第一次请求：执行处理器并保存响应
+		recorder := &recordingWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next(recorder, r)
+		g.store(userID, key, recorder)
+	}
+}
+
+// claim 登记幂等键，返回当前请求是否为第一次请求
+// 已过期的旧记录，以及超过 idempotencyLease 仍未完成的记录会被当前请求接管
+func (g *IdempotencyGuard) claim(userID int, key, requestHash string) (bool, error) {
+	result, err := g.db.Exec(
+		`INSERT INTO idempotency_keys (user_id, idem_key, request_hash)
+		 VALUES ($1, $2, $3)
+		 ON CONFLICT (user_id, idem_key) DO UPDATE SET
+		 	request_hash = EXCLUDED.request_hash,
+		 	status_code = NULL,
+		 	content_type = NULL,
+		 	response_body = NULL,
+		 	created_at = CURRENT_TIMESTAMP,
+		 	completed_at = NULL
+		 WHERE idempotency_keys.created_at < $4
+		    OR (idempotency_keys.completed_at IS NULL AND idempotency_keys.created_at < $5)`,
+		userID, key, requestHash, time.Now().Add(-g.ttl), time.Now().Add(-idempotencyLease),
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// replay 重放第一次请求的响应
+func (g *IdempotencyGuard) replay(w http.ResponseWriter, userID int, key, requestHash string) {
+	var storedHash string
+	var statusCode sql.NullInt64
+	var contentType sql.NullString
+	var body []byte
+	err := g.db.QueryRow(
+		"SELECT request_hash, status_code, content_type, response_body FROM idempotency_keys WHERE user_id = $1 AND idem_key = $2",
+		userID, key,
+	).Scan(&storedHash, &statusCode, &contentType, &body)
+	if err == sql.ErrNoRows {
+		// 第一次请求失败后记录已被删除，让客户端重试
+		writeJSONError(w, "请求正在处理中，请稍后重试", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("❌ [ERROR] 查询幂等键失败: %v", err)
+		writeJSONError(w, "服务器繁忙，请稍后重试", http.StatusInternalServerError)
+		return
+	}
+
+	if storedHash != requestHash {
+		writeJSONError(w, "Idempotency-Key 已被用于其他请求", http.StatusUnprocessableEntity)
+		return
+	}
+	if !statusCode.Valid {
+		writeJSONError(w, "请求正在处理中，请稍后重试", http.StatusConflict)
+		return
+	}
+
+	if contentType.Valid && contentType.String != "" {
+		w.Header().Set("Content-Type", contentType.String)
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(int(statusCode.Int64))
+	w.Write(body)
+}
+
+// store 保存第一次请求的响应
+// 服务器错误不保存，删除登记记录以便客户端用同一个 key 重试
+func (g *IdempotencyGuard) store(userID int, key string, recorder *recordingWriter) {
+	if recorder.statusCode >= http.StatusInternalServerError {
+		g.release(userID, key)
+		return
+	}
+	_, err := g.db.Exec(
+		`UPDATE idempotency_keys
+		 SET status_code = $3, content_type = $4, response_body = $5, completed_at = CURRENT_TIMESTAMP
+		 WHERE user_id = $1 AND idem_key = $2`,
+		userID, key, recorder.statusCode, recorder.Header().Get("Content-Type"), recorder.body.Bytes(),
+	)
+	if err != nil {
+		log.Printf("❌ [ERROR] 保存幂等响应失败: %v", err)
+	}
+}
+
+// release 删除还没有保存响应的登记记录，客户端可以用同一个 key 重试
+func (g *IdempotencyGuard) release(userID int, key string) {
+	_, err := g.db.Exec(
+		"DELETE FROM idempotency_keys WHERE user_id = $1 AND idem_key = $2 AND completed_at IS NULL",
+		userID, key,
+	)
+	if err != nil {
+		log.Printf("❌ [ERROR] 释放幂等键失败: %v", err)
+	}
+}
+
+// cleanupExpired 定期清理过期的幂等记录
+func (g *IdempotencyGuard) cleanupExpired() {
+	for {
+		time.Sleep(g.ttl)
+		_, err := g.db.Exec(
+			"DELETE FROM idempotency_keys WHERE created_at < $1",
+			time.Now().Add(-g.ttl),
+		)
+		if err != nil {
+			log.Printf("⚠️  清理过期幂等记录失败: %v", err)
+		}
+	}
+}
+
+// fingerprint 请求指纹（方法 + 路径 + 请求体），防止同一个 key 被用于不同的请求
+func fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method))
+	h.Write([]byte{0})
+	h.Write([]byte(r.URL.Path))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recordingWriter 在写出响应的同时记录状态码和响应体
+type recordingWriter struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (rw *recordingWriter) WriteHeader(code int) {
+	rw.statusCode = code
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *recordingWriter) Write(b []byte) (int, error) {
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
+
+// writeJSONError 返回JSON格式的错误（与handlers中的错误格式保持一致）
+func writeJSONError(w http.ResponseWriter, message string, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
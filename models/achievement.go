@@ -11,6 +11,15 @@ type AchievementType struct {
 	Description  string    `json:"description" db:"description"`
 	RewardPoints int       `json:"reward_points" db:"reward_points"`
 	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+
+	// 达成规则（见 achievement 包），Metric 为空表示不自动判定
+	Metric     string `json:"metric" db:"metric"`
+	Threshold  int    `json:"threshold" db:"threshold"`   // 目标值，0 表示以指标总量为目标（如集齐全部卡片）
+	Repeatable bool   `json:"repeatable" db:"repeatable"` // 每达到一次 Threshold 的整数倍奖励一次
+	AutoClaim  bool   `json:"auto_claim" db:"auto_claim"` // 达成后自动领取奖励
+	ScopeType  string `json:"scope_type,omitempty" db:"scope_type"`
+	ScopeID    *int   `json:"scope_id,omitempty" db:"scope_id"`
+	ScopeName  string `json:"scope_name,omitempty" db:"-"` // 作用的地点或系列名称，仅查询成就状态时填充
 }
 
 type UserAchievement struct {
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type AuditLog struct {
+	ID         int             `json:"id" db:"id"`
+	UserID     int             `json:"user_id" db:"user_id"`
+	Username   string          `json:"username" db:"username"`
+	Action     string          `json:"action" db:"action"`           // create, update, delete ...
+	TargetType string          `json:"target_type" db:"target_type"` // card, location, achievement, config, feedback, user ...
+	TargetID   string          `json:"target_id" db:"target_id"`
+	Detail     json.RawMessage `json:"detail" db:"detail"`
+	CreatedAt  time.Time       `json:"created_at" db:"created_at"`
+}
+
+type SystemConfig struct {
+	Key         string    `json:"key" db:"key"`
+	Value       string    `json:"value" db:"value"`
+	Description *string   `json:"description" db:"description"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type UpdateConfigRequest struct {
+	Key         string  `json:"key"`
+	Value       string  `json:"value"`
+	Description *string `json:"description"`
+}
+
+type DrawWeight struct {
+	Category    string    `json:"category" db:"category"`
+	Key         string    `json:"key" db:"key"`
+	Weight      float64   `json:"weight" db:"weight"`
+	Description *string   `json:"description" db:"description"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type UpdateRoleRequest struct {
+	Role string `json:"role"`
+}
+
+type UpdateFeedbackRequest struct {
+	Status string `json:"status"` // pending, resolved
+}
+
+type AchievementTypeRequest struct {
+	Code         string  `json:"code"`
+	Name         string  `json:"name"`
+	Description  *string `json:"description"`
+	RewardPoints int     `json:"reward_points"`
+	Metric       string  `json:"metric"`
+	Threshold    int     `json:"threshold"`
+	Repeatable   bool    `json:"repeatable"`
+	AutoClaim    bool    `json:"auto_claim"`
+	ScopeType    string  `json:"scope_type"`
+	ScopeID      *int    `json:"scope_id"`
+}
+
+type UpdateCardRequest struct {
+	Name           string     `json:"name"`
+	ImageURL       string     `json:"image_url"`
+	Rarity         string     `json:"rarity"`
+	Description    *string    `json:"description"`
+	SeriesID       *int       `json:"series_id"`       // 为空表示不属于任何系列
+	AvailableFrom  *time.Time `json:"available_from"`  // 为空表示不限制
+	AvailableUntil *time.Time `json:"available_until"` // 为空表示不限制
+}
+
+type LocationRequest struct {
+	Name            string             `json:"name"`
+	Latitude        float64            `json:"latitude"`
+	Longitude       float64            `json:"longitude"`
+	RadiusMeters    int                `json:"radius_meters"`
+	AchievementCode *string            `json:"achievement_code"`
+	Boundary        json.RawMessage    `json:"boundary"`  // GeoJSON Polygon/MultiPolygon，为空表示按半径判定
+	Schedules       []LocationSchedule `json:"schedules"` // 开放时段，为空表示全天开放
+}
+
+// LocationCheckToggleRequest 设置位置校验开关，Enabled 为空时切换当前状态
+type LocationCheckToggleRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+type ReorderCardsRequest struct {
+	CardIDs []int `json:"card_ids"` // 按展示顺序排列的卡片ID
+}
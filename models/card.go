@@ -11,6 +11,13 @@ type Card struct {
 	Rarity      string    `json:"rarity" db:"rarity"`
 	Description *string   `json:"description" db:"description"`
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	SortOrder   int       `json:"sort_order" db:"sort_order"` // 展示顺序，越小越靠前
+	Retired     bool      `json:"retired" db:"retired"`       // 已下架：不再进入卡池，但保留在用户卡包中
+	SeriesID    *int      `json:"series_id" db:"series_id"`   // 所属系列，为空表示不属于任何系列
+
+	// 上架时间窗口（限时卡片，如圣人节日卡），为空表示不限制
+	AvailableFrom  *time.Time `json:"available_from,omitempty" db:"available_from"`
+	AvailableUntil *time.Time `json:"available_until,omitempty" db:"available_until"`
 }
 
 type UserCard struct {
@@ -32,8 +39,11 @@ type DailyDraw struct {
 type DrawResponse struct {
 	Card            Card                `json:"card"`
 	IsNewCard       bool                `json:"is_new_card"`
+	Rarity          string              `json:"rarity"`
+	PityTriggered   bool                `json:"pity_triggered,omitempty"`
 	Message         string              `json:"message"`
 	NewAchievements []AchievementStatus `json:"new_achievements,omitempty"`
+	CompletedSeries []SeriesProgress    `json:"completed_series,omitempty"` // 本次抽卡集齐的系列
 }
 
 type UserCardDetail struct {
@@ -42,6 +52,7 @@ type UserCardDetail struct {
 	ImageURL    string    `json:"image_url" db:"image_url"`
 	Rarity      string    `json:"rarity" db:"rarity"`
 	Description *string   `json:"description" db:"description"`
+	SeriesID    *int      `json:"series_id" db:"series_id"`
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
 	ObtainedAt  time.Time `json:"obtained_at" db:"obtained_at"`
 }
@@ -1,15 +1,22 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
+// CheckinLocation 打卡地点
 type CheckinLocation struct {
-	ID              int       `json:"id" db:"id"`
-	Name            string    `json:"name" db:"name"`
-	Latitude        float64   `json:"latitude" db:"latitude"`
-	Longitude       float64   `json:"longitude" db:"longitude"`
-	RadiusMeters    int       `json:"radius_meters" db:"radius_meters"`
-	AchievementCode string    `json:"achievement_code" db:"achievement_code"`
-	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+	ID              int                `json:"id" db:"id"`
+	Name            string             `json:"name" db:"name"`
+	Latitude        float64            `json:"latitude" db:"latitude"`
+	Longitude       float64            `json:"longitude" db:"longitude"`
+	RadiusMeters    int                `json:"radius_meters" db:"radius_meters"`
+	AchievementCode *string            `json:"achievement_code,omitempty" db:"achievement_code"`
+	Boundary        json.RawMessage    `json:"boundary,omitempty" db:"boundary"` // GeoJSON，为空表示按半径判定
+	Schedules       []LocationSchedule `json:"schedules"`                        // 开放时段，为空表示全天开放
+	OpenNow         bool               `json:"open_now"`                         // 当前是否开放
+	CreatedAt       time.Time          `json:"created_at" db:"created_at"`
 }
 
 type LocationCheckin struct {
@@ -22,7 +29,80 @@ type LocationCheckin struct {
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
 }
 
+// DrawCardRequest 抽卡请求
+// 位置信息只为兼容旧版客户端：启用位置校验时旧版客户端随抽卡请求提交位置，服务端先按位置签到再抽卡
 type DrawCardRequest struct {
 	Latitude  *float64 `json:"latitude"`
 	Longitude *float64 `json:"longitude"`
+	Accuracy  *float64 `json:"accuracy"` // 定位精度（米）
+	Code      string   `json:"code"`     // 现场签到码
+}
+
+// CheckinRequest 地点签到请求，LocationID 为 0 时签到到范围内最近的地点
+type CheckinRequest struct {
+	LocationID int      `json:"location_id"`
+	Latitude   *float64 `json:"latitude"`
+	Longitude  *float64 `json:"longitude"`
+	Accuracy   *float64 `json:"accuracy"` // 定位精度（米），即浏览器返回的 coords.accuracy
+	Code       string   `json:"code"`     // 教堂现场展示的签到码
+	Token      string   `json:"token"`    // 扫描现场二维码得到的凭证，提供时按扫码签到，不需要位置
+}
+
+// 地点签到的审核状态：通过可信度检查的签到直接计入（accepted），
+// 可疑的签到（flagged）等待工作人员审核，审核通过（approved）后才计入抽卡资格和成就
+const (
+	CheckinAccepted = "accepted"
+	CheckinFlagged  = "flagged"
+	CheckinApproved = "approved"
+	CheckinRejected = "rejected"
+)
+
+// 可疑签到的原因
+const (
+	CheckinFlagLowAccuracy   = "low_accuracy"         // 定位精度太差
+	CheckinFlagTravelSpeed   = "impossible_travel"    // 与上一次签到之间的移动速度不可能达到
+	CheckinFlagRepeatedCoord = "repeated_coordinates" // 与以前的签到坐标完全相同
+	CheckinFlagMissingCode   = "missing_code"         // 没有提供现场签到码
+)
+
+// FlaggedCheckin 等待审核（或已审核）的可疑签到
+type FlaggedCheckin struct {
+	ID           int        `json:"id"`
+	UserID       int        `json:"user_id"`
+	Username     string     `json:"username"`
+	LocationID   int        `json:"location_id"`
+	LocationName string     `json:"location_name"`
+	CheckinDate  string     `json:"checkin_date"`
+	Latitude     *float64   `json:"latitude"`
+	Longitude    *float64   `json:"longitude"`
+	Accuracy     *float64   `json:"accuracy"`
+	Status       string     `json:"status"`
+	Flags        []string   `json:"flags"`
+	Reasons      []string   `json:"reasons"` // Flags 的说明
+	CreatedAt    time.Time  `json:"created_at"`
+	ReviewedAt   *time.Time `json:"reviewed_at"`
+}
+
+// CheckinReviewRequest 审核可疑签到
+type CheckinReviewRequest struct {
+	Note string `json:"note"`
+}
+
+// TodayCheckin 用户在当前游戏日的一次地点签到
+type TodayCheckin struct {
+	LocationID   int       `json:"location_id"`
+	LocationName string    `json:"location_name"`
+	Status       string    `json:"status"`
+	CheckedInAt  time.Time `json:"checked_in_at"`
+}
+
+// LocationSchedule 打卡地点的开放时段（如弥撒时间、瞻礼日），只有在开放时段内的签到才计入
+// Weekday（0 表示星期日）和 Date（2006-01-02）只能设置一个；EndTime 不晚于 StartTime 表示跨过午夜
+type LocationSchedule struct {
+	ID        int     `json:"id,omitempty"`
+	Weekday   *int    `json:"weekday"`
+	Date      *string `json:"date"`
+	StartTime string  `json:"start_time"` // 15:04
+	EndTime   string  `json:"end_time"`   // 15:04，24:00 表示当天结束
+	Note      *string `json:"note"`
 }
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"time"
+)
+
+// RedeemableItem 兑换目录中的奖品
+type RedeemableItem struct {
+	ID                int       `json:"id" db:"id"`
+	Code              string    `json:"code" db:"code"` // 兑换时使用的代码，如 basic、premium
+	Name              string    `json:"name" db:"name"`
+	Description       *string   `json:"description" db:"description"`
+	Cost              int       `json:"cost" db:"cost"`                               // 消耗的兑换点
+	Stock             *int      `json:"stock" db:"stock"`                             // 剩余库存，为空表示不限
+	LowStockThreshold int       `json:"low_stock_threshold" db:"low_stock_threshold"` // 剩余库存不超过该值时提醒管理员补货
+	PeriodLimit       int       `json:"period_limit" db:"period_limit"`               // 每个兑换周期每人最多兑换次数，0 表示不限
+	PickupLocation    string    `json:"pickup_location" db:"pickup_location"`         // 领取地点
+	ImageURL          *string   `json:"image_url" db:"image_url"`
+	Active            bool      `json:"active" db:"active"` // 下架的奖品不出现在兑换目录中
+	SortOrder         int       `json:"sort_order" db:"sort_order"`
+	CreatedAt         time.Time `json:"created_at" db:"created_at"`
+}
+
+// RedeemableItemStatus 用户在本周期兑换某个奖品的状态
+type RedeemableItemStatus struct {
+	RedeemableItem
+	RedeemedCount int    `json:"redeemed_count"` // 本周期已兑换次数
+	CanRedeem     bool   `json:"can_redeem"`
+	Reason        string `json:"reason,omitempty"` // 不能兑换的原因
+}
+
+type RedeemRequest struct {
+	ItemID int    `json:"item_id"`
+	Type   string `json:"type"` // 奖品代码，兼容旧版客户端的 "basic" / "premium"
+}
+
+type RedeemableItemRequest struct {
+	Code              string  `json:"code"`
+	Name              string  `json:"name"`
+	Description       *string `json:"description"`
+	Cost              int     `json:"cost"`
+	Stock             *int    `json:"stock"` // 只在新增时设置，之后通过 POST /{id}/stock 调整
+	LowStockThreshold int     `json:"low_stock_threshold"`
+	PeriodLimit       int     `json:"period_limit"`
+	PickupLocation    string  `json:"pickup_location"`
+	ImageURL          *string `json:"image_url"`
+	Active            *bool   `json:"active"` // 为空时新增默认上架、修改保持不变
+	SortOrder         int     `json:"sort_order"`
+}
+
+// 兑换记录状态（redemption_records.status）
+const (
+	RedemptionPending   = "pending"   // 已兑换，等待到领取地点出示兑换券
+	RedemptionFulfilled = "fulfilled" // 工作人员已核销，奖品已领取
+	RedemptionExpired   = "expired"   // 兑换券过期未领取，兑换点已退还
+	RedemptionCancelled = "cancelled" // 工作人员取消，兑换点已退还
+)
+
+// Redemption 兑换记录及其兑换券
+type Redemption struct {
+	ID             int        `json:"id" db:"id"`
+	UserID         int        `json:"user_id" db:"user_id"`
+	ItemID         *int       `json:"item_id" db:"item_id"`
+	ItemCode       string     `json:"item_code" db:"redemption_type"`
+	ItemName       string     `json:"item_name" db:"-"`
+	PickupLocation string     `json:"pickup_location" db:"-"`
+	Cost           int        `json:"cost" db:"cost"`
+	Period         string     `json:"period" db:"redemption_month"`
+	Status         string     `json:"status" db:"status"`
+	VoucherCode    string     `json:"voucher_code,omitempty" db:"voucher_code"` // 只在待领取时返回给用户
+	RedeemedAt     time.Time  `json:"redeemed_at" db:"redeemed_at"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	FulfilledAt    *time.Time `json:"fulfilled_at,omitempty" db:"fulfilled_at"`
+	CancelledAt    *time.Time `json:"cancelled_at,omitempty" db:"cancelled_at"`
+	CancelReason   *string    `json:"cancel_reason,omitempty" db:"cancel_reason"`
+}
+
+type VoucherRequest struct {
+	Code   string `json:"code"`
+	Reason string `json:"reason"` // 取消原因（仅取消时使用）
+}
+
+// StockAdjustRequest 调整奖品库存，三种方式只能选一种：
+// delta 按增量补货或盘亏；unlimited=true 改为不限库存；unlimited=false 且设置 stock 时把不限库存的奖品改为限量
+type StockAdjustRequest struct {
+	Delta     int    `json:"delta"` // 正数为补货，负数为盘亏
+	Unlimited *bool  `json:"unlimited"`
+	Stock     *int   `json:"stock"` // 改为限量时的初始库存
+	Note      string `json:"note"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+)
+
+type CardSeries struct {
+	ID            int       `json:"id" db:"id"`
+	Name          string    `json:"name" db:"name"`
+	Description   *string   `json:"description" db:"description"`
+	CoverImageURL *string   `json:"cover_image_url" db:"cover_image_url"`
+	SortOrder     int       `json:"sort_order" db:"sort_order"`
+	RewardPoints  int       `json:"reward_points" db:"reward_points"` // 集齐该系列奖励的兑换点
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+// SeriesProgress 用户在一个系列中的收集进度
+type SeriesProgress struct {
+	Series      CardSeries `json:"series"`
+	OwnedCount  int        `json:"owned_count"`
+	TotalCount  int        `json:"total_count"`
+	Completed   bool       `json:"completed"`
+	CompletedAt *time.Time `json:"completed_at"` // 集齐并发放奖励的时间
+}
+
+type SeriesRequest struct {
+	Name          string  `json:"name"`
+	Description   *string `json:"description"`
+	CoverImageURL *string `json:"cover_image_url"`
+	SortOrder     int     `json:"sort_order"`
+	RewardPoints  int     `json:"reward_points"`
+}
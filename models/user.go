@@ -13,6 +13,7 @@ type User struct {
 	Birthday       *time.Time `json:"birthday" db:"birthday"`
 	CheckinCount   int        `json:"checkin_count" db:"checkin_count"`
 	ExchangePoints int        `json:"exchange_points" db:"exchange_points"`
+	Role           string     `json:"role" db:"role"`
 	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
 }
@@ -0,0 +1,261 @@
+package points
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"h5project/database"
+)
+
+// 兑换点变动原因（points_ledger.reason）
+const (
+	ReasonAchievement    = "achievement"     // 成就奖励（手动或自动领取），来源为 user_achievements
+	ReasonMilestone      = "milestone"       // 可重复成就的里程碑奖励，来源为 milestone_claims
+	ReasonSeries         = "series"          // 系列集齐奖励，来源为 series_completions
+	ReasonRedemption     = "redemption"      // 兑换消耗，来源为 redemption_records
+	ReasonRefund         = "refund"          // 兑换取消或过期未领取后退还，来源为 redemption_records
+	ReasonOpeningBalance = "opening_balance" // 启用流水前已有的兑换点
+	ReasonReconciliation = "reconciliation"  // 对账修正
+)
+
+// 来源记录所在的表（points_ledger.source_type）
+const (
+	SourceUserAchievement  = "user_achievement"
+	SourceMilestoneClaim   = "milestone_claim"
+	SourceSeriesCompletion = "series_completion"
+	SourceRedemption       = "redemption"
+)
+
+// ErrInsufficient 兑换点不足
+var ErrInsufficient = errors.New("兑换点不足")
+
+// Source 兑换点变动的来源记录，Type 为空表示没有来源记录（如对账修正）
+type Source struct {
+	Type string
+	ID   int
+}
+
+// Entry 一条兑换点流水
+type Entry struct {
+	ID           int       `json:"id"`
+	Delta        int       `json:"delta"`
+	BalanceAfter int       `json:"balance_after"`
+	Reason       string    `json:"reason"`
+	SourceType   string    `json:"source_type,omitempty"`
+	SourceID     *int      `json:"source_id,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Credit 增加兑换点并记录流水，返回变动后的余额
+func Credit(q database.DBTX, userID, amount int, reason string, src Source) (int, error) {
+	if amount < 0 {
+		return 0, fmt.Errorf("增加的兑换点不能为负数: %d", amount)
+	}
+	return apply(q, userID, amount, reason, src)
+}
+
+// Debit 扣除兑换点并记录流水，返回变动后的余额；余额不足时返回 ErrInsufficient
+func Debit(q database.DBTX, userID, amount int, reason string, src Source) (int, error) {
+	if amount < 0 {
+		return 0, fmt.Errorf("扣除的兑换点不能为负数: %d", amount)
+	}
+	return apply(q, userID, -amount, reason, src)
+}
+
+// apply 在同一条语句中更新 users.exchange_points 并追加流水，余额不会变为负数
+func apply(q database.DBTX, userID, delta int, reason string, src Source) (int, error) {
+	if delta == 0 {
+		var balance int
+		err := q.QueryRow("SELECT exchange_points FROM users WHERE id = $1", userID).Scan(&balance)
+		return balance, err
+	}
+
+	var sourceType sql.NullString
+	var sourceID sql.NullInt64
+	if src.Type != "" {
+		sourceType = sql.NullString{String: src.Type, Valid: true}
+		sourceID = sql.NullInt64{Int64: int64(src.ID), Valid: true}
+	}
+
+	var balance int
+	err := q.QueryRow(
+		`WITH updated AS (
+			UPDATE users SET exchange_points = exchange_points + $2
+			WHERE id = $1 AND exchange_points + $2 >= 0
+			RETURNING exchange_points
+		)
+		INSERT INTO points_ledger (user_id, delta, balance_after, reason, source_type, source_id)
+		SELECT $1, $2, exchange_points, $3, $4, $5 FROM updated
+		RETURNING balance_after`,
+		userID, delta, reason, sourceType, sourceID,
+	).Scan(&balance)
+	if err == sql.ErrNoRows {
+		if delta < 0 {
+			return 0, ErrInsufficient
+		}
+		return 0, fmt.Errorf("用户 %d 不存在", userID)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("记录兑换点流水失败: %w", err)
+	}
+	return balance, nil
+}
+
+// History 按时间倒序读取用户的兑换点流水，beforeID 大于 0 时只返回更早的记录（用于翻页）
+func History(q database.DBTX, userID, limit, beforeID int) ([]Entry, error) {
+	rows, err := q.Query(
+		`SELECT id, delta, balance_after, reason, COALESCE(source_type, ''), source_id, created_at
+		 FROM points_ledger
+		 WHERE user_id = $1 AND ($3 = 0 OR id < $3)
+		 ORDER BY id DESC
+		 LIMIT $2`,
+		userID, limit, beforeID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []Entry{}
+	for rows.Next() {
+		var e Entry
+		var sourceID sql.NullInt64
+		if err := rows.Scan(&e.ID, &e.Delta, &e.BalanceAfter, &e.Reason, &e.SourceType, &sourceID, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if sourceID.Valid {
+			id := int(sourceID.Int64)
+			e.SourceID = &id
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// Drift 缓存余额与流水不一致的用户
+type Drift struct {
+	UserID      int
+	Cached      int  // users.exchange_points
+	LedgerSum   int  // 流水变动之和
+	LastBalance *int // 最后一条流水记录的余额，没有流水时为空
+	Entries     int  // 流水条数
+}
+
+// FindDrift 找出 users.exchange_points 与流水之和或最后一条流水余额不一致的用户
+func FindDrift(q database.DBTX) ([]Drift, error) {
+	rows, err := q.Query(
+		`SELECT u.id, u.exchange_points, COALESCE(l.total, 0), COALESCE(l.entries, 0), last.balance_after
+		 FROM users u
+		 LEFT JOIN (
+			SELECT user_id, SUM(delta) AS total, COUNT(*) AS entries FROM points_ledger GROUP BY user_id
+		 ) l ON l.user_id = u.id
+		 LEFT JOIN LATERAL (
+			SELECT balance_after FROM points_ledger WHERE user_id = u.id ORDER BY id DESC LIMIT 1
+		 ) last ON true
+		 WHERE u.exchange_points <> COALESCE(l.total, 0)
+			OR u.exchange_points <> COALESCE(last.balance_after, 0)
+		 ORDER BY u.id`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var drifts []Drift
+	for rows.Next() {
+		var d Drift
+		var last sql.NullInt64
+		if err := rows.Scan(&d.UserID, &d.Cached, &d.LedgerSum, &d.Entries, &last); err != nil {
+			return nil, err
+		}
+		if last.Valid {
+			balance := int(last.Int64)
+			d.LastBalance = &balance
+		}
+		drifts = append(drifts, d)
+	}
+	return drifts, rows.Err()
+}
+
+// Reconcile 锁定用户后重新核对，缓存余额与流水合计不一致时把 users.exchange_points 重置为流水合计
+// 流水只追加不修改，以流水为准；返回是否修改了缓存余额
+func Reconcile(q database.DBTX, userID int) (bool, error) {
+	cached, ledgerSum, err := lockBalance(q, userID)
+	if err != nil {
+		return false, err
+	}
+	if cached == ledgerSum {
+		return false, nil
+	}
+	if ledgerSum < 0 {
+		return false, fmt.Errorf("用户 %d 的流水合计为 %d，不能作为余额，需要人工核查", userID, ledgerSum)
+	}
+
+	if _, err := q.Exec("UPDATE users SET exchange_points = $2 WHERE id = $1", userID, ledgerSum); err != nil {
+		return false, fmt.Errorf("重置用户 %d 的兑换点失败: %w", userID, err)
+	}
+	return true, nil
+}
+
+// AdjustLedger 锁定用户后重新核对，不一致时追加一条对账修正流水，使流水与缓存余额一致
+// 只在确认缓存余额才是正确的值时使用（如流水启用前的数据有误）；operator 和 note 记录在审计日志中
+func AdjustLedger(q database.DBTX, userID int, operator, note string) (bool, error) {
+	if operator == "" || note == "" {
+		return false, errors.New("修正流水需要提供操作人和原因")
+	}
+	cached, ledgerSum, err := lockBalance(q, userID)
+	if err != nil {
+		return false, err
+	}
+	if cached == ledgerSum {
+		return false, nil
+	}
+
+	delta := cached - ledgerSum
+	var entryID int64
+	err = q.QueryRow(
+		`INSERT INTO points_ledger (user_id, delta, balance_after, reason)
+		 VALUES ($1, $2, $3, $4) RETURNING id`,
+		userID, delta, cached, ReasonReconciliation,
+	).Scan(&entryID)
+	if err != nil {
+		return false, fmt.Errorf("记录用户 %d 的对账修正失败: %w", userID, err)
+	}
+
+	detail, err := json.Marshal(map[string]interface{}{
+		"reason":     note,
+		"cached":     cached,
+		"ledger_sum": ledgerSum,
+		"delta":      delta,
+		"entry_id":   entryID,
+	})
+	if err != nil {
+		return false, err
+	}
+	_, err = q.Exec(
+		`INSERT INTO admin_audit_logs (username, action, target_type, target_id, detail)
+		 VALUES ($1, 'adjust_ledger', 'points', $2, $3)`,
+		operator, fmt.Sprint(userID), string(detail),
+	)
+	if err != nil {
+		return false, fmt.Errorf("记录审计日志失败: %w", err)
+	}
+	return true, nil
+}
+
+// lockBalance 锁定用户行，返回缓存余额和流水合计
+func lockBalance(q database.DBTX, userID int) (cached, ledgerSum int, err error) {
+	err = q.QueryRow(
+		`SELECT u.exchange_points, (SELECT COALESCE(SUM(delta), 0) FROM points_ledger WHERE user_id = u.id)
+		 FROM users u WHERE u.id = $1
+		 FOR UPDATE OF u`,
+		userID,
+	).Scan(&cached, &ledgerSum)
+	if err != nil {
+		return 0, 0, fmt.Errorf("核对用户 %d 的兑换点失败: %w", userID, err)
+	}
+	return cached, ledgerSum, nil
+}
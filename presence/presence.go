@@ -0,0 +1,170 @@
+package presence
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// 打卡地点的现场凭证，在教堂现场展示，用于证明用户确实到过现场
+//
+// 签到码：HMAC-SHA256(密钥, "code:<地点ID>:<时段序号>") 按 HOTP 方式截取的 6 位数字，每 CodePeriod 更换一次，
+// 配合 GPS 签到手动输入
+//
+// 二维码凭证：L<地点ID>-<时段序号>-<签名>，签名为 HMAC-SHA256(密钥, "token:L<地点ID>-<时段序号>") 的前 10 字节，
+// 编码为不含填充的 base32；每 TokenPeriod 更换一次，扫码即可签到，不需要 GPS（室内定位不可靠时使用）
+//
+// 为避免用户看到后刚好赶上更换，上一个时段的签到码和二维码凭证同样有效
+//
+// 展示密钥：教堂现场屏幕获取签到码和二维码用的随机密钥，每个地点一个，数据库只保存哈希；
+// 只能读取该地点的签到码和二维码，屏幕上不需要登录工作人员账号，重新生成后旧密钥立即失效
+
+// CodePeriod 签到码的轮换周期
+const CodePeriod = 10 * time.Minute
+
+// TokenPeriod 二维码凭证的轮换周期
+// 周期越短，拍下二维码转发给不在现场的人越难得逞，但扫码后登录的时间也越紧
+const TokenPeriod = 2 * time.Minute
+
+// ErrInvalidToken 二维码凭证格式错误或签名不匹配
+var ErrInvalidToken = errors.New("无效的签到二维码")
+
+// ErrExpiredToken 二维码凭证已过期
+var ErrExpiredToken = errors.New("签到二维码已过期，请重新扫描现场的二维码")
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+var (
+	mu     sync.RWMutex
+	secret []byte
+)
+
+// SetSecret 设置签名密钥（启动时调用一次）
+func SetSecret(s string) {
+	mu.Lock()
+	defer mu.Unlock()
+	secret = []byte(s)
+}
+
+// Code 地点在 t 时刻的签到码
+func Code(locationID int, t time.Time) string {
+	return code(locationID, t.Unix()/int64(CodePeriod/time.Second))
+}
+
+// CodeExpiresAt t 时刻的签到码停止展示的时间（之后一个周期内仍然有效）
+func CodeExpiresAt(t time.Time) time.Time {
+	period := int64(CodePeriod / time.Second)
+	return time.Unix((t.Unix()/period+1)*period, 0)
+}
+
+// VerifyCode 校验签到码是否为地点当前或上一个时段的签到码，忽略空白
+func VerifyCode(locationID int, input string, t time.Time) bool {
+	input = strings.Join(strings.Fields(input), "")
+	if input == "" {
+		return false
+	}
+	counter := t.Unix() / int64(CodePeriod/time.Second)
+	for _, c := range []int64{counter, counter - 1} {
+		if hmac.Equal([]byte(input), []byte(code(locationID, c))) {
+			return true
+		}
+	}
+	return false
+}
+
+// Token 地点在 t 时刻的二维码凭证
+func Token(locationID int, t time.Time) string {
+	payload := fmt.Sprintf("L%d-%d", locationID, t.Unix()/int64(TokenPeriod/time.Second))
+	return payload + "-" + sign(payload)
+}
+
+// TokenExpiresAt t 时刻的二维码凭证停止展示的时间（之后一个周期内仍然有效）
+func TokenExpiresAt(t time.Time) time.Time {
+	period := int64(TokenPeriod / time.Second)
+	return time.Unix((t.Unix()/period+1)*period, 0)
+}
+
+// ParseToken 校验二维码凭证，返回对应的地点ID
+// 签名不匹配返回 ErrInvalidToken；不是当前或上一个时段的凭证返回 ErrExpiredToken
+func ParseToken(token string, t time.Time) (int, error) {
+	token = strings.ToUpper(strings.TrimSpace(token))
+	i := strings.LastIndex(token, "-")
+	if i < 0 || !strings.HasPrefix(token, "L") {
+		return 0, ErrInvalidToken
+	}
+	payload, sig := token[:i], token[i+1:]
+	if !hmac.Equal([]byte(sig), []byte(sign(payload))) {
+		return 0, ErrInvalidToken
+	}
+
+	parts := strings.SplitN(payload[1:], "-", 2)
+	if len(parts) != 2 {
+		return 0, ErrInvalidToken
+	}
+	locationID, err := strconv.Atoi(parts[0])
+	if err != nil || locationID <= 0 {
+		return 0, ErrInvalidToken
+	}
+	counter, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+	current := t.Unix() / int64(TokenPeriod/time.Second)
+	if counter != current && counter != current-1 {
+		return 0, ErrExpiredToken
+	}
+	return locationID, nil
+}
+
+// NewDisplayKey 生成一个新的展示密钥，返回密钥和保存到数据库的哈希
+func NewDisplayKey() (key, hash string, err error) {
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("生成展示密钥失败: %w", err)
+	}
+	key = encoding.EncodeToString(buf)
+	return key, HashDisplayKey(key), nil
+}
+
+// HashDisplayKey 展示密钥的哈希（SHA-256，十六进制），忽略大小写和空白
+func HashDisplayKey(key string) string {
+	sum := sha256.Sum256([]byte(strings.ToUpper(strings.TrimSpace(key))))
+	return hex.EncodeToString(sum[:])
+}
+
+// QRCode 生成内容为 content 的二维码 PNG 图片
+func QRCode(content string, size int) ([]byte, error) {
+	return qrcode.Encode(content, qrcode.Medium, size)
+}
+
+func sign(payload string) string {
+	mu.RLock()
+	mac := hmac.New(sha256.New, secret)
+	mu.RUnlock()
+	mac.Write([]byte("token:" + payload))
+	return encoding.EncodeToString(mac.Sum(nil)[:10])
+}
+
+// code 地点在第 counter 个时段的签到码
+func code(locationID int, counter int64) string {
+	mu.RLock()
+	mac := hmac.New(sha256.New, secret)
+	mu.RUnlock()
+	fmt.Fprintf(mac, "code:%d:%d", locationID, counter)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", value%1000000)
+}
@@ -0,0 +1,137 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DateFormat 日期格式
+const DateFormat = "2006-01-02"
+
+// Window 一个开放时段
+// 每周固定的时段（如主日弥撒）设置 Weekday，某一天的时段（如瞻礼日）设置 Date，两者只能设置一个
+// Start、End 为当天零点起的分钟数，时段为 [Start, End)；End 不大于 Start 表示跨过午夜，结束于次日
+type Window struct {
+	Weekday *time.Weekday
+	Date    string
+	Start   int
+	End     int
+}
+
+// Schedule 地点的开放时段，没有任何时段表示全天开放
+type Schedule []Window
+
+// NewWindow 创建并校验开放时段，start、end 格式为 15:04
+func NewWindow(weekday *int, date, start, end string) (Window, error) {
+	var w Window
+	switch {
+	case weekday != nil && date != "":
+		return w, errors.New("每周时段和指定日期只能设置一个")
+	case weekday != nil:
+		if *weekday < 0 || *weekday > 6 {
+			return w, fmt.Errorf("无效的星期 %d（0 表示星期日，6 表示星期六）", *weekday)
+		}
+		wd := time.Weekday(*weekday)
+		w.Weekday = &wd
+	case date != "":
+		if _, err := time.Parse(DateFormat, date); err != nil {
+			return w, fmt.Errorf("无效的日期 %q，格式为 2006-01-02", date)
+		}
+		w.Date = date
+	default:
+		return w, errors.New("需要设置星期或日期")
+	}
+
+	var err error
+	if w.Start, err = parseClock(start); err != nil {
+		return w, err
+	}
+	if w.End, err = parseClock(end); err != nil {
+		return w, err
+	}
+	return w, nil
+}
+
+// parseClock 解析 15:04 格式的时间，返回零点起的分钟数（24:00 表示当天结束）
+func parseClock(s string) (int, error) {
+	if s == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("无效的时间 %q，格式为 15:04", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// FormatClock 将零点起的分钟数格式化为 15:04
+func FormatClock(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+// Open t 时刻是否开放（按 t 所在时区判断）
+func (s Schedule) Open(t time.Time) bool {
+	if len(s) == 0 {
+		return true
+	}
+	for _, w := range s {
+		if w.covers(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextOpen t 之后（含 t）最早的开放时间；一年内都不开放时返回 false
+func (s Schedule) NextOpen(t time.Time) (time.Time, bool) {
+	if s.Open(t) {
+		return t, true
+	}
+
+	var next time.Time
+	found := false
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	for i := 0; i <= 366 && !found; i++ {
+		d := day.AddDate(0, 0, i)
+		for _, w := range s {
+			if !w.onDay(d) {
+				continue
+			}
+			start := d.Add(time.Duration(w.Start) * time.Minute)
+			if !start.Before(t) && (!found || start.Before(next)) {
+				next, found = start, true
+			}
+		}
+	}
+	return next, found
+}
+
+// covers t 是否在时段内（包括前一天开始、跨过午夜的时段）
+func (w Window) covers(t time.Time) bool {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	minute := t.Hour()*60 + t.Minute()
+
+	if w.onDay(day) {
+		if w.End > w.Start {
+			if minute >= w.Start && minute < w.End {
+				return true
+			}
+		} else if minute >= w.Start {
+			return true
+		}
+	}
+	// 前一天开始、跨过午夜的时段
+	if w.End <= w.Start && w.onDay(day.AddDate(0, 0, -1)) && minute < w.End {
+		return true
+	}
+	return false
+}
+
+// onDay 时段是否在 day 这一天开始
+func (w Window) onDay(day time.Time) bool {
+	if w.Weekday != nil {
+		return day.Weekday() == *w.Weekday
+	}
+	return day.Format(DateFormat) == w.Date
+}
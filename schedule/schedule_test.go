@@ -0,0 +1,130 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+var shanghai = time.FixedZone("CST", 8*3600)
+
+func at(s string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04", s, shanghai)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func weekly(t *testing.T, weekday int, start, end string) Window {
+	t.Helper()
+	w, err := NewWindow(&weekday, "", start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+func dated(t *testing.T, date, start, end string) Window {
+	t.Helper()
+	w, err := NewWindow(nil, date, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+func TestEmptyScheduleIsAlwaysOpen(t *testing.T) {
+	if !Schedule(nil).Open(at("2026-10-18 03:00")) {
+		t.Error("empty schedule should always be open")
+	}
+}
+
+func TestWeeklyWindow(t *testing.T) {
+	// 2026-10-18 是星期日
+	s := Schedule{weekly(t, 0, "07:00", "11:30")}
+	tests := map[string]bool{
+		"2026-10-18 06:59": false,
+		"2026-10-18 07:00": true,
+		"2026-10-18 11:29": true,
+		"2026-10-18 11:30": false,
+		"2026-10-18 03:00": false,
+		"2026-10-19 08:00": false,
+		"2026-10-25 08:00": true,
+	}
+	for ts, want := range tests {
+		if got := s.Open(at(ts)); got != want {
+			t.Errorf("Open(%s) = %v, want %v", ts, got, want)
+		}
+	}
+}
+
+func TestWindowAcrossMidnight(t *testing.T) {
+	// 圣诞子夜弥撒：12月24日 22:00 至次日 01:30
+	s := Schedule{dated(t, "2026-12-24", "22:00", "01:30")}
+	tests := map[string]bool{
+		"2026-12-24 21:59": false,
+		"2026-12-24 23:00": true,
+		"2026-12-25 01:00": true,
+		"2026-12-25 01:30": false,
+		"2026-12-25 23:00": false,
+	}
+	for ts, want := range tests {
+		if got := s.Open(at(ts)); got != want {
+			t.Errorf("Open(%s) = %v, want %v", ts, got, want)
+		}
+	}
+}
+
+func TestNextOpen(t *testing.T) {
+	s := Schedule{
+		weekly(t, 0, "07:00", "11:30"),
+		dated(t, "2026-10-20", "18:00", "20:00"),
+	}
+
+	next, ok := s.NextOpen(at("2026-10-18 12:00"))
+	if !ok || !next.Equal(at("2026-10-20 18:00")) {
+		t.Errorf("NextOpen = %v, %v; want the feast day window", next, ok)
+	}
+
+	next, ok = s.NextOpen(at("2026-10-20 21:00"))
+	if !ok || !next.Equal(at("2026-10-25 07:00")) {
+		t.Errorf("NextOpen = %v, %v; want next Sunday", next, ok)
+	}
+
+	now := at("2026-10-18 08:00")
+	if next, ok := s.NextOpen(now); !ok || !next.Equal(now) {
+		t.Errorf("NextOpen while open = %v, %v; want now", next, ok)
+	}
+
+	past := Schedule{dated(t, "2025-01-01", "08:00", "09:00")}
+	if _, ok := past.NextOpen(now); ok {
+		t.Error("a schedule with only past dates should never open again")
+	}
+}
+
+func TestNewWindowValidation(t *testing.T) {
+	seven := 7
+	sunday := 0
+	tests := []struct {
+		weekday    *int
+		date       string
+		start, end string
+	}{
+		{nil, "", "07:00", "08:00"},
+		{&sunday, "2026-10-18", "07:00", "08:00"},
+		{&seven, "", "07:00", "08:00"},
+		{nil, "2026-13-01", "07:00", "08:00"},
+		{&sunday, "", "7am", "08:00"},
+		{&sunday, "", "07:00", "25:00"},
+	}
+	for _, tt := range tests {
+		if _, err := NewWindow(tt.weekday, tt.date, tt.start, tt.end); err == nil {
+			t.Errorf("NewWindow(%v, %q, %q, %q) should fail", tt.weekday, tt.date, tt.start, tt.end)
+		}
+	}
+
+	w, err := NewWindow(&sunday, "", "00:00", "24:00")
+	if err != nil || !(Schedule{w}).Open(at("2026-10-18 23:59")) {
+		t.Errorf("00:00-24:00 should cover the whole day: %v", err)
+	}
+}
@@ -0,0 +1,15 @@
+-- 成就进度改为由领域事件（card_obtained / checkin_recorded / points_spent）维护
+-- 成就页面直接读取 user_achievement_progress；表为空时首次查看成就会重新计算，
+-- 也可以执行 go run ./cmd/backfill_achievements 一次性计算所有用户
+
+CREATE TABLE IF NOT EXISTS user_achievement_progress (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    achievement_type_id INTEGER NOT NULL REFERENCES achievement_types(id) ON DELETE CASCADE,
+    progress JSONB NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, achievement_type_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_achievement_progress_type ON user_achievement_progress(achievement_type_id);
+
+SELECT COUNT(*) AS progress_rows FROM user_achievement_progress;
@@ -0,0 +1,57 @@
+-- 成就规则：达成条件保存在 achievement_types 中，由 achievement 包统一判定
+-- milestone_claims 增加 achievement_type_id，支持任意可重复成就
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'achievement_types' AND column_name = 'metric'
+    ) THEN
+        ALTER TABLE achievement_types
+            ADD COLUMN metric VARCHAR(50) NOT NULL DEFAULT '',
+            ADD COLUMN threshold INTEGER NOT NULL DEFAULT 0,
+            ADD COLUMN repeatable BOOLEAN NOT NULL DEFAULT FALSE,
+            ADD COLUMN auto_claim BOOLEAN NOT NULL DEFAULT FALSE,
+            ADD COLUMN scope_type VARCHAR(20) NOT NULL DEFAULT '',
+            ADD COLUMN scope_id INTEGER;
+    END IF;
+END $$;
+
+-- 原来写在代码中的规则（只更新还没有设置规则的成就）
+UPDATE achievement_types SET metric = 'distinct_cards', threshold = 1
+WHERE code = 'first_card' AND metric = '';
+
+UPDATE achievement_types SET metric = 'checkin_days', threshold = 3
+WHERE code = 'pilgrim_nova' AND metric = '';
+
+UPDATE achievement_types SET metric = 'distinct_cards', threshold = 7, repeatable = TRUE, auto_claim = TRUE
+WHERE code = 'milestone_7' AND metric = '';
+
+UPDATE achievement_types SET metric = 'all_cards', threshold = 0
+WHERE code = 'complete_all' AND metric = '';
+
+UPDATE achievement_types SET metric = 'series_completed', threshold = 1
+WHERE code = 'complete_series' AND metric = '';
+
+-- 地点成就：通过 checkin_locations.achievement_code 关联地点
+UPDATE achievement_types SET metric = 'location_checkins', threshold = 15, auto_claim = TRUE, scope_type = 'location'
+WHERE metric = '' AND code IN (SELECT achievement_code FROM checkin_locations WHERE achievement_code IS NOT NULL);
+
+-- milestone_claims 原来只记录 milestone_7
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'milestone_claims' AND column_name = 'achievement_type_id'
+    ) THEN
+        ALTER TABLE milestone_claims ADD COLUMN achievement_type_id INTEGER REFERENCES achievement_types(id) ON DELETE CASCADE;
+        UPDATE milestone_claims SET achievement_type_id = (SELECT id FROM achievement_types WHERE code = 'milestone_7');
+        DELETE FROM milestone_claims WHERE achievement_type_id IS NULL;
+        ALTER TABLE milestone_claims ALTER COLUMN achievement_type_id SET NOT NULL;
+        ALTER TABLE milestone_claims DROP CONSTRAINT IF EXISTS milestone_claims_user_id_card_count_key;
+        ALTER TABLE milestone_claims ADD CONSTRAINT milestone_claims_user_type_count_unique
+            UNIQUE (user_id, achievement_type_id, card_count);
+    END IF;
+END $$;
+
+SELECT code, metric, threshold, repeatable, auto_claim, scope_type, scope_id FROM achievement_types ORDER BY id;
@@ -0,0 +1,26 @@
+-- 添加用户角色和管理操作审计日志
+-- 角色：user（普通用户）、staff（工作人员）、admin（管理员）
+
+DO $$ 
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns 
+        WHERE table_name = 'users' AND column_name = 'role'
+    ) THEN
+        ALTER TABLE users ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'user';
+    END IF;
+END $$;
+
+CREATE TABLE IF NOT EXISTS admin_audit_logs (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    username VARCHAR(50) NOT NULL DEFAULT '',
+    action VARCHAR(30) NOT NULL,
+    target_type VARCHAR(30) NOT NULL,
+    target_id VARCHAR(100) NOT NULL DEFAULT '',
+    detail JSONB,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_admin_audit_logs_target ON admin_audit_logs(target_type, target_id);
+CREATE INDEX IF NOT EXISTS idx_admin_audit_logs_created_at ON admin_audit_logs(created_at);
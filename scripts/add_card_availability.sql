@@ -0,0 +1,21 @@
+-- 卡片上架时间窗口：限时卡片（如圣人节日卡）只在窗口内进入卡池，过期后仍保留在用户卡包中
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'cards' AND column_name = 'available_from'
+    ) THEN
+        ALTER TABLE cards ADD COLUMN available_from TIMESTAMPTZ;
+    END IF;
+END $$;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'cards' AND column_name = 'available_until'
+    ) THEN
+        ALTER TABLE cards ADD COLUMN available_until TIMESTAMPTZ;
+    END IF;
+END $$;
@@ -0,0 +1,23 @@
+-- 卡片管理：为 cards 表添加展示顺序和下架标记
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'cards' AND column_name = 'sort_order'
+    ) THEN
+        ALTER TABLE cards ADD COLUMN sort_order INTEGER NOT NULL DEFAULT 0;
+        -- 现有卡片按编号排序
+        UPDATE cards SET sort_order = id;
+    END IF;
+END $$;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'cards' AND column_name = 'retired'
+    ) THEN
+        ALTER TABLE cards ADD COLUMN retired BOOLEAN NOT NULL DEFAULT FALSE;
+    END IF;
+END $$;
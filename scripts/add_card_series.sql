@@ -0,0 +1,56 @@
+-- 卡片系列：新增 card_series 表和 cards.series_id，系列不再借用 rarity 字段
+-- 已有卡片按原来的 rarity 分组生成系列，保持 complete_series 成就的判定结果不变
+
+CREATE TABLE IF NOT EXISTS card_series (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) UNIQUE NOT NULL,
+    description TEXT,
+    cover_image_url VARCHAR(255),
+    sort_order INTEGER NOT NULL DEFAULT 0,
+    reward_points INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'cards' AND column_name = 'series_id'
+    ) THEN
+        ALTER TABLE cards ADD COLUMN series_id INTEGER REFERENCES card_series(id) ON DELETE SET NULL;
+
+        INSERT INTO card_series (name, sort_order)
+        SELECT rarity, ROW_NUMBER() OVER (ORDER BY MIN(id))
+        FROM cards
+        WHERE rarity IS NOT NULL
+        GROUP BY rarity
+        ON CONFLICT (name) DO NOTHING;
+
+        UPDATE cards SET series_id = card_series.id
+        FROM card_series
+        WHERE card_series.name = cards.rarity;
+    END IF;
+END $$;
+
+CREATE TABLE IF NOT EXISTS series_completions (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    series_id INTEGER NOT NULL REFERENCES card_series(id) ON DELETE CASCADE,
+    reward_points INTEGER NOT NULL DEFAULT 0,
+    completed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id, series_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_cards_series_id ON cards(series_id);
+CREATE INDEX IF NOT EXISTS idx_series_completions_user_id ON series_completions(user_id);
+
+-- 已经集齐的用户记为已完成（不补发奖励，避免迁移时凭空增加兑换点）
+INSERT INTO series_completions (user_id, series_id, reward_points)
+SELECT uc.user_id, c.series_id, 0
+FROM user_cards uc
+INNER JOIN cards c ON c.id = uc.card_id AND c.series_id IS NOT NULL AND c.retired = false
+GROUP BY uc.user_id, c.series_id
+HAVING COUNT(DISTINCT uc.card_id) = (
+    SELECT COUNT(*) FROM cards c2 WHERE c2.series_id = c.series_id AND c2.retired = false
+)
+ON CONFLICT (user_id, series_id) DO NOTHING;
@@ -0,0 +1,14 @@
+-- 现场签到屏幕改用每个地点独立的展示密钥（POST /api/admin/checkin-codes/{id}/display-key 生成），
+-- 不再在屏幕上登录工作人员账号；数据库只保存密钥的 SHA-256
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'checkin_locations' AND column_name = 'display_key_hash'
+    ) THEN
+        ALTER TABLE checkin_locations ADD COLUMN display_key_hash VARCHAR(64);
+    END IF;
+END $$;
+
+SELECT id, name, display_key_hash IS NOT NULL AS has_display_key FROM checkin_locations ORDER BY id;
@@ -0,0 +1,30 @@
+-- 签到可信度检查：定位精度、移动速度、重复坐标和现场签到码
+-- 可疑的签到记为 flagged，工作人员通过 /api/admin/checkins 审核通过后才计入抽卡资格和成就
+-- 已有的签到记录保持 accepted
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'location_checkins' AND column_name = 'status'
+    ) THEN
+        ALTER TABLE location_checkins
+            ADD COLUMN accuracy_meters REAL,
+            ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'accepted' CHECK (status IN ('accepted', 'flagged', 'approved', 'rejected')),
+            ADD COLUMN flags TEXT[] NOT NULL DEFAULT '{}',
+            ADD COLUMN reviewed_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+            ADD COLUMN reviewed_at TIMESTAMP,
+            ADD COLUMN review_note VARCHAR(200);
+    END IF;
+END $$;
+
+CREATE INDEX IF NOT EXISTS idx_location_checkins_flagged ON location_checkins(created_at) WHERE status = 'flagged';
+CREATE INDEX IF NOT EXISTS idx_location_checkins_coordinates ON location_checkins(latitude, longitude);
+
+INSERT INTO system_config (key, value, description) VALUES
+    ('checkin_code_required', 'false', '签到是否需要教堂现场展示的签到码，缺少签到码的签到需要审核（true/false）'),
+    ('checkin_max_accuracy', '100', '定位精度超过该值（米）的签到需要审核'),
+    ('checkin_max_speed_kmh', '200', '与上一次签到之间的移动速度超过该值（公里/小时）的签到需要审核')
+ON CONFLICT (key) DO NOTHING;
+
+SELECT status, COUNT(*) FROM location_checkins GROUP BY status;
@@ -0,0 +1,14 @@
+-- 记录每次抽卡是否由保底触发：连续重复次数从最后一次抽到新卡或触发保底之后开始计算
+-- 以前只在抽到新卡时清零，已集齐所有卡片的用户达到保底次数后每次抽卡都会触发保底
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'daily_draws' AND column_name = 'pity'
+    ) THEN
+        ALTER TABLE daily_draws ADD COLUMN pity BOOLEAN NOT NULL DEFAULT FALSE;
+    END IF;
+END $$;
+
+SELECT pity, COUNT(*) FROM daily_draws GROUP BY pity;
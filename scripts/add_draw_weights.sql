@@ -0,0 +1,16 @@
+-- 添加抽卡概率配置表
+-- 运营可直接修改 draw_weights 调整活动概率，例如：
+--   UPDATE draw_weights SET weight = 0.8, updated_at = CURRENT_TIMESTAMP WHERE category = 'policy' AND key = 'new_card_rate';
+
+CREATE TABLE IF NOT EXISTS draw_weights (
+    category VARCHAR(30) NOT NULL,
+    key VARCHAR(50) NOT NULL,
+    weight DOUBLE PRECISION NOT NULL,
+    description TEXT,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (category, key)
+);
+
+INSERT INTO draw_weights (category, key, weight, description) VALUES
+    ('policy', 'new_card_rate', 0.9, '抽到未拥有卡片的概率（0~1）')
+ON CONFLICT (category, key) DO NOTHING;
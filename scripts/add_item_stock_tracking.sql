@@ -0,0 +1,18 @@
+-- 奖品库存跟踪：库存不足提醒，兑换券过期未领取时退还兑换点并恢复库存
+-- 兑换时扣减库存、过期或取消时恢复库存由代码在同一事务中完成；补货通过 POST /api/admin/redeemable-items/{id}/stock 按增量调整
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'redeemable_items' AND column_name = 'low_stock_threshold'
+    ) THEN
+        ALTER TABLE redeemable_items
+            ADD COLUMN low_stock_threshold INTEGER NOT NULL DEFAULT 5 CHECK (low_stock_threshold >= 0);
+    END IF;
+END $$;
+
+SELECT id, code, name, stock, low_stock_threshold,
+    (SELECT COUNT(*) FROM redemption_records rr WHERE rr.item_id = i.id AND rr.status = 'pending') AS pending
+FROM redeemable_items i
+ORDER BY sort_order, id;
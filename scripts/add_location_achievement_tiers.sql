@@ -0,0 +1,9 @@
+-- 地点成就分级：每个打卡地点按 location_achievement_tiers 自动生成成就（代码 location_<地点ID>_<次数>）
+-- 服务启动时和通过 /api/admin/config 修改该配置后，会为所有地点补充缺少的成就；
+-- 已有的 location_a_15 等成就通过 achievement_code 关联，视为该地点 15 次的等级，不会重复生成
+
+INSERT INTO system_config (key, value, description) VALUES
+    ('location_achievement_tiers', '5:初访者,15:朝圣者,50:守护者', '地点成就分级，逗号分隔的 次数:称号，每个打卡地点按此自动生成成就')
+ON CONFLICT (key) DO NOTHING;
+
+SELECT key, value FROM system_config WHERE key = 'location_achievement_tiers';
@@ -0,0 +1,32 @@
+-- 打卡地点的不规则边界和开放时段
+-- 边界为 GeoJSON Polygon/MultiPolygon（坐标顺序为 [经度, 纬度]），设置后按边界判定是否在范围内
+-- 开放时段通过 /api/admin/locations 维护，地点没有任何时段时全天开放
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'checkin_locations' AND column_name = 'boundary'
+    ) THEN
+        ALTER TABLE checkin_locations ADD COLUMN boundary JSONB;
+    END IF;
+END $$;
+
+CREATE TABLE IF NOT EXISTS location_schedules (
+    id SERIAL PRIMARY KEY,
+    location_id INTEGER NOT NULL REFERENCES checkin_locations(id) ON DELETE CASCADE,
+    weekday SMALLINT CHECK (weekday BETWEEN 0 AND 6),
+    date DATE,
+    start_time TIME NOT NULL,
+    end_time TIME NOT NULL,
+    note VARCHAR(200),
+    CHECK ((weekday IS NULL) <> (date IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_location_schedules_location_id ON location_schedules(location_id);
+
+SELECT cl.id, cl.name, cl.boundary IS NOT NULL AS has_boundary, COUNT(ls.id) AS schedules
+FROM checkin_locations cl
+LEFT JOIN location_schedules ls ON ls.location_id = cl.id
+GROUP BY cl.id
+ORDER BY cl.id;
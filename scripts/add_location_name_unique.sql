@@ -0,0 +1,11 @@
+-- 打卡地点改由 /api/admin/locations 管理：地点名称不区分大小写唯一
+-- 已有重复名称时先列出，需手动改名后再执行
+
+SELECT lower(name) AS name, array_agg(id ORDER BY id) AS ids
+FROM checkin_locations
+GROUP BY lower(name)
+HAVING COUNT(*) > 1;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_checkin_locations_name ON checkin_locations (lower(name));
+
+SELECT indexname, indexdef FROM pg_indexes WHERE indexname = 'idx_checkin_locations_name';
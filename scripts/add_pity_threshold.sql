@@ -0,0 +1,6 @@
+-- 添加保底配置：连续抽到重复卡 N 次后，下一次必出新卡（已集齐时必出最稀有的卡）
+-- 设置为 0 可关闭保底
+
+INSERT INTO draw_weights (category, key, weight, description) VALUES
+    ('policy', 'pity_threshold', 5, '连续抽到重复卡多少次后下一次必出新卡（0 表示关闭保底）')
+ON CONFLICT (category, key) DO NOTHING;
@@ -0,0 +1,24 @@
+-- 兑换点流水：所有兑换点变动都追加到 points_ledger，users.exchange_points 作为余额缓存
+-- 已有的兑换点记为一条 opening_balance 流水；之后可执行 go run ./cmd/reconcile_points 对账
+
+CREATE TABLE IF NOT EXISTS points_ledger (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    delta INTEGER NOT NULL,
+    balance_after INTEGER NOT NULL CHECK (balance_after >= 0),
+    reason VARCHAR(30) NOT NULL,
+    source_type VARCHAR(30),
+    source_id INTEGER,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_points_ledger_user_id ON points_ledger(user_id, id);
+
+-- 期初余额（只为还没有流水的用户补录，重复执行不会重复记录）
+INSERT INTO points_ledger (user_id, delta, balance_after, reason)
+SELECT u.id, u.exchange_points, u.exchange_points, 'opening_balance'
+FROM users u
+WHERE u.exchange_points <> 0
+  AND NOT EXISTS (SELECT 1 FROM points_ledger l WHERE l.user_id = u.id);
+
+SELECT reason, COUNT(*) AS entries, SUM(delta) AS total FROM points_ledger GROUP BY reason ORDER BY reason;
@@ -0,0 +1,15 @@
+-- 扫码签到：教堂现场的屏幕展示定时轮换的签名二维码（/api/admin/checkin-codes/{id}/qr，展示页面 checkin-display.html）
+-- 用户扫码后 /api/checkin 凭二维码凭证签到，不需要定位；扫码签到没有坐标
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'location_checkins' AND column_name = 'method'
+    ) THEN
+        ALTER TABLE location_checkins
+            ADD COLUMN method VARCHAR(10) NOT NULL DEFAULT 'gps' CHECK (method IN ('gps', 'qr'));
+    END IF;
+END $$;
+
+SELECT method, COUNT(*) FROM location_checkins GROUP BY method;
@@ -0,0 +1,9 @@
+-- 添加稀有度权重配置（单卡权重，数值越大越容易抽到）
+-- 未配置的稀有度按权重 1 计算
+-- 当前概率公示：GET /api/draw/odds
+
+INSERT INTO draw_weights (category, key, weight, description) VALUES
+    ('rarity', 'common', 10, '普通卡单卡权重'),
+    ('rarity', 'rare', 4, '稀有卡单卡权重'),
+    ('rarity', 'legendary', 1, '传说卡单卡权重')
+ON CONFLICT (category, key) DO NOTHING;
@@ -0,0 +1,54 @@
+-- 兑换目录：奖品、消耗、库存、每周期次数和领取地点保存在 redeemable_items 中，通过管理接口维护
+-- 原来硬编码的 basic（1点）/ premium（5点）作为默认奖品导入，已有兑换记录关联到对应奖品
+
+CREATE TABLE IF NOT EXISTS redeemable_items (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(50) UNIQUE NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    cost INTEGER NOT NULL CHECK (cost >= 0),
+    stock INTEGER CHECK (stock >= 0),
+    period_limit INTEGER NOT NULL DEFAULT 1 CHECK (period_limit >= 0),
+    pickup_location VARCHAR(200) NOT NULL,
+    image_url VARCHAR(255),
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    sort_order INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO redeemable_items (code, name, description, cost, period_limit, pickup_location, sort_order) VALUES
+    ('basic', '基础兑换', '钓圣人徽章机会', 1, 1, '罗源南门堂圣物部', 1),
+    ('premium', '高级兑换', '指定圣人徽章', 5, 1, '罗源南门堂圣物部', 2)
+ON CONFLICT (code) DO NOTHING;
+
+-- 一个周期总共只能兑换一次改为可配置（默认仍为 1）
+INSERT INTO system_config (key, value, description) VALUES
+    ('redemption_period_limit', '1', '每个兑换周期所有奖品合计最多兑换次数（0 表示不限）')
+ON CONFLICT (key) DO NOTHING;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'redemption_records' AND column_name = 'item_id'
+    ) THEN
+        ALTER TABLE redemption_records
+            ALTER COLUMN redemption_type TYPE VARCHAR(50),
+            ADD COLUMN item_id INTEGER REFERENCES redeemable_items(id),
+            ADD COLUMN cost INTEGER NOT NULL DEFAULT 0;
+
+        UPDATE redemption_records rr
+        SET item_id = i.id, cost = i.cost
+        FROM redeemable_items i
+        WHERE i.code = rr.redemption_type;
+    END IF;
+END $$;
+
+-- 次数限制由代码按配置检查，不再使用唯一约束
+ALTER TABLE redemption_records DROP CONSTRAINT IF EXISTS redemption_records_user_month_unique;
+ALTER TABLE redemption_records DROP CONSTRAINT IF EXISTS redemption_records_user_id_redemption_month_key;
+
+CREATE INDEX IF NOT EXISTS idx_redemption_records_user_month ON redemption_records(user_id, redemption_month);
+CREATE INDEX IF NOT EXISTS idx_redemption_records_item_id ON redemption_records(item_id);
+
+SELECT id, code, name, cost, stock, period_limit, pickup_location, active FROM redeemable_items ORDER BY sort_order, id;
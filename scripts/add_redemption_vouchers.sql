@@ -0,0 +1,32 @@
+-- 兑换券：每次兑换生成签名的一次性兑换券，工作人员扫码核销
+-- 兑换记录增加状态 pending（待领取）/ fulfilled（已核销）/ expired（过期未领取）/ cancelled（已取消，兑换点已退还）
+-- 启用兑换券之前的兑换记录没有兑换券，视为已在兑换处领取
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'redemption_records' AND column_name = 'status'
+    ) THEN
+        ALTER TABLE redemption_records
+            ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'pending'
+                CHECK (status IN ('pending', 'fulfilled', 'expired', 'cancelled')),
+            ADD COLUMN voucher_code VARCHAR(64) UNIQUE,
+            ADD COLUMN expires_at TIMESTAMP,
+            ADD COLUMN fulfilled_at TIMESTAMP,
+            ADD COLUMN fulfilled_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+            ADD COLUMN cancelled_at TIMESTAMP,
+            ADD COLUMN cancelled_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+            ADD COLUMN cancel_reason TEXT;
+
+        UPDATE redemption_records SET status = 'fulfilled', fulfilled_at = redeemed_at;
+    END IF;
+END $$;
+
+CREATE INDEX IF NOT EXISTS idx_redemption_records_pending ON redemption_records(expires_at) WHERE status = 'pending';
+
+INSERT INTO system_config (key, value, description) VALUES
+    ('voucher_valid_days', '30', '兑换券有效天数，过期未领取的兑换券作废')
+ON CONFLICT (key) DO NOTHING;
+
+SELECT status, COUNT(*) FROM redemption_records GROUP BY status ORDER BY status;
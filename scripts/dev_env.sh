@@ -0,0 +1,18 @@
+#!/bin/bash
+# 本地开发用的签名密钥（由 dev.sh 等启动脚本 source）
+# 首次运行时随机生成到 .dev.env（不提交到仓库），之后复用，重启后 token 仍然有效
+
+DEV_ENV_FILE="$(cd "$(dirname "${BASH_SOURCE[0]}")/.." && pwd)/.dev.env"
+
+touch "$DEV_ENV_FILE"
+chmod 600 "$DEV_ENV_FILE"
+for KEY in JWT_SECRET VOUCHER_SECRET CHECKIN_SECRET; do
+    if ! grep -q "^$KEY=" "$DEV_ENV_FILE"; then
+        echo "🔑 生成本地开发密钥 $KEY: $DEV_ENV_FILE"
+        echo "$KEY=$(openssl rand -base64 32)" >> "$DEV_ENV_FILE"
+    fi
+done
+
+set -a
+. "$DEV_ENV_FILE"
+set +a
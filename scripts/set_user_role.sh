@@ -0,0 +1,41 @@
+#!/bin/bash
+# 设置用户角色（用于创建第一个管理员，之后可通过 /api/admin/users/{id}/role 管理）
+# 使用方法: ./scripts/set_user_role.sh <用户名> <user|staff|admin>
+
+USERNAME=$1
+ROLE=$2
+
+if [ -z "$USERNAME" ] || [ -z "$ROLE" ]; then
+    echo "❌ 用法: ./scripts/set_user_role.sh <用户名> <user|staff|admin>"
+    exit 1
+fi
+
+if [ "$ROLE" != "user" ] && [ "$ROLE" != "staff" ] && [ "$ROLE" != "admin" ]; then
+    echo "❌ 无效的角色: $ROLE（必须是 user、staff 或 admin）"
+    exit 1
+fi
+
+# 检查Docker容器是否运行
+if ! docker ps | grep -q h5project_db; then
+    echo "❌ 数据库容器未运行"
+    exit 1
+fi
+
+CONTAINER_NAME=$(docker ps --format "{{.Names}}" | grep -E "(db|postgres|h5project)" | head -1)
+
+if [ -z "$CONTAINER_NAME" ]; then
+    echo "❌ 未找到数据库容器"
+    exit 1
+fi
+
+# 通过标准输入执行，使 psql 变量替换生效（-c 不支持变量替换），避免用户名中的引号破坏SQL
+UPDATED=$(echo "UPDATE users SET role = :'role', updated_at = CURRENT_TIMESTAMP WHERE username = :'username' RETURNING id;" | \
+    docker exec -i $CONTAINER_NAME psql -U h5user -d h5project -t -q -v username="$USERNAME" -v role="$ROLE" | tr -d ' \n')
+
+if [ -z "$UPDATED" ]; then
+    echo "❌ 用户不存在: $USERNAME"
+    exit 1
+fi
+
+echo "✅ 已将用户 $USERNAME 的角色设置为 $ROLE"
+echo "💡 提示：用户需要重新登录后生效"
@@ -0,0 +1,7 @@
+-- 朝圣新星改为按打卡过的不同地点（教堂）判定，原来用 3 个不同的抽卡日期模拟
+-- 执行后运行 go run ./cmd/backfill_achievements 重新评估已有用户
+
+UPDATE achievement_types SET metric = 'distinct_locations', threshold = 3
+WHERE code = 'pilgrim_nova' AND metric = 'checkin_days';
+
+SELECT code, metric, threshold FROM achievement_types WHERE code = 'pilgrim_nova';
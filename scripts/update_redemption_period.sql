@@ -0,0 +1,14 @@
+-- 支持可配置的兑换周期（每月 / 每周 / 每次活动）
+-- redemption_month 改为存放周期标识：YYYY-MM（每月）、YYYY-Www（每周）或 event:<活动标识>
+
+ALTER TABLE redemption_records ALTER COLUMN redemption_month TYPE VARCHAR(50);
+
+-- 切换兑换周期示例（修改后需重启服务）：
+--   INSERT INTO system_config (key, value, description) VALUES
+--       ('redemption_period', 'weekly', '兑换周期（monthly/weekly/event）')
+--   ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_at = CURRENT_TIMESTAMP;
+--
+-- 按活动兑换时还需要设置活动标识，换一个标识即开启新的兑换周期：
+--   INSERT INTO system_config (key, value, description) VALUES
+--       ('redemption_event_key', '2025-carlo-feast', '当前兑换活动标识')
+--   ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_at = CURRENT_TIMESTAMP;
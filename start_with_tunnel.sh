@@ -33,6 +33,7 @@ if lsof -Pi :8080 -sTCP:LISTEN -t >/dev/null 2>&1; then
     echo "✅ 服务器已在运行（端口 8080）"
 else
     echo "启动服务器..."
+    . ./scripts/dev_env.sh
     go run main.go > server.log 2>&1 &
     sleep 2
     echo "✅ 服务器已启动"
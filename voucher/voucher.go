@@ -0,0 +1,114 @@
+package voucher
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// 兑换券代码格式: R<兑换记录ID>-<随机串>-<签名>，如 R123-7K3QX2MA-J5Q2W8ZC4N6PD3VB
+// 签名为 HMAC-SHA256(密钥, "R<ID>-<随机串>") 的前 10 字节，编码为不含填充的 base32
+// 签名只用于在查库前拒绝伪造的代码；兑换券是否有效、是否已核销以 redemption_records 为准
+
+// ErrInvalid 兑换券代码格式错误或签名不匹配
+var ErrInvalid = errors.New("无效的兑换券")
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+var (
+	mu           sync.RWMutex
+	secret       []byte
+	previousKeys [][]byte
+)
+
+// SetSecret 设置签名密钥（启动时调用一次）
+// previous 为更换密钥前使用的密钥，只用于校验更换前发出的兑换券，新的兑换券始终用 s 签名
+func SetSecret(s string, previous ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+	secret = []byte(s)
+	previousKeys = previousKeys[:0]
+	for _, p := range previous {
+		if p != "" {
+			previousKeys = append(previousKeys, []byte(p))
+		}
+	}
+}
+
+// Issue 为兑换记录生成一个新的兑换券代码
+func Issue(redemptionID int) (string, error) {
+	nonce := make([]byte, 5)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("生成兑换券失败: %w", err)
+	}
+	payload := fmt.Sprintf("R%d-%s", redemptionID, encoding.EncodeToString(nonce))
+	return payload + "-" + sign(payload), nil
+}
+
+// Parse 校验兑换券代码的签名，返回对应的兑换记录ID
+// 代码不区分大小写，前后空白会被忽略（方便工作人员手动输入）
+func Parse(code string) (int, error) {
+	code = Normalize(code)
+	i := strings.LastIndex(code, "-")
+	if i < 0 || !strings.HasPrefix(code, "R") {
+		return 0, ErrInvalid
+	}
+	payload, sig := code[:i], code[i+1:]
+	if !verify(payload, sig) {
+		return 0, ErrInvalid
+	}
+
+	parts := strings.SplitN(payload[1:], "-", 2)
+	if len(parts) != 2 {
+		return 0, ErrInvalid
+	}
+	id, err := strconv.Atoi(parts[0])
+	if err != nil || id <= 0 {
+		return 0, ErrInvalid
+	}
+	return id, nil
+}
+
+// Normalize 统一兑换券代码的大小写和空白，用于按代码查库
+func Normalize(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// QRCode 生成兑换券代码的二维码 PNG，size 为图片边长（像素）
+func QRCode(code string, size int) ([]byte, error) {
+	return qrcode.Encode(code, qrcode.Medium, size)
+}
+
+func sign(payload string) string {
+	mu.RLock()
+	key := secret
+	mu.RUnlock()
+	return signWith(key, payload)
+}
+
+// verify 用当前密钥或更换前的密钥校验签名
+func verify(payload, sig string) bool {
+	mu.RLock()
+	keys := append([][]byte{secret}, previousKeys...)
+	mu.RUnlock()
+	for _, key := range keys {
+		if hmac.Equal([]byte(sig), []byte(signWith(key, payload))) {
+			return true
+		}
+	}
+	return false
+}
+
+func signWith(key []byte, payload string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return encoding.EncodeToString(mac.Sum(nil)[:10])
+}